@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"lystem/pkg/accrualstub"
+)
+
+func main() {
+	address := flag.String("a", "localhost:8081", "address to run accrual stub on")
+	rulesPath := flag.String("rules", "", "path to JSON file with stub rules")
+	flag.Parse()
+
+	var rules accrualstub.Rules
+	if *rulesPath != "" {
+		data, err := os.ReadFile(*rulesPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err = json.Unmarshal(data, &rules); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	server := &http.Server{Addr: *address, Handler: accrualstub.New(rules)}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		if err := server.Shutdown(context.Background()); err != nil {
+			log.Println(err)
+		}
+	}()
+
+	log.Printf("accrual stub listening on %s", *address)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
+	}
+}
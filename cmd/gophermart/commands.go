@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"lystem/internal/usecase"
+	"lystem/pkg/postgres"
+)
+
+var errLedgerMismatch = errors.New("ledger check failed")
+
+type runner func(ctx context.Context, db *postgres.DBStorage, args []string) error
+
+// commands maps "<command> <action>" to its runner, words after them are passed to the runner.
+// Migrations are run by runCommand itself.
+var commands = map[string]runner{
+	"ledger check": noArgs(ledgerCheck),
+	"login unlock": loginUnlock,
+	"user role":    userRole,
+}
+
+func findCommand(args []string) (runner, bool) {
+	if len(args) < 2 {
+		return nil, false
+	}
+	run, ok := commands[args[0]+" "+args[1]]
+	return run, ok
+}
+
+func noArgs(run func(ctx context.Context, db *postgres.DBStorage) error) runner {
+	return func(ctx context.Context, db *postgres.DBStorage, args []string) error {
+		if len(args) > 0 {
+			return errUnknownCommand
+		}
+		return run(ctx, db)
+	}
+}
+
+func ledgerCheck(ctx context.Context, db *postgres.DBStorage) error {
+	report, err := usecase.NewLedgerUsecase(db).Check(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range report.Mismatches {
+		fmt.Printf("user %d: balance %s, ledger %s\n", m.UserID, m.Current, m.LedgerSum)
+	}
+	for _, id := range report.UnbalancedTransactions {
+		fmt.Printf("transaction %s does not sum up to zero\n", id)
+	}
+	if !report.OK() {
+		return errLedgerMismatch
+	}
+
+	fmt.Println("ledger is consistent")
+	return nil
+}
+
+// loginUnlock lets the user try the password again right away, from any address the login was tried from
+func loginUnlock(ctx context.Context, db *postgres.DBStorage, args []string) error {
+	if len(args) != 1 {
+		return errUnknownCommand
+	}
+
+	unlocked, err := usecase.NewSessionUsecase(db, "").Unlock(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	if !unlocked {
+		fmt.Printf("login %s has no failed attempts\n", args[0])
+		return nil
+	}
+	fmt.Printf("login %s unlocked\n", args[0])
+	return nil
+}
+
+// userRole is the way to appoint the first admin, the API does not change roles
+func userRole(ctx context.Context, db *postgres.DBStorage, args []string) error {
+	if len(args) != 2 {
+		return errUnknownCommand
+	}
+
+	u, err := usecase.NewAdminUsecase(db).SetRole(ctx, args[0], args[1])
+	if err != nil {
+		return err
+	}
+	fmt.Printf("user %s now has role %s\n", u.Login, u.Role)
+	return nil
+}
@@ -5,23 +5,49 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"strings"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/logger"
 	"go.uber.org/zap"
 
+	"lystem/internal/accrual"
 	"lystem/internal/agent"
 	"lystem/internal/config"
 	"lystem/internal/handlers"
+	"lystem/internal/janitor"
 	"lystem/internal/middleware"
+	"lystem/internal/models/idempotency"
+	"lystem/internal/models/session"
+	"lystem/internal/models/user"
+	"lystem/internal/notifier"
+	"lystem/internal/passwordreset"
+	"lystem/internal/storage"
+	"lystem/internal/token"
+	"lystem/pkg/memory"
 	"lystem/pkg/postgres"
 )
 
+// callbackMaxSkew is how far accrual callback timestamp may be from local time
+const callbackMaxSkew = 5 * time.Minute
+
 func main() {
+	if err := config.Load(); err != nil {
+		log.Fatal("Error: ", err)
+	}
+
+	if len(config.Options.Command) > 0 {
+		if err := runCommand(config.Options.Command, config.Options.DatabaseURI); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	// ------- DATABASE -------
-	db, err := postgres.NewStorage(config.Options.DatabaseURI)
+	db, err := newStorage(config.Options.DatabaseURI)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -38,28 +64,98 @@ func main() {
 	var wg sync.WaitGroup
 
 	// ------- ORDERS INFO POLLER -------
-	ordersAgent := agent.New(db, config.Options, zapLogger)
+	accrualClient := accrual.NewHTTPClient(config.Options.AccrualSystemAddress, accrual.Options{
+		Timeout:           config.Options.AccrualRequestTimeout,
+		MaxIdleConns:      config.Options.AccrualMaxIdleConns,
+		DefaultRetryAfter: config.Options.PollInterval,
+		BreakerThreshold:  config.Options.BreakerThreshold,
+		BreakerCooldown:   config.Options.BreakerCooldown,
+	})
+	ordersAgent := agent.New(db, accrualClient, config.Options, zapLogger)
 	wg.Add(1)
 	go ordersAgent.StartOrdersPolling(ctx, &wg)
 
+	// ------- ENDED SESSIONS AND EXPIRED KEYS CLEANUP -------
+	storageJanitor := janitor.New(db, config.Options, zapLogger)
+	wg.Add(1)
+	go storageJanitor.StartCleanup(ctx, &wg)
+
+	// ------- SIGNED ACCESS TOKENS -------
+	var signer *token.Signer
+	if config.Options.AccessTokenKeys != "" {
+		keys, err := token.ParseKeys(config.Options.AccessTokenKeys)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if signer, err = token.NewSigner(keys, config.Options.AccessTokenTTL); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// ------- NOTIFICATIONS -------
+	notificationsOut := os.Stdout
+	if config.Options.NotificationsFile != "" {
+		notificationsOut, err = os.OpenFile(config.Options.NotificationsFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	notifications := notifier.NewWriter(notificationsOut)
+	passwordResets := passwordreset.New(db, notifications, config.Options.PasswordResetTTL, zapLogger)
+	wg.Add(1)
+	go passwordResets.StartDelivery(ctx, &wg)
+
 	// ------- INIT APP -------
 	app := fiber.New()
 
 	// ------- HANDLERS -------
-	v1 := handlers.New(db, ordersAgent, config.Options)
+	v1 := handlers.New(db, ordersAgent, signer, notifications, passwordResets, config.Options)
 	app.Use(logger.New(logger.Config{Output: os.Stdout}))
-	api := app.Group("/api/user", middleware.Authorize(db))
+	sessionTTL := session.TTL{Absolute: config.Options.SessionTTL, Idle: config.Options.SessionIdleTTL}
+	idempotencyTTL := idempotency.TTL{Completed: config.Options.IdempotencyKeyTTL, InProgress: config.Options.IdempotencyLockTTL}
+	api := app.Group("/api/user", middleware.Authorize(db, sessionTTL, signer))
 	api.Post("/register", v1.CreateUser)
 	api.Post("/login", v1.CreateSession)
+	api.Post("/refresh", v1.RefreshSession)
 	api.Delete("/logout", v1.DeleteSession)
+	api.Get("/sessions", v1.GetSessions)
+	api.Delete("/sessions/:id", v1.RevokeSession)
+
+	api.Post("/password", v1.ChangePassword)
+	api.Post("/password/reset", v1.RequestPasswordReset)
+	api.Post("/password/reset/confirm", v1.ConfirmPasswordReset)
 
 	api.Post("/orders", v1.SaveOrder)
+	api.Post("/orders/batch", v1.SaveOrdersBatch)
 	api.Get("/orders", v1.GetOrders)
+	api.Get("/orders/:number", v1.GetOrder)
+	api.Get("/orders/:number/history", v1.GetOrderHistory)
 
 	api.Get("/balance", v1.GetBalance)
-	api.Post("/balance/withdraw", v1.Withdraw)
+	api.Get("/balance/history", v1.GetBalanceHistory)
+	api.Post("/balance/withdraw", middleware.Idempotency(db, idempotencyTTL), v1.Withdraw)
 	api.Get("/withdrawals", v1.Withdrawals)
 
+	// support staff looks users up, only admins change accounts
+	admin := app.Group("/api/admin", middleware.Authorize(db, sessionTTL, signer), middleware.RequireRole(db, user.RoleSupport, user.RoleAdmin))
+	admin.Get("/users/:login", v1.AdminGetUser)
+	admin.Get("/users/:login/orders", v1.AdminGetUserOrders)
+	admin.Get("/users/:login/balance", v1.AdminGetUserBalance)
+	admin.Get("/users/:login/withdrawals", v1.AdminGetUserWithdrawals)
+	admin.Post("/orders/:number/recheck", v1.AdminRecheckOrder)
+	admin.Post("/users/:login/block", middleware.RequireRole(db, user.RoleAdmin), v1.AdminBlockUser)
+	admin.Delete("/users/:login/block", middleware.RequireRole(db, user.RoleAdmin), v1.AdminUnblockUser)
+	admin.Delete("/logins/:login/lock", middleware.RequireRole(db, user.RoleAdmin), v1.AdminUnlockLogin)
+	admin.Post("/users/:login/adjustments", v1.CreateAdjustment)
+	admin.Get("/users/:login/adjustments", v1.GetUserAdjustments)
+	admin.Get("/adjustments", v1.GetAdjustments)
+	admin.Post("/adjustments/:id/approve", middleware.RequireRole(db, user.RoleAdmin), v1.ApproveAdjustment)
+	admin.Post("/adjustments/:id/reject", middleware.RequireRole(db, user.RoleAdmin), v1.RejectAdjustment)
+
+	if config.Options.AccrualWebhookSecret != "" {
+		app.Post("/internal/accrual/callback", middleware.AccrualSignature(config.Options.AccrualWebhookSecret, callbackMaxSkew), v1.AccrualCallback)
+	}
+
 	// ------- GRACEFULLY SHUTDOWN -------
 	exit := make(chan os.Signal, 1)
 	waiter := make(chan os.Signal, 1)
@@ -73,7 +169,7 @@ func main() {
 		sig := <-waiter
 		zapLogger.Info("1 Signal notify received: " + sig.String())
 
-		// cancel context, that we sent to orders poller
+		// cancel context, that we sent to orders poller and storage janitor
 		cancel()
 
 		zapLogger.Info("2 Gracefully shutting down loystem application")
@@ -84,12 +180,16 @@ func main() {
 		zapLogger.Info("3 Application shut down")
 
 		wg.Wait()
-		zapLogger.Info("5 Finish waiting agent wait group done()")
+		zapLogger.Info("5 Finish waiting agent and janitor wait group done()")
 
 		// gracefully close database connection after finishing agent work
 		db.Close()
 		zapLogger.Info("6 Database connections closed")
 
+		if notificationsOut != os.Stdout {
+			_ = notificationsOut.Close()
+		}
+
 		// signal main goroutine that gracefully shutdown finished
 		exit <- syscall.SIGSTOP
 	}()
@@ -105,3 +205,12 @@ func main() {
 	// exit with code 0 to signal, that everything gone right
 	os.Exit(0)
 }
+
+// newStorage picks storage by database URI: empty URI or "memory://" scheme
+// keeps everything in process memory, anything else is treated as postgres DSN.
+func newStorage(uri string) (storage.Storage, error) {
+	if uri == "" || strings.HasPrefix(uri, "memory://") {
+		return memory.NewStorage(), nil
+	}
+	return postgres.NewStorage(uri)
+}
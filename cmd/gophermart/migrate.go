@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"lystem/pkg/postgres"
+)
+
+var migrateActions = []string{"up", "down", "status"}
+
+var (
+	errUnknownCommand  = errors.New("unknown command, usage: gophermart [flags] migrate up|down|status | ledger check | login unlock <login> | user role <login> user|support|admin")
+	errMigrateInMemory = errors.New("command requires postgres database URI")
+)
+
+func runCommand(args []string, databaseURI string) error {
+	run, found := findCommand(args)
+	if !found && (len(args) != 2 || args[0] != "migrate" || !slices.Contains(migrateActions, args[1])) {
+		return errUnknownCommand
+	}
+	if databaseURI == "" || strings.HasPrefix(databaseURI, "memory://") {
+		return errMigrateInMemory
+	}
+
+	db, err := postgres.Connect(databaseURI)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	if found {
+		return run(ctx, db, args[2:])
+	}
+	switch args[1] {
+	case "up":
+		applied, err := db.MigrateUp(ctx)
+		for _, m := range applied {
+			fmt.Printf("applied %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Println("no pending migrations")
+		}
+	case "down":
+		rolledBack, err := db.MigrateDown(ctx)
+		if err != nil {
+			return err
+		}
+		if rolledBack == nil {
+			fmt.Println("no applied migrations")
+			return nil
+		}
+		fmt.Printf("rolled back %04d_%s\n", rolledBack.Version, rolledBack.Name)
+	case "status":
+		statuses, err := db.MigrationsStatus(ctx)
+		if err != nil {
+			return err
+		}
+		for _, st := range statuses {
+			state := "pending"
+			if st.Applied {
+				state = "applied " + st.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%s\t%s\n", st.Version, st.Name, state)
+		}
+	default:
+		return errUnknownCommand
+	}
+	return nil
+}
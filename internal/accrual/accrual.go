@@ -0,0 +1,141 @@
+// Package accrual is a client of the accrual system: GET /api/orders/{number}.
+package accrual
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"lystem/internal/request"
+)
+
+var (
+	// ErrNotRegistered is returned when accrual system does not know the order (204)
+	ErrNotRegistered = errors.New("order is not registered in accrual system")
+	ErrMalformed     = errors.New("malformed accrual system response")
+
+	// reasons of RetryLaterError
+	ErrTooManyRequests = errors.New("too many requests")
+	ErrBreakerOpen     = errors.New("circuit breaker is open")
+)
+
+// RetryLaterError asks caller to hold all requests to accrual system for RetryAfter,
+// either because of 429 (ErrTooManyRequests) or because circuit breaker is open (ErrBreakerOpen)
+type RetryLaterError struct {
+	RetryAfter time.Duration
+	Reason     error
+}
+
+func (e *RetryLaterError) Error() string {
+	return fmt.Sprintf("%s, retry after %s", e.Reason, e.RetryAfter)
+}
+
+func (e *RetryLaterError) Unwrap() error {
+	return e.Reason
+}
+
+// UnexpectedStatusError is any answer not described by accrual system contract, 500 included
+type UnexpectedStatusError struct {
+	StatusCode int
+}
+
+func (e *UnexpectedStatusError) Error() string {
+	return fmt.Sprintf("accrual system responded with %d", e.StatusCode)
+}
+
+type Options struct {
+	// Timeout limits the whole request including reading the body
+	Timeout         time.Duration
+	MaxIdleConns    int
+	IdleConnTimeout time.Duration
+	// DefaultRetryAfter is used when 429 comes without valid Retry-After header
+	DefaultRetryAfter time.Duration
+	// after BreakerThreshold failures in a row requests are not sent for BreakerCooldown,
+	// then a single probe request decides whether to close the breaker
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+}
+
+type HTTPClient struct {
+	baseURL           string
+	client            *http.Client
+	breaker           *Breaker
+	defaultRetryAfter time.Duration
+}
+
+func NewHTTPClient(baseURL string, options Options) *HTTPClient {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if options.MaxIdleConns > 0 {
+		transport.MaxIdleConns = options.MaxIdleConns
+		transport.MaxIdleConnsPerHost = options.MaxIdleConns
+	}
+	if options.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = options.IdleConnTimeout
+	}
+
+	return &HTTPClient{
+		baseURL:           baseURL,
+		client:            &http.Client{Timeout: options.Timeout, Transport: transport},
+		breaker:           NewBreaker(options.BreakerThreshold, options.BreakerCooldown),
+		defaultRetryAfter: options.DefaultRetryAfter,
+	}
+}
+
+// GetOrder asks accrual system about the order. Request is cancelled together with ctx.
+func (c *HTTPClient) GetOrder(ctx context.Context, number string) (*request.GetOrderRequest, error) {
+	if wait, ok := c.breaker.Allow(); !ok {
+		return nil, &RetryLaterError{RetryAfter: wait, Reason: ErrBreakerOpen}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/api/orders/"+url.PathEscape(number), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		// cancelled on shutdown is not a failure of accrual system
+		if ctx.Err() != nil {
+			c.breaker.Abort()
+			return nil, err
+		}
+		c.breaker.Failure()
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		c.breaker.Success()
+		var orderInfo request.GetOrderRequest
+		if err = json.NewDecoder(resp.Body).Decode(&orderInfo); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrMalformed, err)
+		}
+		if err = orderInfo.Validate(number); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrMalformed, err)
+		}
+		return &orderInfo, nil
+	case http.StatusNoContent:
+		c.breaker.Success()
+		return nil, ErrNotRegistered
+	case http.StatusTooManyRequests:
+		c.breaker.Success()
+		retryAfter := c.defaultRetryAfter
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			retryAfter = time.Duration(seconds) * time.Second
+		}
+		return nil, &RetryLaterError{RetryAfter: retryAfter, Reason: ErrTooManyRequests}
+	default:
+		if resp.StatusCode >= http.StatusInternalServerError {
+			c.breaker.Failure()
+		} else {
+			c.breaker.Success()
+		}
+		return nil, &UnexpectedStatusError{StatusCode: resp.StatusCode}
+	}
+}
@@ -0,0 +1,152 @@
+package accrual
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"lystem/internal/request"
+)
+
+func TestGetOrder(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		retryAfter string
+		body       string
+		want       *request.GetOrderRequest
+		wantErr    error
+		wantRetry  time.Duration
+		wantStatus int
+		// wantFailure tells whether the answer counts against accrual system in the breaker
+		wantFailure bool
+	}{
+		{
+			name:   "processed",
+			status: http.StatusOK,
+			body:   `{"order":"12345678903","status":"PROCESSED","accrual":729.98}`,
+			want:   &request.GetOrderRequest{Order: "12345678903", Status: "PROCESSED", Accrual: 72998},
+		},
+		{name: "malformed body", status: http.StatusOK, body: `{"status":`, wantErr: ErrMalformed},
+		{name: "unknown status", status: http.StatusOK, body: `{"status":"DONE"}`, wantErr: ErrMalformed},
+		{name: "another order", status: http.StatusOK, body: `{"order":"1","status":"PROCESSED"}`, wantErr: ErrMalformed},
+		{name: "not registered", status: http.StatusNoContent, wantErr: ErrNotRegistered},
+		{name: "throttled", status: http.StatusTooManyRequests, retryAfter: "7", wantErr: ErrTooManyRequests, wantRetry: 7 * time.Second},
+		{name: "throttled without retry after", status: http.StatusTooManyRequests, wantErr: ErrTooManyRequests, wantRetry: time.Minute},
+		{name: "server error", status: http.StatusInternalServerError, wantStatus: http.StatusInternalServerError, wantFailure: true},
+		{name: "bad gateway", status: http.StatusBadGateway, wantStatus: http.StatusBadGateway, wantFailure: true},
+		{name: "not found", status: http.StatusNotFound, wantStatus: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/api/orders/12345678903" {
+					t.Errorf("request to %s", r.URL.Path)
+				}
+				if tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			// a single failure opens the breaker, so the next Allow tells how the answer was judged
+			client := NewHTTPClient(server.URL, Options{
+				Timeout:           time.Second,
+				DefaultRetryAfter: time.Minute,
+				BreakerThreshold:  1,
+				BreakerCooldown:   time.Hour,
+			})
+			got, err := client.GetOrder(context.Background(), "12345678903")
+
+			var retryLater *RetryLaterError
+			var unexpected *UnexpectedStatusError
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("GetOrder() error = %v, want %v", err, tt.wantErr)
+				}
+				if tt.wantRetry > 0 && (!errors.As(err, &retryLater) || retryLater.RetryAfter != tt.wantRetry) {
+					t.Errorf("GetOrder() error = %v, want retry after %v", err, tt.wantRetry)
+				}
+			case tt.wantStatus != 0:
+				if !errors.As(err, &unexpected) || unexpected.StatusCode != tt.wantStatus {
+					t.Fatalf("GetOrder() error = %v, want unexpected status %d", err, tt.wantStatus)
+				}
+			default:
+				if err != nil {
+					t.Fatal(err)
+				}
+				if *got != *tt.want {
+					t.Errorf("GetOrder() = %+v, want %+v", got, tt.want)
+				}
+			}
+
+			if _, allowed := client.breaker.Allow(); allowed == tt.wantFailure {
+				t.Errorf("breaker allows requests: %v, want %v", allowed, !tt.wantFailure)
+			}
+		})
+	}
+}
+
+func TestGetOrderWithOpenBreaker(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	now := time.Now()
+	client := NewHTTPClient(server.URL, Options{Timeout: time.Second, BreakerThreshold: 1, BreakerCooldown: time.Minute})
+	client.breaker.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	if _, err := client.GetOrder(ctx, "1"); err == nil {
+		t.Fatal("GetOrder() succeeded on 500")
+	}
+
+	_, err := client.GetOrder(ctx, "1")
+	var retryLater *RetryLaterError
+	if !errors.Is(err, ErrBreakerOpen) || !errors.As(err, &retryLater) || retryLater.RetryAfter != time.Minute {
+		t.Errorf("GetOrder() with open breaker error = %v, want %v for a minute", err, ErrBreakerOpen)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("accrual system got %d requests while breaker is open", n)
+	}
+
+	now = now.Add(time.Minute)
+	if _, err = client.GetOrder(ctx, "1"); !errors.Is(err, ErrNotRegistered) {
+		t.Errorf("probe GetOrder() error = %v, want %v", err, ErrNotRegistered)
+	}
+	if _, err = client.GetOrder(ctx, "1"); !errors.Is(err, ErrNotRegistered) {
+		t.Errorf("GetOrder() after successful probe error = %v, want %v", err, ErrNotRegistered)
+	}
+}
+
+func TestGetOrderCancelled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	client := NewHTTPClient(server.URL, Options{Timeout: time.Minute, BreakerThreshold: 1, BreakerCooldown: time.Hour})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := client.GetOrder(ctx, "1"); err == nil {
+		t.Fatal("cancelled GetOrder() succeeded")
+	}
+
+	// cancellation is not a failure of accrual system
+	if _, allowed := client.breaker.Allow(); !allowed {
+		t.Error("breaker opened after cancelled request")
+	}
+}
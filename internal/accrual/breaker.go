@@ -0,0 +1,75 @@
+package accrual
+
+import (
+	"sync"
+	"time"
+)
+
+// Breaker is a circuit breaker: closed - requests pass, open - requests are rejected,
+// half-open - a single probe request is let through after cooldown.
+type Breaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+
+	failures  int
+	openUntil time.Time
+	probing   bool
+	// now is replaced by tests
+	now func() time.Time
+}
+
+// NewBreaker returns breaker opening after threshold failures in a row, zero threshold disables it
+func NewBreaker(threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{threshold: threshold, cooldown: cooldown, now: time.Now}
+}
+
+// Allow reports whether request may be sent, otherwise returns time left till the next probe
+func (b *Breaker) Allow() (time.Duration, bool) {
+	if b.threshold <= 0 {
+		return 0, true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.threshold {
+		return 0, true
+	}
+	if wait := b.openUntil.Sub(b.now()); wait > 0 {
+		return wait, false
+	}
+	if b.probing {
+		return b.cooldown, false
+	}
+
+	b.probing = true
+	return 0, true
+}
+
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	b.probing = false
+}
+
+func (b *Breaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.probing = false
+	if b.failures >= b.threshold {
+		b.openUntil = b.now().Add(b.cooldown)
+	}
+}
+
+// Abort forgets the request without judging accrual system, e.g. when it was cancelled on shutdown
+func (b *Breaker) Abort() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+}
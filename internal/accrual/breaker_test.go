@@ -0,0 +1,121 @@
+package accrual
+
+import (
+	"testing"
+	"time"
+)
+
+// step is an event of the breaker, allow steps check the answer
+type step struct {
+	event     string
+	advance   time.Duration
+	wantAllow bool
+	wantWait  time.Duration
+}
+
+func TestBreaker(t *testing.T) {
+	const cooldown = 10 * time.Second
+	tests := []struct {
+		name      string
+		threshold int
+		steps     []step
+	}{
+		{
+			name:      "disabled",
+			threshold: 0,
+			steps: []step{
+				{event: "failure"}, {event: "failure"}, {event: "failure"},
+				{event: "allow", wantAllow: true},
+			},
+		},
+		{
+			name:      "closed below threshold",
+			threshold: 3,
+			steps: []step{
+				{event: "failure"}, {event: "failure"},
+				{event: "allow", wantAllow: true},
+				{event: "success"}, {event: "failure"}, {event: "failure"},
+				{event: "allow", wantAllow: true},
+			},
+		},
+		{
+			name:      "opens at threshold",
+			threshold: 2,
+			steps: []step{
+				{event: "failure"}, {event: "failure"},
+				{event: "allow", wantWait: cooldown},
+				{event: "advance", advance: 4 * time.Second},
+				{event: "allow", wantWait: 6 * time.Second},
+			},
+		},
+		{
+			name:      "half-open lets single probe",
+			threshold: 1,
+			steps: []step{
+				{event: "failure"},
+				{event: "advance", advance: cooldown},
+				{event: "allow", wantAllow: true},
+				{event: "allow", wantWait: cooldown},
+			},
+		},
+		{
+			name:      "probe success closes",
+			threshold: 1,
+			steps: []step{
+				{event: "failure"},
+				{event: "advance", advance: cooldown},
+				{event: "allow", wantAllow: true},
+				{event: "success"},
+				{event: "allow", wantAllow: true},
+				{event: "allow", wantAllow: true},
+			},
+		},
+		{
+			name:      "probe failure reopens",
+			threshold: 1,
+			steps: []step{
+				{event: "failure"},
+				{event: "advance", advance: cooldown},
+				{event: "allow", wantAllow: true},
+				{event: "failure"},
+				{event: "allow", wantWait: cooldown},
+			},
+		},
+		{
+			name:      "aborted probe is repeated",
+			threshold: 1,
+			steps: []step{
+				{event: "failure"},
+				{event: "advance", advance: cooldown},
+				{event: "allow", wantAllow: true},
+				{event: "abort"},
+				{event: "allow", wantAllow: true},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			b := NewBreaker(tt.threshold, cooldown)
+			b.now = func() time.Time { return now }
+
+			for i, s := range tt.steps {
+				switch s.event {
+				case "allow":
+					wait, ok := b.Allow()
+					if ok != s.wantAllow || wait != s.wantWait {
+						t.Errorf("step %d: Allow() = %v, %v, want %v, %v", i, wait, ok, s.wantWait, s.wantAllow)
+					}
+				case "success":
+					b.Success()
+				case "failure":
+					b.Failure()
+				case "abort":
+					b.Abort()
+				case "advance":
+					now = now.Add(s.advance)
+				}
+			}
+		})
+	}
+}
@@ -2,15 +2,15 @@ package agent
 
 import (
 	"context"
-	"encoding/json"
-	"io"
-	"net/http"
-	"strconv"
+	"errors"
+	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"go.uber.org/zap"
 
+	"lystem/internal/accrual"
 	"lystem/internal/config"
 	"lystem/internal/models/order"
 	"lystem/internal/request"
@@ -18,27 +18,93 @@ import (
 	"lystem/internal/usecase"
 )
 
+// AccrualClient asks accrual system about an order, see accrual.HTTPClient for the errors it returns
+type AccrualClient interface {
+	GetOrder(ctx context.Context, number string) (*request.GetOrderRequest, error)
+}
+
 type Agent struct {
 	storage           storage.Storage
+	client            AccrualClient
 	logger            *zap.SugaredLogger
 	waitBeforePoll    time.Duration
 	requestMaxRetries int
-	url               string
 	pollLimit         int
+	workers           int
+	instanceID        string
+	leaseTTL          time.Duration
+	retryPolicy       order.RetryPolicy
+	unregisteredGrace time.Duration
+
+	// queue feeds workers with orders, inFlight keeps numbers of queued and processing orders,
+	// so the next poll does not dispatch them twice
+	queue    chan order.Order
+	inFlight sync.Map
+
+	// pauseUntil is unix nano time till which all workers hold requests after 429 or open circuit breaker
+	pauseUntil atomic.Int64
+	metrics    counters
+}
+
+type counters struct {
+	processed atomic.Uint64
+	throttled atomic.Uint64
+	failed    atomic.Uint64
+}
+
+// Metrics is a snapshot of poller state
+type Metrics struct {
+	QueueDepth  int
+	InFlight    int
+	Workers     int
+	Processed   uint64
+	Throttled   uint64
+	Failed      uint64
+	PausedUntil time.Time
 }
 
-func New(db storage.Storage, options config.Config, logger *zap.Logger) *Agent {
+func New(db storage.Storage, client AccrualClient, options config.Config, logger *zap.Logger) *Agent {
+	workers := options.AccrualWorkers
+	if workers < 1 {
+		workers = 1
+	}
+	pollLimit := options.OrdersPollLimit
+	if pollLimit < 1 {
+		pollLimit = 1
+	}
+	// accrual system pushes results to callback, polling only reconciles missed ones
+	waitBeforePoll := options.PollInterval
+	if options.AccrualWebhookSecret != "" && options.ReconcileInterval > 0 {
+		waitBeforePoll = options.ReconcileInterval
+	}
+
 	return &Agent{
 		storage:           db,
 		logger:            logger.Sugar(),
-		waitBeforePoll:    options.PollInterval,
+		waitBeforePoll:    waitBeforePoll,
 		requestMaxRetries: options.RequestMaxRetries,
-		url:               options.AccrualSystemAddress,
-		pollLimit:         options.OrdersPollLimit,
+		client:            client,
+		pollLimit:         pollLimit,
+		workers:           workers,
+		instanceID:        options.InstanceID,
+		leaseTTL:          options.OrderLeaseTTL,
+		unregisteredGrace: options.UnregisteredOrderGrace,
+		retryPolicy: order.RetryPolicy{
+			MaxAttempts: options.AccrualMaxAttempts,
+			BaseDelay:   options.RetryBaseDelay,
+			MaxDelay:    options.RetryMaxDelay,
+		},
+		queue: make(chan order.Order, pollLimit),
 	}
 }
 
 func (p *Agent) StartOrdersPolling(ctx context.Context, wg *sync.WaitGroup) {
+	var workersWg sync.WaitGroup
+	for range p.workers {
+		workersWg.Add(1)
+		go p.work(ctx, &workersWg)
+	}
+
 	ordersTimer := time.NewTimer(p.waitBeforePoll)
 
 	for {
@@ -49,83 +115,237 @@ func (p *Agent) StartOrdersPolling(ctx context.Context, wg *sync.WaitGroup) {
 		case <-ctx.Done():
 			p.logger.Info("4 Gracefully stop orders timer")
 			ordersTimer.Stop()
+			close(p.queue)
+			workersWg.Wait()
+			p.releaseClaims()
 			wg.Done()
 			return
 		}
 	}
 }
 
+func (p *Agent) Metrics() Metrics {
+	var inFlight int
+	p.inFlight.Range(func(_, _ any) bool {
+		inFlight++
+		return true
+	})
+
+	m := Metrics{
+		QueueDepth: len(p.queue),
+		InFlight:   inFlight,
+		Workers:    p.workers,
+		Processed:  p.metrics.processed.Load(),
+		Throttled:  p.metrics.throttled.Load(),
+		Failed:     p.metrics.failed.Load(),
+	}
+	if until := p.pauseUntil.Load(); until > time.Now().UnixNano() {
+		m.PausedUntil = time.Unix(0, until)
+	}
+	return m
+}
+
+// PollOrdersInfo selects unprocessed orders and hands them over to workers
 func (p *Agent) PollOrdersInfo(ctx context.Context) {
 	orderUsecase := usecase.NewOrderUsecase(p.storage)
-	orders, err := orderUsecase.SelectUnprocessed(ctx, p.pollLimit)
+	orders, err := orderUsecase.ClaimUnprocessed(ctx, p.instanceID, p.pollLimit, p.leaseTTL)
 	if err != nil {
 		p.logger.Warn("failed to find orders", "error", err)
 	}
 
 	if len(orders) == 0 {
 		p.logger.Info("no orders to request")
-		// wait to collect to some orders to request
-		time.Sleep(p.waitBeforePoll)
 		return
 	}
 
 	for _, o := range orders {
-		p.GetOneOrderInfo(ctx, &o, 0)
+		if _, loaded := p.inFlight.LoadOrStore(o.Number, struct{}{}); loaded {
+			continue
+		}
+
+		select {
+		case p.queue <- o:
+		case <-ctx.Done():
+			p.inFlight.Delete(o.Number)
+			return
+		}
+	}
+
+	m := p.Metrics()
+	p.logger.Infow("orders dispatched", "queue_depth", m.QueueDepth, "in_flight", m.InFlight, "workers", m.Workers)
+}
+
+// releaseClaims hands unfinished orders over to other instances right away instead of waiting for lease expiration
+func (p *Agent) releaseClaims() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	orderUsecase := usecase.NewOrderUsecase(p.storage)
+	if err := orderUsecase.ReleaseClaims(ctx, p.instanceID); err != nil {
+		p.logger.Errorw("failed to release order claims", "error", err)
+	}
+}
+
+func (p *Agent) work(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	// queue is closed on shutdown, orders left in it are picked up by the next run
+	for o := range p.queue {
+		if ctx.Err() == nil {
+			p.GetOneOrderInfo(ctx, &o, 0)
+		}
+		p.inFlight.Delete(o.Number)
+	}
+}
+
+var (
+	// ErrPaused is returned by Recheck while requests to accrual system are held after 429 or open circuit breaker
+	ErrPaused = errors.New("запросы к системе расчёта баллов приостановлены, повторите позже")
+	// ErrThrottled is returned by Recheck when accrual system answered 429 to the request itself
+	ErrThrottled = errors.New("система расчёта баллов ограничила запросы, повторите позже")
+	// ErrCheckFailed is returned by Recheck when accrual system did not answer, the order is checked later by schedule
+	ErrCheckFailed = errors.New("система расчёта баллов не ответила, заказ будет проверен позже")
+)
+
+// Recheck asks accrual system about the order right away, out of poll schedule.
+// It does not wait for the pause and does not repeat throttled request, the caller may try again later.
+// The error tells why the order was not checked.
+func (p *Agent) Recheck(ctx context.Context, o *order.Order) error {
+	if time.Now().UnixNano() < p.pauseUntil.Load() {
+		return ErrPaused
+	}
+
+	err := p.fetchOrderInfo(ctx, o)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, accrual.ErrBreakerOpen):
+		return ErrPaused
+	case errors.Is(err, accrual.ErrTooManyRequests):
+		return ErrThrottled
+	case ctx.Err() != nil:
+		return err
+	default:
+		return fmt.Errorf("%w: %v", ErrCheckFailed, err)
 	}
 }
 
 func (p *Agent) GetOneOrderInfo(ctx context.Context, o *order.Order, retryCount int) {
-	if ctx.Err() != nil {
+	if !p.waitPause(ctx) {
 		return
 	}
 
-	resp, err := http.Get(p.url + "/api/orders/" + o.Number)
-	if err != nil {
-		p.logger.Error("failed to get order", "error", err)
+	var retryLater *accrual.RetryLaterError
+	if err := p.fetchOrderInfo(ctx, o); !errors.As(err, &retryLater) {
+		return
+	}
+	if retryCount < p.requestMaxRetries {
+		p.GetOneOrderInfo(ctx, o, retryCount+1)
 		return
 	}
 
-	switch resp.StatusCode {
-	case http.StatusOK:
-		p.saveOkOrder(ctx, o, resp.Body)
-	case http.StatusTooManyRequests:
-		if retryCount >= p.requestMaxRetries {
-			return
-		}
+	// give the order up till the pause ends instead of holding the claim till lease expiration
+	ordersUsecase := usecase.NewOrderUsecase(p.storage)
+	if err := ordersUsecase.Throttle(ctx, o, retryLater.RetryAfter); err != nil {
+		p.logger.Errorw("failed to postpone throttled order", "number", o.Number, "error", err)
+	}
+}
 
-		sleepTime := p.waitBeforePoll
-		retryAfterStr := resp.Header.Get("Retry-After")
-		if retryAfter, err := strconv.Atoi(retryAfterStr); err == nil {
-			sleepTime = time.Duration(retryAfter) * time.Second
-		}
+// fetchOrderInfo makes one request to accrual system and stores the outcome. It returns nil
+// if the order was updated from the answer, otherwise the error of the request.
+func (p *Agent) fetchOrderInfo(ctx context.Context, o *order.Order) error {
+	orderInfo, err := p.client.GetOrder(ctx, o.Number)
+	if ctx.Err() != nil {
+		// shutdown, the order is released and picked up by the next run
+		return ctx.Err()
+	}
 
-		time.Sleep(sleepTime)
-		p.GetOneOrderInfo(ctx, o, retryCount+1)
-	case http.StatusInternalServerError:
-		time.Sleep(p.waitBeforePoll)
-		p.GetOneOrderInfo(ctx, o, retryCount+1)
+	var retryLater *accrual.RetryLaterError
+	switch {
+	case err == nil:
+		p.saveOkOrder(ctx, o, orderInfo)
+		p.metrics.processed.Add(1)
+	case errors.Is(err, accrual.ErrNotRegistered):
+		p.handleUnregistered(ctx, o)
+	case errors.As(err, &retryLater):
+		p.metrics.throttled.Add(1)
+		// accrual system limits all of our requests or is down, so every worker holds on
+		p.pause(retryLater.RetryAfter)
+		return err
+	default:
+		// network errors, timeouts, 500, malformed answers and any other unexpected code
+		p.logger.Errorw("failed to get order", "number", o.Number, "error", err)
+		p.scheduleRetry(ctx, o, err)
+		return err
 	}
+	return nil
+}
 
-	if err = resp.Body.Close(); err != nil {
-		p.logger.Error("failed to close response body", "error", err)
+// handleUnregistered keeps checking order unknown to accrual system until grace period since upload ends,
+// then the order is considered invalid. Checks are spaced by retry backoff, not made every poll.
+func (p *Agent) handleUnregistered(ctx context.Context, o *order.Order) {
+	if graceEnd := o.UploadedAt.Add(p.unregisteredGrace); time.Now().Before(graceEnd) {
+		ordersUsecase := usecase.NewOrderUsecase(p.storage)
+		if err := ordersUsecase.PostponeCheck(ctx, o, p.retryPolicy, graceEnd); err != nil {
+			p.logger.Errorw("failed to postpone order check", "number", o.Number, "error", err)
+		}
+		return
 	}
+
+	p.logger.Infow("order is not registered in accrual system, marking invalid", "number", o.Number)
+	p.saveInvalidOrder(ctx, o)
 }
 
-func (p *Agent) saveOkOrder(ctx context.Context, o *order.Order, respBody io.ReadCloser) {
-	ordersUsecase := usecase.NewOrderUsecase(p.storage)
+// scheduleRetry postpones the order with exponential backoff, persisted so other instances and restarts honor it
+func (p *Agent) scheduleRetry(ctx context.Context, o *order.Order, cause error) {
+	p.metrics.failed.Add(1)
 
-	var orderInfo request.GetOrderRequest
-	if err := json.NewDecoder(respBody).Decode(&orderInfo); err != nil {
-		p.logger.Error("failed to decode order", "error", err)
+	ordersUsecase := usecase.NewOrderUsecase(p.storage)
+	if err := ordersUsecase.ScheduleRetry(ctx, o, cause, p.retryPolicy); err != nil {
+		p.logger.Errorw("failed to schedule order retry", "number", o.Number, "error", err)
 		return
 	}
+	if o.Status == order.StatusStuck {
+		p.logger.Warnw("order is stuck", "number", o.Number, "attempts", o.Attempts, "last_error", o.LastError)
+	}
+}
 
-	o.Status = orderInfo.Status
-	if orderInfo.Accrual > 0 {
-		o.Accrual = orderInfo.Accrual
+// pause holds all workers for d, longer pause already set is kept
+func (p *Agent) pause(d time.Duration) {
+	until := time.Now().Add(d).UnixNano()
+	for {
+		current := p.pauseUntil.Load()
+		if current >= until || p.pauseUntil.CompareAndSwap(current, until) {
+			return
+		}
 	}
+}
 
-	if err := ordersUsecase.Update(ctx, o); err != nil {
+// waitPause blocks while global pause is on, returns false if ctx is done
+func (p *Agent) waitPause(ctx context.Context) bool {
+	wait := time.Until(time.Unix(0, p.pauseUntil.Load()))
+	if wait <= 0 {
+		return ctx.Err() == nil
+	}
+	return sleep(ctx, wait)
+}
+
+// sleep waits for d, returns false if ctx is done earlier
+func sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (p *Agent) saveOkOrder(ctx context.Context, o *order.Order, orderInfo *request.GetOrderRequest) {
+	ordersUsecase := usecase.NewOrderUsecase(p.storage)
+	if err := ordersUsecase.ApplyAccrual(ctx, o, orderInfo); err != nil {
 		p.logger.Error("failed to update order", "error", err)
 	}
 }
@@ -0,0 +1,209 @@
+package agent_test
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"lystem/internal/accrual"
+	"lystem/internal/agent"
+	"lystem/internal/config"
+	"lystem/internal/models/money"
+	"lystem/internal/models/order"
+	"lystem/internal/models/user"
+	"lystem/pkg/accrualstub"
+	"lystem/pkg/memory"
+)
+
+// startPolling runs the poller against stub server till the end of the test
+func startPolling(t *testing.T, db *memory.MemStorage, rules accrualstub.Rules) (*agent.Agent, *accrualstub.Stub) {
+	t.Helper()
+
+	stub, server := accrualstub.NewTestServer(rules)
+	t.Cleanup(server.Close)
+
+	options := config.Config{
+		PollInterval:           10 * time.Millisecond,
+		OrdersPollLimit:        10,
+		AccrualWorkers:         4,
+		InstanceID:             "test",
+		OrderLeaseTTL:          time.Minute,
+		RetryBaseDelay:         time.Minute,
+		RetryMaxDelay:          time.Hour,
+		UnregisteredOrderGrace: time.Hour,
+	}
+	client := accrual.NewHTTPClient(server.URL, accrual.Options{Timeout: time.Second, BreakerThreshold: 100, BreakerCooldown: time.Second})
+	a := agent.New(db, client, options, zap.NewNop())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go a.StartOrdersPolling(ctx, &wg)
+	t.Cleanup(func() {
+		cancel()
+		wg.Wait()
+	})
+	return a, stub
+}
+
+func uploadOrders(t *testing.T, db *memory.MemStorage, numbers ...string) *user.User {
+	t.Helper()
+
+	ctx := context.Background()
+	u, err := db.CreateUser(ctx, &user.User{Login: "u-" + uuid.NewString(), HashedPassword: "-"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, number := range numbers {
+		if _, err = db.SaveOrder(ctx, number, u.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return u
+}
+
+// waitFor polls cond till it holds or timeout passes
+func waitFor(t *testing.T, timeout time.Duration, cond func() bool) bool {
+	t.Helper()
+
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return true
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return cond()
+}
+
+func findOrder(t *testing.T, db *memory.MemStorage, number string) *order.Order {
+	t.Helper()
+
+	o, err := db.FindOrderByNumber(context.Background(), number)
+	if err != nil || o == nil {
+		t.Fatalf("find order %s: %v, %v", number, o, err)
+	}
+	return o
+}
+
+func TestPollerProcessesScriptedOrder(t *testing.T) {
+	const number = "12345678903"
+	db := memory.NewStorage()
+	u := uploadOrders(t, db, number)
+	_, stub := startPolling(t, db, accrualstub.Rules{})
+	stub.Progress(number, 729.98)
+
+	if !waitFor(t, 5*time.Second, func() bool { return findOrder(t, db, number).Status == order.StatusProcessed }) {
+		t.Fatalf("order status %s, want %s", findOrder(t, db, number).Status, order.StatusProcessed)
+	}
+
+	o := findOrder(t, db, number)
+	if o.Accrual != money.Money(72998) {
+		t.Errorf("accrual = %s, want 729.98", o.Accrual)
+	}
+	if got := stub.Requests(number); got != 3 {
+		t.Errorf("stub answered %d requests, want 3", got)
+	}
+
+	history, err := db.FindOrderStatusHistory(context.Background(), o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var statuses []string
+	for _, change := range history {
+		statuses = append(statuses, change.To)
+	}
+	for _, want := range []string{order.StatusRegistered, order.StatusProcessing, order.StatusProcessed} {
+		if !slices.Contains(statuses, want) {
+			t.Errorf("status history %v has no %s", statuses, want)
+		}
+	}
+
+	b, err := db.FindBalance(context.Background(), u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Current != money.Money(72998) {
+		t.Errorf("balance = %s, want 729.98", b.Current)
+	}
+}
+
+func TestPollerKeepsUnregisteredOrderNew(t *testing.T) {
+	const number = "9278923470"
+	db := memory.NewStorage()
+	u := uploadOrders(t, db, number)
+	startPolling(t, db, accrualstub.Rules{})
+
+	if !waitFor(t, 5*time.Second, func() bool { return !findOrder(t, db, number).CheckedAt.IsZero() }) {
+		t.Fatal("order was not checked")
+	}
+
+	o := findOrder(t, db, number)
+	if o.Status != order.StatusNew {
+		t.Errorf("status = %s, want %s", o.Status, order.StatusNew)
+	}
+	if o.Attempts != 0 {
+		t.Errorf("attempts = %d, 204 is not a failure", o.Attempts)
+	}
+	if !o.NextAttemptAt.After(time.Now()) {
+		t.Errorf("next attempt at %v is not postponed", o.NextAttemptAt)
+	}
+
+	b, err := db.FindBalance(context.Background(), u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Current != 0 {
+		t.Errorf("balance = %s, want 0", b.Current)
+	}
+}
+
+func TestPollerPausesAllWorkersAfterTooManyRequests(t *testing.T) {
+	numbers := []string{"12345678903", "9278923470", "346436439", "79927398713", "4561261212345467", "2377225624", "49927398716", "1234567812345670"}
+	db := memory.NewStorage()
+	uploadOrders(t, db, numbers...)
+	a, stub := startPolling(t, db, accrualstub.Rules{RetryAfter: 1})
+	stub.Throttle(1)
+	for _, number := range numbers {
+		stub.Script(number, accrualstub.Step{Status: accrualstub.StatusProcessed, Accrual: 10})
+	}
+
+	if !waitFor(t, 5*time.Second, func() bool { return a.Metrics().Throttled == 1 }) {
+		t.Fatal("poller was not throttled")
+	}
+	pausedUntil := a.Metrics().PausedUntil
+	if wait := time.Until(pausedUntil); wait <= 0 || wait > time.Second {
+		t.Fatalf("paused until %v, want about a second from now", pausedUntil)
+	}
+
+	answered := func() int {
+		var total int
+		for _, number := range numbers {
+			total += stub.Requests(number)
+		}
+		return total
+	}
+	// requests sent before the pause may still be answered
+	time.Sleep(50 * time.Millisecond)
+	before := answered()
+	time.Sleep(time.Until(pausedUntil) - 100*time.Millisecond)
+	if after := answered(); after != before {
+		t.Errorf("stub answered %d requests during the pause", after-before)
+	}
+
+	if !waitFor(t, 5*time.Second, func() bool {
+		for _, number := range numbers {
+			if findOrder(t, db, number).Status != order.StatusProcessed {
+				return false
+			}
+		}
+		return true
+	}) {
+		t.Error("not all orders are processed after the pause")
+	}
+}
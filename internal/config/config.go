@@ -1,12 +1,15 @@
 package config
 
 import (
+	"errors"
 	"flag"
-	"log"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/caarlos0/env/v6"
+
+	"lystem/internal/models/money"
 )
 
 type Config struct {
@@ -15,50 +18,168 @@ type Config struct {
 	AccrualSystemAddress string `env:"ACCRUAL_SYSTEM_ADDRESS"`
 	RequestMaxRetries    int
 	PollInterval         time.Duration
-	UserSalt             string `env:"USER_SALT"`
-	OrdersPollLimit      int
+	// UserSalt is the global salt of SHA-512 password hashes made before argon2id,
+	// it is needed only to let such users log in once, then their hash is upgraded
+	UserSalt        string `env:"USER_SALT"`
+	OrdersPollLimit int
+	AccrualWorkers  int `env:"ACCRUAL_WORKERS"`
+	// Idempotency-Key is replayed for IdempotencyKeyTTL, the key of request not answered
+	// within IdempotencyLockTTL may be used again
+	IdempotencyKeyTTL  time.Duration `env:"IDEMPOTENCY_KEY_TTL"`
+	IdempotencyLockTTL time.Duration `env:"IDEMPOTENCY_LOCK_TTL"`
+	// InstanceID tells poller replicas apart when they claim orders
+	InstanceID    string        `env:"INSTANCE_ID"`
+	OrderLeaseTTL time.Duration `env:"ORDER_LEASE_TTL"`
+	// failed accrual requests are retried with exponential backoff from RetryBaseDelay up to RetryMaxDelay,
+	// after AccrualMaxAttempts the order is marked as STUCK
+	AccrualMaxAttempts int           `env:"ACCRUAL_MAX_ATTEMPTS"`
+	RetryBaseDelay     time.Duration `env:"RETRY_BASE_DELAY"`
+	RetryMaxDelay      time.Duration `env:"RETRY_MAX_DELAY"`
+	// order unknown to accrual system (204) is re-checked during this period since upload and then marked INVALID
+	UnregisteredOrderGrace time.Duration `env:"UNREGISTERED_ORDER_GRACE"`
+	// accrual client limits every request by AccrualRequestTimeout and stops calling accrual system
+	// for BreakerCooldown after BreakerThreshold failures in a row
+	AccrualRequestTimeout time.Duration `env:"ACCRUAL_REQUEST_TIMEOUT"`
+	AccrualMaxIdleConns   int           `env:"ACCRUAL_MAX_IDLE_CONNS"`
+	BreakerThreshold      int           `env:"BREAKER_THRESHOLD"`
+	BreakerCooldown       time.Duration `env:"BREAKER_COOLDOWN"`
+	// with AccrualWebhookSecret set accrual system may push order updates to /internal/accrual/callback,
+	// then orders are polled every ReconcileInterval instead of PollInterval
+	AccrualWebhookSecret string        `env:"ACCRUAL_WEBHOOK_SECRET"`
+	ReconcileInterval    time.Duration `env:"RECONCILE_INTERVAL"`
+	// session stops authorizing requests after SessionIdleTTL without requests and can be refreshed
+	// until SessionTTL since login; sessions past SessionTTL, expired idempotency keys and login attempts
+	// are deleted every SessionCleanupInterval
+	SessionTTL             time.Duration `env:"SESSION_TTL"`
+	SessionIdleTTL         time.Duration `env:"SESSION_IDLE_TTL"`
+	SessionCleanupInterval time.Duration `env:"SESSION_CLEANUP_INTERVAL"`
+	// with AccessTokenKeys set ("kid:secret,..." - the first key signs, all of them verify) clients also get
+	// signed access tokens valid for AccessTokenTTL, which are authorized without the storage
+	AccessTokenKeys string        `env:"ACCESS_TOKEN_KEYS"`
+	AccessTokenTTL  time.Duration `env:"ACCESS_TOKEN_TTL"`
+	// every failed login delays the next attempt for the login, doubling from LoginBaseDelay
+	// up to LoginMaxDelay; LoginMaxFailures per login or LoginIPMaxFailures per address within LoginFailureWindow
+	// lock further attempts for LoginLockout; audit records of attempts and used password resets
+	// are kept for LoginAttemptsRetention
+	LoginMaxFailures       int           `env:"LOGIN_MAX_FAILURES"`
+	LoginIPMaxFailures     int           `env:"LOGIN_IP_MAX_FAILURES"`
+	LoginFailureWindow     time.Duration `env:"LOGIN_FAILURE_WINDOW"`
+	LoginLockout           time.Duration `env:"LOGIN_LOCKOUT"`
+	LoginBaseDelay         time.Duration `env:"LOGIN_BASE_DELAY"`
+	LoginMaxDelay          time.Duration `env:"LOGIN_MAX_DELAY"`
+	LoginAttemptsRetention time.Duration `env:"LOGIN_ATTEMPTS_RETENTION"`
+	// password reset tokens are valid for PasswordResetTTL and are written as JSON lines
+	// to NotificationsFile, stdout if it is not set
+	PasswordResetTTL  time.Duration `env:"PASSWORD_RESET_TTL"`
+	NotificationsFile string        `env:"NOTIFICATIONS_FILE"`
+	// manual balance adjustments above AdjustmentApprovalThreshold points wait for approval of another admin,
+	// zero applies all of them right away
+	AdjustmentApprovalThreshold money.Money `env:"ADJUSTMENT_APPROVAL_THRESHOLD"`
+	// Command is positional arguments left after flags, e.g. ["migrate", "up"]
+	Command []string
 }
 
 const (
-	hostDefault            = "localhost"
-	portDefault            = "8080"
-	defaultUserSalt        = "HavuDuUdoMrPron'ka"
-	defaultOrdersPollLimit = 100
+	hostDefault              = "localhost"
+	portDefault              = "8080"
+	defaultUserSalt          = "HavuDuUdoMrPron'ka"
+	defaultOrdersPollLimit   = 100
+	defaultAccrualWorkers    = 4
+	defaultOrderLeaseTTL     = time.Minute
+	defaultMaxAttempts       = 10
+	defaultRetryBaseDelay    = 5 * time.Second
+	defaultRetryMaxDelay     = 30 * time.Minute
+	defaultUnregisteredGrace = 24 * time.Hour
+	defaultIdempotencyTTL    = 24 * time.Hour
+	defaultIdempotencyLock   = time.Minute
+	defaultRequestTimeout    = 5 * time.Second
+	defaultMaxIdleConns      = 16
+	defaultBreakerThreshold  = 5
+	defaultBreakerCooldown   = 30 * time.Second
+	defaultReconcileInterval = time.Minute
+	defaultSessionTTL        = 30 * 24 * time.Hour
+	defaultSessionIdleTTL    = 24 * time.Hour
+	defaultSessionCleanup    = 10 * time.Minute
+	defaultAccessTokenTTL    = 15 * time.Minute
+	defaultLoginMaxFailures  = 5
+	defaultIPMaxFailures     = 20
+	defaultFailureWindow     = 15 * time.Minute
+	defaultLoginLockout      = 15 * time.Minute
+	defaultLoginBaseDelay    = time.Second
+	defaultLoginMaxDelay     = 30 * time.Second
+	defaultAttemptsRetention = 30 * 24 * time.Hour
+	defaultPasswordResetTTL  = 30 * time.Minute
 )
 
 var Options = Config{
-	Address:              hostDefault + ":" + portDefault,
-	DatabaseURI:          "",
-	AccrualSystemAddress: "",
-	RequestMaxRetries:    3,
-	PollInterval:         3 * time.Second,
-	UserSalt:             defaultUserSalt,
-	OrdersPollLimit:      defaultOrdersPollLimit,
+	Address:                hostDefault + ":" + portDefault,
+	DatabaseURI:            "",
+	AccrualSystemAddress:   "",
+	RequestMaxRetries:      3,
+	PollInterval:           3 * time.Second,
+	UserSalt:               defaultUserSalt,
+	OrdersPollLimit:        defaultOrdersPollLimit,
+	AccrualWorkers:         defaultAccrualWorkers,
+	InstanceID:             defaultInstanceID(),
+	OrderLeaseTTL:          defaultOrderLeaseTTL,
+	AccrualMaxAttempts:     defaultMaxAttempts,
+	RetryBaseDelay:         defaultRetryBaseDelay,
+	RetryMaxDelay:          defaultRetryMaxDelay,
+	UnregisteredOrderGrace: defaultUnregisteredGrace,
+	IdempotencyKeyTTL:      defaultIdempotencyTTL,
+	IdempotencyLockTTL:     defaultIdempotencyLock,
+	AccrualRequestTimeout:  defaultRequestTimeout,
+	AccrualMaxIdleConns:    defaultMaxIdleConns,
+	BreakerThreshold:       defaultBreakerThreshold,
+	BreakerCooldown:        defaultBreakerCooldown,
+	ReconcileInterval:      defaultReconcileInterval,
+	SessionTTL:             defaultSessionTTL,
+	SessionIdleTTL:         defaultSessionIdleTTL,
+	SessionCleanupInterval: defaultSessionCleanup,
+	AccessTokenTTL:         defaultAccessTokenTTL,
+	LoginMaxFailures:       defaultLoginMaxFailures,
+	LoginIPMaxFailures:     defaultIPMaxFailures,
+	LoginFailureWindow:     defaultFailureWindow,
+	LoginLockout:           defaultLoginLockout,
+	LoginBaseDelay:         defaultLoginBaseDelay,
+	LoginMaxDelay:          defaultLoginMaxDelay,
+	LoginAttemptsRetention: defaultAttemptsRetention,
+	PasswordResetTTL:       defaultPasswordResetTTL,
 }
 
-func init() {
-	parseFlags()
+// Load fills Options from command line flags and environment, environment wins
+func Load() error {
+	if err := parseFlags(); err != nil {
+		return err
+	}
 	if err := env.Parse(&Options); err != nil {
-		log.Fatal(err)
+		return err
 	}
 
-	if Options.DatabaseURI == "" {
-		log.Fatal("Error: database URI is required")
+	if Options.AccrualSystemAddress == "" && len(Options.Command) == 0 {
+		return errors.New("accrual system address is required")
 	}
-	if Options.AccrualSystemAddress == "" {
-		log.Fatal("Error: accrual system address is required")
+	return nil
+}
+
+func defaultInstanceID() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "gophermart"
 	}
+	return hostname + "-" + strconv.Itoa(os.Getpid())
 }
 
-func parseFlags() {
+func parseFlags() error {
 	fs := flag.NewFlagSet("loystem", flag.ContinueOnError)
 	fs.StringVar(&Options.Address, "a", hostDefault+":"+portDefault, "server address to run on")
 	fs.StringVar(&Options.DatabaseURI, "d", "", "database source name")
 	fs.StringVar(&Options.AccrualSystemAddress, "r", "", "accrual system address")
 	fs.StringVar(&Options.UserSalt, "s", "", "salt to register user")
 
-	err := fs.Parse(os.Args[1:])
-	if err != nil {
-		log.Fatal(err)
+	if err := fs.Parse(os.Args[1:]); err != nil {
+		return err
 	}
+	Options.Command = fs.Args()
+	return nil
 }
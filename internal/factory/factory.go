@@ -5,18 +5,18 @@ import (
 	"lystem/internal/request"
 )
 
-type UserFactory struct {
-	salt string
-}
+type UserFactory struct{}
 
-func NewUserFactory(salt string) *UserFactory {
-	return &UserFactory{salt}
+func NewUserFactory() *UserFactory {
+	return &UserFactory{}
 }
 
 func (u *UserFactory) Build(userReq request.CreateUser) (*user.User, error) {
 	var newUser user.User
 
 	newUser.Login = userReq.Login
-	newUser.SetHashedPassword(userReq.Password, u.salt)
+	if err := newUser.SetPassword(userReq.Password); err != nil {
+		return nil, err
+	}
 	return &newUser, nil
 }
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+
+	"lystem/internal/models/order"
+	"lystem/internal/presenter"
+	"lystem/internal/request"
+	"lystem/internal/usecase"
+)
+
+var errEmptyOrderNumber = errors.New("не указан номер заказа")
+
+// AccrualCallback godoc
+//
+//	@Summary		Приём результата расчёта начислений от системы расчёта баллов
+//	@Tags			Система расчёта баллов
+//	@Accept			application/json
+//	@Produce		application/json
+//	@Param			X-Accrual-Timestamp	header		string	true	"время запроса, unix секунды"
+//	@Param			X-Accrual-Signature	header		string	true	"hex HMAC-SHA256 от <timestamp>.<body>"
+//	@Param			payload				body		request.GetOrderRequest
+//	@Success		200					{string}	json	"результат принят"
+//	@Failure		400					{string}	error	"неверный формат запроса"
+//	@Failure		401					{string}	error	"неверная подпись запроса"
+//	@Failure		404					{string}	error	"заказ не найден"
+//	@Failure		409					{string}	error	"недопустимая смена статуса заказа"
+//	@Failure		500					{string}	error	"внутренняя ошибка сервера"
+//	@Router			/internal/accrual/callback	[post]
+func (v1 v1Handler) AccrualCallback(ctx *fiber.Ctx) error {
+	var orderInfo request.GetOrderRequest
+	if err := json.Unmarshal(ctx.Body(), &orderInfo); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(presenter.NewFailure(err))
+	}
+	if orderInfo.Order == "" {
+		return ctx.Status(fiber.StatusBadRequest).JSON(presenter.NewFailure(errEmptyOrderNumber))
+	}
+	if err := orderInfo.Validate(orderInfo.Order); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(presenter.NewFailure(err))
+	}
+
+	orderUsecase := usecase.NewOrderUsecase(v1.storage)
+	foundOrder, err := orderUsecase.FindByNumber(ctx.Context(), orderInfo.Order)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(presenter.NewFailure(err))
+	}
+	if foundOrder == nil {
+		return ctx.Status(fiber.StatusNotFound).JSON(presenter.NewFailure(errOrderNotFound))
+	}
+
+	// repeated delivery is harmless: staying in the same status is not a transition
+	if err = orderUsecase.ApplyAccrual(ctx.Context(), foundOrder, &orderInfo); err != nil {
+		if errors.Is(err, order.ErrIllegalTransition) {
+			return ctx.Status(fiber.StatusConflict).JSON(presenter.NewFailure(err))
+		}
+		return ctx.Status(fiber.StatusInternalServerError).JSON(presenter.NewFailure(err))
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(presenter.NewSuccess(nil))
+}
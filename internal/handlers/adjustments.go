@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"lystem/internal/models/adjustment"
+	"lystem/internal/models/user"
+	"lystem/internal/presenter"
+	"lystem/internal/request"
+	"lystem/internal/storage"
+	"lystem/internal/usecase"
+)
+
+// CreateAdjustment godoc
+//
+//	@Summary		Ручное начисление или списание баллов пользователю
+//	@Description	Корректировка больше ADJUSTMENT_APPROVAL_THRESHOLD ждёт подтверждения другого администратора
+//	@Tags			Корректировки
+//	@Accept			application/json
+//	@Produce		application/json
+//	@Param			login	path		string	true	"логин пользователя"
+//	@Param			payload	body		request.CreateAdjustment
+//	@Success		201		{string}	json	"корректировка применена"
+//	@Success		202		{string}	json	"корректировка ждёт подтверждения"
+//	@Failure		400		{string}	error	"неверный формат запроса"
+//	@Failure		401		{string}	error	"пользователь не аутентифицирован"
+//	@Failure		403		{string}	error	"недостаточно прав или корректировка собственного баланса"
+//	@Failure		404		{string}	error	"пользователь не найден"
+//	@Failure		409		{string}	error	"сумма списания больше текущего баланса"
+//	@Failure		500		{string}	error	"внутренняя ошибка сервера"
+//	@Router			/api/admin/users/{login}/adjustments	[post]
+func (v1 v1Handler) CreateAdjustment(ctx *fiber.Ctx) error {
+	currentUser := ctx.Locals("current_user").(*user.User)
+
+	var adjustmentRequest request.CreateAdjustment
+	if err := ctx.BodyParser(&adjustmentRequest); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(presenter.NewFailure(err))
+	}
+	if err := adjustmentRequest.Validate(); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(presenter.NewFailure(err))
+	}
+
+	targetUser, err := v1.findTargetUser(ctx)
+	if err != nil {
+		return respondUserLookupError(ctx, err)
+	}
+
+	adjustmentUsecase := usecase.NewAdjustmentUsecase(v1.storage, v1.adjustmentPolicy)
+	created, err := adjustmentUsecase.Create(ctx.Context(), currentUser, targetUser, adjustmentRequest)
+	if err != nil && errors.Is(err, usecase.ErrSelfAdjustment) {
+		return ctx.Status(fiber.StatusForbidden).JSON(presenter.NewFailure(err))
+	} else if err != nil && errors.Is(err, usecase.ErrAdjustmentOverdraft) {
+		return ctx.Status(fiber.StatusConflict).JSON(presenter.NewFailure(err))
+	} else if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(presenter.NewFailure(err))
+	}
+
+	status := fiber.StatusCreated
+	if created.Status == adjustment.StatusPending {
+		status = fiber.StatusAccepted
+	}
+	return ctx.Status(status).JSON(presenter.NewAdjustmentResponse(created))
+}
+
+// GetUserAdjustments godoc
+//
+//	@Summary		Получение корректировок баланса пользователя
+//	@Tags			Корректировки
+//	@Produce		application/json
+//	@Param			login	path		string	true	"логин пользователя"
+//	@Success		200		{string}	json	"успешная обработка запроса"
+//	@Success		204		{string}	json	"нет ни одной корректировки"
+//	@Failure		401		{string}	error	"пользователь не аутентифицирован"
+//	@Failure		403		{string}	error	"недостаточно прав"
+//	@Failure		404		{string}	error	"пользователь не найден"
+//	@Failure		500		{string}	error	"внутренняя ошибка сервера"
+//	@Router			/api/admin/users/{login}/adjustments	[get]
+func (v1 v1Handler) GetUserAdjustments(ctx *fiber.Ctx) error {
+	targetUser, err := v1.findTargetUser(ctx)
+	if err != nil {
+		return respondUserLookupError(ctx, err)
+	}
+
+	return v1.listAdjustments(ctx, adjustment.Filter{UserID: targetUser.ID})
+}
+
+// GetAdjustments godoc
+//
+//	@Summary		Получение корректировок баланса всех пользователей
+//	@Tags			Корректировки
+//	@Produce		application/json
+//	@Param			status	query		string	false	"PENDING, APPLIED или REJECTED"
+//	@Success		200		{string}	json	"успешная обработка запроса"
+//	@Success		204		{string}	json	"нет ни одной корректировки"
+//	@Failure		400		{string}	error	"неизвестный статус корректировки"
+//	@Failure		401		{string}	error	"пользователь не аутентифицирован"
+//	@Failure		403		{string}	error	"недостаточно прав"
+//	@Failure		500		{string}	error	"внутренняя ошибка сервера"
+//	@Router			/api/admin/adjustments	[get]
+func (v1 v1Handler) GetAdjustments(ctx *fiber.Ctx) error {
+	return v1.listAdjustments(ctx, adjustment.Filter{Status: strings.ToUpper(ctx.Query("status"))})
+}
+
+// ApproveAdjustment godoc
+//
+//	@Summary		Подтверждение корректировки баланса, баллы начисляются или списываются
+//	@Tags			Корректировки
+//	@Produce		application/json
+//	@Param			id	path		int		true	"номер корректировки"
+//	@Success		200	{string}	json	"корректировка применена"
+//	@Failure		401	{string}	error	"пользователь не аутентифицирован"
+//	@Failure		403	{string}	error	"недостаточно прав, корректировка создана этим же пользователем или касается его баланса"
+//	@Failure		404	{string}	error	"корректировка не найдена"
+//	@Failure		409	{string}	error	"корректировка уже рассмотрена или сумма списания больше баланса"
+//	@Failure		500	{string}	error	"внутренняя ошибка сервера"
+//	@Router			/api/admin/adjustments/{id}/approve	[post]
+func (v1 v1Handler) ApproveAdjustment(ctx *fiber.Ctx) error {
+	adjustmentUsecase := usecase.NewAdjustmentUsecase(v1.storage, v1.adjustmentPolicy)
+	return v1.decideAdjustment(ctx, adjustmentUsecase.Approve)
+}
+
+// RejectAdjustment godoc
+//
+//	@Summary		Отклонение корректировки баланса
+//	@Tags			Корректировки
+//	@Produce		application/json
+//	@Param			id	path		int		true	"номер корректировки"
+//	@Success		200	{string}	json	"корректировка отклонена"
+//	@Failure		401	{string}	error	"пользователь не аутентифицирован"
+//	@Failure		403	{string}	error	"недостаточно прав"
+//	@Failure		404	{string}	error	"корректировка не найдена"
+//	@Failure		409	{string}	error	"корректировка уже рассмотрена"
+//	@Failure		500	{string}	error	"внутренняя ошибка сервера"
+//	@Router			/api/admin/adjustments/{id}/reject	[post]
+func (v1 v1Handler) RejectAdjustment(ctx *fiber.Ctx) error {
+	adjustmentUsecase := usecase.NewAdjustmentUsecase(v1.storage, v1.adjustmentPolicy)
+	return v1.decideAdjustment(ctx, adjustmentUsecase.Reject)
+}
+
+type adjustmentDecision func(ctx context.Context, operator *user.User, id int) (*adjustment.Adjustment, error)
+
+func (v1 v1Handler) decideAdjustment(ctx *fiber.Ctx, decide adjustmentDecision) error {
+	currentUser := ctx.Locals("current_user").(*user.User)
+	id, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil {
+		return ctx.Status(fiber.StatusNotFound).JSON(presenter.NewFailure(storage.ErrAdjustmentNotFound))
+	}
+
+	decided, err := decide(ctx.Context(), currentUser, id)
+	switch {
+	case err == nil:
+		return ctx.JSON(presenter.NewAdjustmentResponse(decided))
+	case errors.Is(err, storage.ErrAdjustmentNotFound):
+		return ctx.Status(fiber.StatusNotFound).JSON(presenter.NewFailure(err))
+	case errors.Is(err, usecase.ErrSelfApproval), errors.Is(err, usecase.ErrSelfAdjustment):
+		return ctx.Status(fiber.StatusForbidden).JSON(presenter.NewFailure(err))
+	case errors.Is(err, storage.ErrAdjustmentDecided), errors.Is(err, usecase.ErrAdjustmentOverdraft):
+		return ctx.Status(fiber.StatusConflict).JSON(presenter.NewFailure(err))
+	default:
+		return ctx.Status(fiber.StatusInternalServerError).JSON(presenter.NewFailure(err))
+	}
+}
+
+func (v1 v1Handler) listAdjustments(ctx *fiber.Ctx, f adjustment.Filter) error {
+	adjustmentUsecase := usecase.NewAdjustmentUsecase(v1.storage, v1.adjustmentPolicy)
+	adjustments, err := adjustmentUsecase.FindAll(ctx.Context(), f)
+	if err != nil && errors.Is(err, usecase.ErrUnknownStatus) {
+		return ctx.Status(fiber.StatusBadRequest).JSON(presenter.NewFailure(err))
+	} else if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(presenter.NewFailure(err))
+	}
+
+	if len(adjustments) == 0 {
+		return ctx.Status(fiber.StatusNoContent).JSON(presenter.NewSuccess(nil))
+	}
+	return ctx.JSON(presenter.NewAdjustmentsResponse(adjustments))
+}
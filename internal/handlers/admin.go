@@ -0,0 +1,269 @@
+package handlers
+
+import (
+	"errors"
+	"net/url"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"lystem/internal/agent"
+	"lystem/internal/models/order"
+	"lystem/internal/models/user"
+	"lystem/internal/presenter"
+	"lystem/internal/storage"
+	"lystem/internal/usecase"
+)
+
+var (
+	errOrderFinal     = errors.New("заказ уже в финальном статусе")
+	errLoginNotLocked = errors.New("вход для логина не заблокирован")
+)
+
+// AdminGetUser godoc
+//
+//	@Summary		Получение пользователя по логину
+//	@Tags			Администрирование
+//	@Produce		application/json
+//	@Param			login	path		string	true	"логин пользователя"
+//	@Success		200		{string}	json	"успешная обработка запроса"
+//	@Failure		401		{string}	error	"пользователь не аутентифицирован"
+//	@Failure		403		{string}	error	"недостаточно прав"
+//	@Failure		404		{string}	error	"пользователь не найден"
+//	@Failure		500		{string}	error	"внутренняя ошибка сервера"
+//	@Router			/api/admin/users/{login}	[get]
+func (v1 v1Handler) AdminGetUser(ctx *fiber.Ctx) error {
+	foundUser, err := v1.findTargetUser(ctx)
+	if err != nil {
+		return respondUserLookupError(ctx, err)
+	}
+
+	return ctx.JSON(presenter.NewUserResponse(foundUser))
+}
+
+// AdminGetUserOrders godoc
+//
+//	@Summary		Получение списка заказов пользователя
+//	@Description	Параметры списка те же, что у /api/user/orders, но статус STUCK виден и по нему можно фильтровать
+//	@Tags			Администрирование
+//	@Produce		application/json
+//	@Param			login	path		string	true	"логин пользователя"
+//	@Success		200		{string}	json	"успешная обработка запроса"
+//	@Success		204		{string}	json	"нет данных для ответа"
+//	@Failure		400		{string}	error	"неверные параметры списка"
+//	@Failure		401		{string}	error	"пользователь не аутентифицирован"
+//	@Failure		403		{string}	error	"недостаточно прав"
+//	@Failure		404		{string}	error	"пользователь не найден"
+//	@Failure		500		{string}	error	"внутренняя ошибка сервера"
+//	@Router			/api/admin/users/{login}/orders	[get]
+func (v1 v1Handler) AdminGetUserOrders(ctx *fiber.Ctx) error {
+	foundUser, err := v1.findTargetUser(ctx)
+	if err != nil {
+		return respondUserLookupError(ctx, err)
+	}
+
+	return v1.listOrders(ctx, foundUser, true)
+}
+
+// AdminGetUserBalance godoc
+//
+//	@Summary		Получение баланса пользователя
+//	@Tags			Администрирование
+//	@Produce		application/json
+//	@Param			login	path		string	true	"логин пользователя"
+//	@Success		200		{string}	json	"успешная обработка запроса"
+//	@Failure		401		{string}	error	"пользователь не аутентифицирован"
+//	@Failure		403		{string}	error	"недостаточно прав"
+//	@Failure		404		{string}	error	"пользователь не найден"
+//	@Failure		500		{string}	error	"внутренняя ошибка сервера"
+//	@Router			/api/admin/users/{login}/balance	[get]
+func (v1 v1Handler) AdminGetUserBalance(ctx *fiber.Ctx) error {
+	foundUser, err := v1.findTargetUser(ctx)
+	if err != nil {
+		return respondUserLookupError(ctx, err)
+	}
+
+	return v1.showBalance(ctx, foundUser)
+}
+
+// AdminGetUserWithdrawals godoc
+//
+//	@Summary		Получение списка списаний пользователя
+//	@Description	Параметры списка те же, что у /api/user/withdrawals
+//	@Tags			Администрирование
+//	@Produce		application/json
+//	@Param			login	path		string	true	"логин пользователя"
+//	@Success		200		{string}	json	"успешная обработка запроса"
+//	@Success		204		{string}	json	"нет ни одного списания"
+//	@Failure		400		{string}	error	"неверные параметры списка"
+//	@Failure		401		{string}	error	"пользователь не аутентифицирован"
+//	@Failure		403		{string}	error	"недостаточно прав"
+//	@Failure		404		{string}	error	"пользователь не найден"
+//	@Failure		500		{string}	error	"внутренняя ошибка сервера"
+//	@Router			/api/admin/users/{login}/withdrawals	[get]
+func (v1 v1Handler) AdminGetUserWithdrawals(ctx *fiber.Ctx) error {
+	foundUser, err := v1.findTargetUser(ctx)
+	if err != nil {
+		return respondUserLookupError(ctx, err)
+	}
+
+	return v1.listWithdrawals(ctx, foundUser)
+}
+
+// AdminRecheckOrder godoc
+//
+//	@Summary		Внеочередной запрос заказа в системе расчёта баллов
+//	@Tags			Администрирование
+//	@Produce		application/json
+//	@Param			number	path		string	true	"номер заказа"
+//	@Success		200		{string}	json	"заказ после проверки"
+//	@Failure		401		{string}	error	"пользователь не аутентифицирован"
+//	@Failure		403		{string}	error	"недостаточно прав"
+//	@Failure		404		{string}	error	"заказ не найден"
+//	@Failure		409		{string}	error	"заказ уже в финальном статусе"
+//	@Failure		429		{string}	error	"система расчёта баллов ограничила запросы"
+//	@Failure		500		{string}	error	"внутренняя ошибка сервера"
+//	@Failure		503		{string}	error	"запросы к системе расчёта баллов приостановлены или она не ответила"
+//	@Router			/api/admin/orders/{number}/recheck	[post]
+func (v1 v1Handler) AdminRecheckOrder(ctx *fiber.Ctx) error {
+	orderUsecase := usecase.NewOrderUsecase(v1.storage)
+
+	foundOrder, err := orderUsecase.FindByNumber(ctx.Context(), ctx.Params("number"))
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(presenter.NewFailure(err))
+	}
+	if foundOrder == nil {
+		return ctx.Status(fiber.StatusNotFound).JSON(presenter.NewFailure(errOrderNotFound))
+	}
+	if order.IsFinal(foundOrder.Status) {
+		return ctx.Status(fiber.StatusConflict).JSON(presenter.NewFailure(errOrderFinal))
+	}
+
+	err = v1.agent.Recheck(ctx.Context(), foundOrder)
+	if err != nil && errors.Is(err, agent.ErrPaused) {
+		ctx.Set(fiber.HeaderRetryAfter, v1.agentRetryAfter())
+		return ctx.Status(fiber.StatusServiceUnavailable).JSON(presenter.NewFailure(err))
+	} else if err != nil && errors.Is(err, agent.ErrThrottled) {
+		ctx.Set(fiber.HeaderRetryAfter, v1.agentRetryAfter())
+		return ctx.Status(fiber.StatusTooManyRequests).JSON(presenter.NewFailure(err))
+	} else if err != nil && errors.Is(err, agent.ErrCheckFailed) {
+		return ctx.Status(fiber.StatusServiceUnavailable).JSON(presenter.NewFailure(err))
+	} else if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(presenter.NewFailure(err))
+	}
+
+	// the order may be postponed instead of updated, the stored order tells the outcome
+	checkedOrder, err := orderUsecase.FindByNumber(ctx.Context(), foundOrder.Number)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(presenter.NewFailure(err))
+	}
+
+	return ctx.JSON(presenter.NewAdminOrderDetailsResponse(checkedOrder, nil))
+}
+
+// AdminBlockUser godoc
+//
+//	@Summary		Блокировка учётной записи, все сессии пользователя завершаются
+//	@Description	Подписанные токены доступа, выданные до блокировки, действуют до истечения только для чтения,
+//	@Description	запросы на изменение данных (списания, загрузка заказов, смена пароля) получают 403
+//	@Tags			Администрирование
+//	@Produce		application/json
+//	@Param			login	path		string	true	"логин пользователя"
+//	@Success		200		{string}	json	"пользователь заблокирован"
+//	@Failure		400		{string}	error	"нельзя заблокировать самого себя"
+//	@Failure		401		{string}	error	"пользователь не аутентифицирован"
+//	@Failure		403		{string}	error	"недостаточно прав"
+//	@Failure		404		{string}	error	"пользователь не найден"
+//	@Failure		500		{string}	error	"внутренняя ошибка сервера"
+//	@Router			/api/admin/users/{login}/block	[post]
+func (v1 v1Handler) AdminBlockUser(ctx *fiber.Ctx) error {
+	return v1.setUserBlocked(ctx, true)
+}
+
+// AdminUnblockUser godoc
+//
+//	@Summary		Разблокировка учётной записи
+//	@Tags			Администрирование
+//	@Produce		application/json
+//	@Param			login	path		string	true	"логин пользователя"
+//	@Success		200		{string}	json	"пользователь разблокирован"
+//	@Failure		401		{string}	error	"пользователь не аутентифицирован"
+//	@Failure		403		{string}	error	"недостаточно прав"
+//	@Failure		404		{string}	error	"пользователь не найден"
+//	@Failure		500		{string}	error	"внутренняя ошибка сервера"
+//	@Router			/api/admin/users/{login}/block	[delete]
+func (v1 v1Handler) AdminUnblockUser(ctx *fiber.Ctx) error {
+	return v1.setUserBlocked(ctx, false)
+}
+
+// AdminUnlockLogin godoc
+//
+//	@Summary		Сброс неудачных попыток входа и блокировки по логину
+//	@Tags			Администрирование
+//	@Produce		application/json
+//	@Param			login	path		string	true	"логин"
+//	@Success		200		{string}	json	"вход разблокирован"
+//	@Failure		401		{string}	error	"пользователь не аутентифицирован"
+//	@Failure		403		{string}	error	"недостаточно прав"
+//	@Failure		404		{string}	error	"вход для логина не заблокирован"
+//	@Failure		500		{string}	error	"внутренняя ошибка сервера"
+//	@Router			/api/admin/logins/{login}/lock	[delete]
+func (v1 v1Handler) AdminUnlockLogin(ctx *fiber.Ctx) error {
+	login, err := url.PathUnescape(ctx.Params("login"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(presenter.NewFailure(err))
+	}
+
+	// failures are counted for unknown logins too, so the login is not looked up
+	sessionUsecase := usecase.NewSessionUsecase(v1.storage, v1.legacySalt)
+	unlocked, err := sessionUsecase.Unlock(ctx.Context(), login)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(presenter.NewFailure(err))
+	}
+	if !unlocked {
+		return ctx.Status(fiber.StatusNotFound).JSON(presenter.NewFailure(errLoginNotLocked))
+	}
+
+	return ctx.JSON(presenter.NewSuccess(nil))
+}
+
+func (v1 v1Handler) setUserBlocked(ctx *fiber.Ctx, blocked bool) error {
+	currentUser := ctx.Locals("current_user").(*user.User)
+	login, err := url.PathUnescape(ctx.Params("login"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(presenter.NewFailure(err))
+	}
+
+	adminUsecase := usecase.NewAdminUsecase(v1.storage)
+	foundUser, err := adminUsecase.SetBlocked(ctx.Context(), currentUser, login, blocked)
+	if err != nil && errors.Is(err, usecase.ErrSelfBlock) {
+		return ctx.Status(fiber.StatusBadRequest).JSON(presenter.NewFailure(err))
+	} else if err != nil {
+		return respondUserLookupError(ctx, err)
+	}
+
+	return ctx.JSON(presenter.NewUserResponse(foundUser))
+}
+
+// agentRetryAfter is Retry-After seconds till the agent pause ends
+func (v1 v1Handler) agentRetryAfter() string {
+	return retryAfter(time.Until(v1.agent.Metrics().PausedUntil))
+}
+
+// findTargetUser finds the user the admin request is about by login in the path
+func (v1 v1Handler) findTargetUser(ctx *fiber.Ctx) (*user.User, error) {
+	login, err := url.PathUnescape(ctx.Params("login"))
+	if err != nil {
+		return nil, storage.ErrUserNotFound
+	}
+
+	adminUsecase := usecase.NewAdminUsecase(v1.storage)
+	return adminUsecase.FindUser(ctx.Context(), login)
+}
+
+func respondUserLookupError(ctx *fiber.Ctx, err error) error {
+	if errors.Is(err, storage.ErrUserNotFound) {
+		return ctx.Status(fiber.StatusNotFound).JSON(presenter.NewFailure(err))
+	}
+	return ctx.Status(fiber.StatusInternalServerError).JSON(presenter.NewFailure(err))
+}
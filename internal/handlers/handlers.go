@@ -3,39 +3,112 @@ package handlers
 import (
 	"errors"
 	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 
 	"lystem/internal/agent"
 	"lystem/internal/config"
+	"lystem/internal/models/adjustment"
+	"lystem/internal/models/lockout"
+	"lystem/internal/models/session"
 	"lystem/internal/models/user"
+	"lystem/internal/notifier"
+	"lystem/internal/passwordreset"
 	"lystem/internal/presenter"
 	"lystem/internal/request"
 	"lystem/internal/storage"
+	"lystem/internal/token"
 	"lystem/internal/usecase"
-	"lystem/pkg/postgres"
 )
 
 type Handler interface {
 	CreateUser(ctx *fiber.Ctx) error
 	CreateSession(ctx *fiber.Ctx) error
 	DeleteSession(ctx *fiber.Ctx) error
+	RefreshSession(ctx *fiber.Ctx) error
+	GetSessions(ctx *fiber.Ctx) error
+	RevokeSession(ctx *fiber.Ctx) error
+
+	ChangePassword(ctx *fiber.Ctx) error
+	RequestPasswordReset(ctx *fiber.Ctx) error
+	ConfirmPasswordReset(ctx *fiber.Ctx) error
 
 	SaveOrder(ctx *fiber.Ctx) error
+	SaveOrdersBatch(ctx *fiber.Ctx) error
 	GetOrders(ctx *fiber.Ctx) error
+	GetOrder(ctx *fiber.Ctx) error
+	GetOrderHistory(ctx *fiber.Ctx) error
 	GetBalance(ctx *fiber.Ctx) error
+	GetBalanceHistory(ctx *fiber.Ctx) error
 	Withdraw(ctx *fiber.Ctx) error
 	Withdrawals(ctx *fiber.Ctx) error
+
+	AccrualCallback(ctx *fiber.Ctx) error
+
+	AdminGetUser(ctx *fiber.Ctx) error
+	AdminGetUserOrders(ctx *fiber.Ctx) error
+	AdminGetUserBalance(ctx *fiber.Ctx) error
+	AdminGetUserWithdrawals(ctx *fiber.Ctx) error
+	AdminRecheckOrder(ctx *fiber.Ctx) error
+	AdminBlockUser(ctx *fiber.Ctx) error
+	AdminUnblockUser(ctx *fiber.Ctx) error
+	AdminUnlockLogin(ctx *fiber.Ctx) error
+	CreateAdjustment(ctx *fiber.Ctx) error
+	GetUserAdjustments(ctx *fiber.Ctx) error
+	GetAdjustments(ctx *fiber.Ctx) error
+	ApproveAdjustment(ctx *fiber.Ctx) error
+	RejectAdjustment(ctx *fiber.Ctx) error
 }
 
-func New(db storage.Storage, agent *agent.Agent, options config.Config) Handler {
-	return v1Handler{storage: db, agent: agent, userSalt: options.UserSalt}
+// New creates handlers, signer is nil unless signed access tokens are enabled
+func New(db storage.Storage, agent *agent.Agent, signer *token.Signer, n notifier.Notifier, resets *passwordreset.Queue,
+	options config.Config) Handler {
+	loginPolicy := lockout.Policy{
+		LoginMaxFailures: options.LoginMaxFailures,
+		IPMaxFailures:    options.LoginIPMaxFailures,
+		Window:           options.LoginFailureWindow,
+		Lockout:          options.LoginLockout,
+		BaseDelay:        options.LoginBaseDelay,
+		MaxDelay:         options.LoginMaxDelay,
+	}
+	resetPolicy := loginPolicy
+	resetPolicy.Action = lockout.ActionPasswordReset
+
+	return v1Handler{
+		storage:          db,
+		agent:            agent,
+		signer:           signer,
+		notifier:         n,
+		resets:           resets,
+		adjustmentPolicy: adjustment.Policy{ApprovalThreshold: options.AdjustmentApprovalThreshold},
+		legacySalt:       options.UserSalt,
+		sessionTTL:       session.TTL{Absolute: options.SessionTTL, Idle: options.SessionIdleTTL},
+		loginPolicy:      loginPolicy,
+		resetPolicy:      resetPolicy,
+	}
 }
 
 type v1Handler struct {
-	storage  storage.Storage
-	agent    *agent.Agent
-	userSalt string
+	storage storage.Storage
+	agent   *agent.Agent
+	// legacySalt verifies password hashes of users registered before argon2id
+	legacySalt string
+	sessionTTL session.TTL
+	signer     *token.Signer
+	// loginPolicy delays and locks login attempts after failures
+	loginPolicy lockout.Policy
+	// resetPolicy throttles password reset requests per login and address by the same limits
+	resetPolicy lockout.Policy
+	// notifier is required by password usecase, reset tokens are delivered through resets
+	notifier notifier.Notifier
+	// resets delivers password reset tokens in background
+	resets *passwordreset.Queue
+	// adjustmentPolicy tells which balance adjustments wait for approval
+	adjustmentPolicy adjustment.Policy
 }
 
 // CreateUser godoc
@@ -59,18 +132,15 @@ func (v1 v1Handler) CreateUser(ctx *fiber.Ctx) error {
 		return ctx.Status(fiber.StatusBadRequest).JSON(presenter.NewFailure(err))
 	}
 
-	userUsecase := usecase.NewUserUsecase(v1.storage, v1.userSalt)
-	newSession, err := userUsecase.CreateUserAndSession(ctx.Context(), userRequest)
-	if err != nil && errors.Is(err, postgres.ErrUserAlreadyExists) {
+	userUsecase := usecase.NewUserUsecase(v1.storage)
+	newSession, err := userUsecase.CreateUserAndSession(ctx.Context(), userRequest, newClient(ctx))
+	if err != nil && errors.Is(err, storage.ErrUserAlreadyExists) {
 		return ctx.Status(fiber.StatusConflict).JSON(presenter.NewFailure(err))
 	} else if err != nil {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(presenter.NewFailure(err))
 	}
 
-	bearerToken := fmt.Sprintf("Token token=%s", newSession.ID)
-	ctx.Set("Authorization", bearerToken)
-
-	return ctx.JSON(presenter.NewSuccess(nil))
+	return v1.respondWithToken(ctx, newSession)
 }
 
 // CreateSession godoc
@@ -83,6 +153,8 @@ func (v1 v1Handler) CreateUser(ctx *fiber.Ctx) error {
 //	@Success		200		{string}	json	"пользователь успешно аутентифицирован"
 //	@Failure		400		{string}	error	"неверный формат запроса"
 //	@Failure		401		{string}	error	"неверная пара логин/пароль"
+//	@Failure		403		{string}	error	"учётная запись заблокирована"
+//	@Failure		429		{string}	error	"слишком много неудачных попыток, повтор после Retry-After секунд"
 //	@Failure		500		{string}	error	"внутренняя ошибка сервера"
 //	@Router			/api/user/login	    [post]
 func (v1 v1Handler) CreateSession(ctx *fiber.Ctx) error {
@@ -94,47 +166,154 @@ func (v1 v1Handler) CreateSession(ctx *fiber.Ctx) error {
 		return ctx.Status(fiber.StatusBadRequest).JSON(presenter.NewFailure(err))
 	}
 
-	sessionUsecase := usecase.NewSessionUsecase(v1.storage, v1.userSalt)
-	session, err := sessionUsecase.Create(ctx.Context(), sessionRequest)
-	if err != nil && errors.Is(err, usecase.ErrInvalidCreds) {
+	sessionUsecase := usecase.NewSessionUsecase(v1.storage, v1.legacySalt)
+	newSession, err := sessionUsecase.Create(ctx.Context(), sessionRequest, newClient(ctx), v1.loginPolicy)
+	var lockedErr *usecase.LoginLockedError
+	if err != nil && errors.As(err, &lockedErr) {
+		ctx.Set(fiber.HeaderRetryAfter, retryAfter(lockedErr.RetryAfter))
+		return ctx.Status(fiber.StatusTooManyRequests).JSON(presenter.NewFailure(err))
+	} else if err != nil && errors.Is(err, usecase.ErrUserBlocked) {
+		return ctx.Status(fiber.StatusForbidden).JSON(presenter.NewFailure(err))
+	} else if err != nil && errors.Is(err, usecase.ErrInvalidCreds) {
 		return ctx.Status(fiber.StatusUnauthorized).JSON(presenter.NewFailure(err))
 	} else if err != nil {
 		return ctx.Status(fiber.StatusUnauthorized).JSON(presenter.NewFailure(err))
 	}
 
-	bearerToken := fmt.Sprintf("Token token=%s", session.ID)
-	ctx.Set("Authorization", bearerToken)
+	return v1.respondWithToken(ctx, newSession)
+}
+
+// RefreshSession godoc
+//
+//	@Summary		Выпуск нового токена по refresh-токену
+//	@Tags			Сессия
+//	@Accept			application/json
+//	@Produce		application/json
+//	@Param			payload	body		request.RefreshSession
+//	@Success		200		{string}	json	"токен успешно обновлён"
+//	@Failure		400		{string}	error	"неверный формат запроса"
+//	@Failure		401		{string}	error	"сессия не найдена или завершена"
+//	@Failure		500		{string}	error	"внутренняя ошибка сервера"
+//	@Router			/api/user/refresh	[post]
+func (v1 v1Handler) RefreshSession(ctx *fiber.Ctx) error {
+	var refreshRequest request.RefreshSession
+	if err := ctx.BodyParser(&refreshRequest); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(presenter.NewFailure(err))
+	}
+	if err := refreshRequest.Validate(); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(presenter.NewFailure(err))
+	}
+
+	sessionUsecase := usecase.NewSessionUsecase(v1.storage, v1.legacySalt)
+	refreshed, err := sessionUsecase.Refresh(ctx.Context(), refreshRequest, newClient(ctx), v1.sessionTTL)
+	if err != nil && errors.Is(err, storage.ErrSessionNotFound) {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(presenter.NewFailure(err))
+	} else if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(presenter.NewFailure(err))
+	}
+
+	return v1.respondWithToken(ctx, refreshed)
+}
+
+// respondWithToken passes session token in Authorization header in the legacy format existing clients parse,
+// all the tokens, including signed access token when it is issued, are passed in the body.
+// Access token names the session by its public id, so it does not carry the session token.
+func (v1 v1Handler) respondWithToken(ctx *fiber.Ctx, s *session.Session) error {
+	response := presenter.NewTokenResponse(s, v1.sessionTTL)
+	if v1.signer != nil {
+		accessToken, claims, err := v1.signer.Issue(s.UserID, s.PublicID.String(), time.Now(), v1.sessionTTL.EndsAt(s))
+		if err != nil {
+			return ctx.Status(fiber.StatusInternalServerError).JSON(presenter.NewFailure(err))
+		}
+		response.AccessToken = accessToken
+		response.AccessExpiresAt = &claims.ExpiresAt
+	}
 
-	return ctx.JSON(presenter.NewSuccess(session))
+	ctx.Set(fiber.HeaderAuthorization, fmt.Sprintf("Token token=%s", s.ID))
+	return ctx.JSON(presenter.NewSuccess(response))
 }
 
 // DeleteSession godoc
 //
-//	@Summary		Удаление сессии
-//	@Tags			Авторизация
-//	@Accept			application/json
+//	@Summary		Удаление текущей сессии, остальные устройства остаются авторизованы
+//	@Tags			Сессия
 //	@Produce		application/json
-//	@Param			payload	body		request.CreateSession
 //	@Success		200		{string}	json	"сессия успешно удалена"
 //	@Failure		401		{string}	error	"не удалось идентифицировать пользователя"
 //	@Failure		500		{string}	error	"внутренняя ошибка сервера"
-//	@Router			/api/user/logout	[post]
+//	@Router			/api/user/logout	[delete]
 func (v1 v1Handler) DeleteSession(ctx *fiber.Ctx) error {
-	currentUser, ok := ctx.UserContext().Value("current_user").(*user.User)
-	if !ok {
-		return ctx.Status(fiber.StatusUnauthorized).JSON(presenter.NewFailure(errors.New("invalid creds")))
+	currentUser := ctx.Locals("current_user").(*user.User)
+	currentSessionID := ctx.Locals("current_session").(string)
+
+	sessionUsecase := usecase.NewSessionUsecase(v1.storage, v1.legacySalt)
+	err := sessionUsecase.Delete(ctx.Context(), currentUser, currentSessionID)
+	// session could be revoked from another device in between, the result is the same
+	if err != nil && !errors.Is(err, storage.ErrSessionNotFound) {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(presenter.NewFailure(err))
+	}
+
+	return ctx.JSON(presenter.NewSuccess(nil))
+}
+
+// GetSessions godoc
+//
+//	@Summary		Список активных сессий пользователя
+//	@Tags			Сессия
+//	@Produce		application/json
+//	@Success		200		{string}	json	"успешная обработка запроса"
+//	@Failure		401		{string}	error	"не удалось идентифицировать пользователя"
+//	@Failure		500		{string}	error	"внутренняя ошибка сервера"
+//	@Router			/api/user/sessions	[get]
+func (v1 v1Handler) GetSessions(ctx *fiber.Ctx) error {
+	currentUser := ctx.Locals("current_user").(*user.User)
+	currentSessionID := ctx.Locals("current_session").(string)
+
+	sessionUsecase := usecase.NewSessionUsecase(v1.storage, v1.legacySalt)
+	sessions, err := sessionUsecase.FindAll(ctx.Context(), currentUser)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(presenter.NewFailure(err))
 	}
 
-	sessionUsecase := usecase.NewSessionUsecase(v1.storage, v1.userSalt)
-	if err := sessionUsecase.Delete(ctx.Context(), currentUser); err != nil {
+	return ctx.JSON(presenter.NewSessionsResponse(sessions, currentSessionID))
+}
+
+// RevokeSession godoc
+//
+//	@Summary		Завершение сессии на другом устройстве
+//	@Tags			Сессия
+//	@Produce		application/json
+//	@Param			id	path		string	true	"идентификатор сессии из списка сессий"
+//	@Success		200		{string}	json	"сессия успешно удалена"
+//	@Failure		401		{string}	error	"не удалось идентифицировать пользователя"
+//	@Failure		404		{string}	error	"сессия не найдена"
+//	@Failure		500		{string}	error	"внутренняя ошибка сервера"
+//	@Router			/api/user/sessions/{id}	[delete]
+func (v1 v1Handler) RevokeSession(ctx *fiber.Ctx) error {
+	currentUser := ctx.Locals("current_user").(*user.User)
+
+	sessionUsecase := usecase.NewSessionUsecase(v1.storage, v1.legacySalt)
+	err := sessionUsecase.Delete(ctx.Context(), currentUser, ctx.Params("id"))
+	if err != nil && errors.Is(err, storage.ErrSessionNotFound) {
+		return ctx.Status(fiber.StatusNotFound).JSON(presenter.NewFailure(err))
+	} else if err != nil {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(presenter.NewFailure(err))
 	}
 
-	ctx.Context().RemoveUserValue("current_token")
-	ctx.Context().RemoveUserValue("current_user")
 	return ctx.JSON(presenter.NewSuccess(nil))
 }
 
+// newClient describes the device the session is opened from.
+// Header values point into the request buffer reused by fasthttp, so they are copied.
+func newClient(ctx *fiber.Ctx) session.Client {
+	return session.NewClient(strings.Clone(ctx.Get(fiber.HeaderUserAgent)), strings.Clone(ctx.IP()))
+}
+
+// retryAfter is Retry-After header value, whole seconds rounded up
+func retryAfter(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
 // GetBalance godoc
 //
 //	@Summary		Получение текущего баланса пользователя
@@ -147,13 +326,42 @@ func (v1 v1Handler) DeleteSession(ctx *fiber.Ctx) error {
 //	@Router			/api/user/balance	[get]
 func (v1 v1Handler) GetBalance(ctx *fiber.Ctx) error {
 	currentUser := ctx.Locals("current_user").(*user.User)
-	usersUsecase := usecase.NewUserUsecase(v1.storage, v1.userSalt)
-	balance, withdrawals, err := usersUsecase.GetBalanceAndWithdrawals(ctx.Context(), currentUser)
+	return v1.showBalance(ctx, currentUser)
+}
+
+func (v1 v1Handler) showBalance(ctx *fiber.Ctx, u *user.User) error {
+	usersUsecase := usecase.NewUserUsecase(v1.storage)
+	balance, err := usersUsecase.GetBalance(ctx.Context(), u)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(presenter.NewFailure(err))
+	}
+
+	return ctx.JSON(presenter.NewBalanceResponse(balance))
+}
+
+// GetBalanceHistory godoc
+//
+//	@Summary		Получение истории движения баллов пользователя
+//	@Tags			Баланс
+//	@Produce		application/json
+//	@Success		200		{string}	json	"успешная обработка запроса"
+//	@Success		204		{string}	json	"нет ни одного движения баллов"
+//	@Failure		401		{string}	error	"пользователь не аутентифицирован"
+//	@Failure		500		{string}	error	"внутренняя ошибка сервера"
+//	@Router			/api/user/balance/history	[get]
+func (v1 v1Handler) GetBalanceHistory(ctx *fiber.Ctx) error {
+	currentUser := ctx.Locals("current_user").(*user.User)
+	ledgerUsecase := usecase.NewLedgerUsecase(v1.storage)
+	entries, err := ledgerUsecase.History(ctx.Context(), currentUser)
 	if err != nil {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(presenter.NewFailure(err))
 	}
 
-	return ctx.JSON(presenter.NewBalanceResponse(balance, withdrawals))
+	if len(entries) == 0 {
+		return ctx.Status(fiber.StatusNoContent).JSON(presenter.NewSuccess(nil))
+	}
+
+	return ctx.JSON(presenter.NewLedgerResponse(entries))
 }
 
 // Withdraw godoc
@@ -162,8 +370,12 @@ func (v1 v1Handler) GetBalance(ctx *fiber.Ctx) error {
 //	@Tags			Баланс
 //	@Accept			application/json
 //	@Produce		application/json
+//	@Param			Idempotency-Key	header	string	false	"ключ для безопасного повтора запроса"
 //	@Success		200		{string}	json	"успешная обработка запроса"
 //	@Failure		401		{string}	error	"пользователь не аутентифицирован"
+//	@Failure		402		{string}	error	"на счету недостаточно средств"
+//	@Failure		409		{string}	error	"по этому номеру заказа уже было списание"
+//	@Failure		422		{string}	error	"неверный номер заказа или сумма точнее сотых"
 //	@Failure		500		{string}	error	"внутренняя ошибка сервера"
 //	@Router			/api/user/balance/withdraw	[post]
 func (v1 v1Handler) Withdraw(ctx *fiber.Ctx) error {
@@ -184,6 +396,8 @@ func (v1 v1Handler) Withdraw(ctx *fiber.Ctx) error {
 	w, err := withdrawalUsecase.Create(ctx.Context(), wRequest, currentUser)
 	if err != nil && errors.Is(err, usecase.ErrNotEnoughBalance) {
 		return ctx.Status(fiber.StatusPaymentRequired).JSON(presenter.NewFailure(err))
+	} else if err != nil && errors.Is(err, storage.ErrAlreadyWithdrawn) {
+		return ctx.Status(fiber.StatusConflict).JSON(presenter.NewFailure(err))
 	} else if err != nil && errors.Is(err, usecase.ErrOrderUserIncorrect) {
 		return ctx.Status(fiber.StatusUnprocessableEntity).JSON(presenter.NewFailure(err))
 	} else if err != nil {
@@ -196,20 +410,42 @@ func (v1 v1Handler) Withdraw(ctx *fiber.Ctx) error {
 // Withdrawals godoc
 //
 //	@Summary		Получение информации о выводе средств
+//	@Description	Следующая страница передаётся в заголовках Link (rel="next") и X-Next-Cursor
 //	@Tags			Списания
 //	@Produce		application/json
+//	@Param			limit	query		int		false	"размер страницы не больше 1000, с cursor по умолчанию 100; без limit и cursor возвращается весь список"
+//	@Param			cursor	query		string	false	"курсор следующей страницы"
+//	@Param			sort	query		string	false	"processed_at (по умолчанию) или -processed_at"
+//	@Param			from	query		string	false	"списание не раньше, RFC3339"
+//	@Param			to		query		string	false	"списание раньше, RFC3339"
 //	@Success		200		{string}	json	"успешная обработка запроса"
 //	@Success		204		{string}	json	"нет ни одного списания"
+//	@Failure		400		{string}	error	"неверные параметры списка"
 //	@Failure		401		{string}	error	"пользователь не аутентифицирован"
 //	@Failure		500		{string}	error	"внутренняя ошибка сервера"
 //	@Router			/api/user/withdrawals	    [get]
 func (v1 v1Handler) Withdrawals(ctx *fiber.Ctx) error {
 	currentUser := ctx.Locals("current_user").(*user.User)
+	return v1.listWithdrawals(ctx, currentUser)
+}
+
+// listWithdrawals answers with a page of withdrawals made by u
+func (v1 v1Handler) listWithdrawals(ctx *fiber.Ctx, u *user.User) error {
+	var listRequest request.ListRequest
+	if err := ctx.QueryParser(&listRequest); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(presenter.NewFailure(err))
+	}
+	query, err := listRequest.Query("processed_at")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(presenter.NewFailure(err))
+	}
+
 	withdrawalsUsecase := usecase.NewWithdrawalUsecase(v1.storage)
-	withdrawals, err := withdrawalsUsecase.FindAll(ctx.Context(), currentUser)
+	withdrawals, next, err := withdrawalsUsecase.FindAll(ctx.Context(), u, query)
 	if err != nil {
 		return ctx.Status(fiber.StatusUnprocessableEntity).JSON(presenter.NewFailure(err))
 	}
+	setNextPage(ctx, next)
 
 	if len(withdrawals) == 0 {
 		return ctx.Status(fiber.StatusNoContent).JSON(presenter.NewSuccess(nil))
@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"errors"
+
 	"github.com/gofiber/fiber/v2"
 
 	"lystem/internal/models/order"
@@ -10,6 +12,11 @@ import (
 	"lystem/internal/usecase"
 )
 
+var (
+	errOrderNotFound = errors.New("заказ не найден")
+	errForeignOrder  = errors.New("заказ загружен другим пользователем")
+)
+
 // SaveOrder godoc
 //
 //	@Summary		Загрузка заказа пользователем
@@ -59,29 +66,161 @@ func (v1 v1Handler) SaveOrder(ctx *fiber.Ctx) error {
 	return ctx.Status(fiber.StatusAccepted).JSON(presenter.NewSuccess(newOrder))
 }
 
+// SaveOrdersBatch godoc
+//
+//	@Summary		Загрузка нескольких заказов пользователем
+//	@Description	Для каждого номера возвращается результат: accepted, already_yours, owned_by_other или invalid
+//	@Tags			Заказ
+//	@Accept			application/json,text/plain
+//	@Produce		application/json
+//	@Param			payload	body		string	true	"JSON массив номеров или номера по одному в строке"
+//	@Success		200		{string}	json	"результаты загрузки по каждому номеру"
+//	@Failure		400		{string}	error	"неверный формат запроса"
+//	@Failure		401		{string}	error	"пользователь не аутентифицирован"
+//	@Failure		500		{string}	error	"внутренняя ошибка сервера"
+//	@Router			/api/user/orders/batch	[post]
+func (v1 v1Handler) SaveOrdersBatch(ctx *fiber.Ctx) error {
+	var batchRequest request.SaveOrdersBatchRequest
+	if err := batchRequest.Parse(ctx.Body()); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(presenter.NewFailure(err))
+	}
+	if err := batchRequest.Validate(); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(presenter.NewFailure(err))
+	}
+
+	currentUser := ctx.Locals("current_user").(*user.User)
+	orderUsecase := usecase.NewOrderUsecase(v1.storage)
+	uploads, err := orderUsecase.SaveBatch(ctx.Context(), batchRequest, currentUser)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(presenter.NewFailure(err))
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(presenter.NewUploadsResponse(uploads))
+}
+
 // GetOrders godoc
 //
 //	@Summary		Получение списка загруженных пользователем номеров заказов, статусов их обработки и информации о начислениях
+//	@Description	Следующая страница передаётся в заголовках Link (rel="next") и X-Next-Cursor
 //	@Tags			Заказ
 //	@Accept			text/plain
 //	@Produce		application/json
+//	@Param			limit	query		int		false	"размер страницы не больше 1000, с cursor по умолчанию 100; без limit и cursor возвращается весь список"
+//	@Param			cursor	query		string	false	"курсор следующей страницы"
+//	@Param			sort	query		string	false	"uploaded_at (по умолчанию) или -uploaded_at"
+//	@Param			status	query		string	false	"статусы через запятую"
+//	@Param			from	query		string	false	"загружен не раньше, RFC3339"
+//	@Param			to		query		string	false	"загружен раньше, RFC3339"
 //	@Success		200		{string}	json	"успешная обработка запроса"
 //	@Success		204		{string}	json	"нет данных для ответа"
+//	@Failure		400		{string}	error	"неверные параметры списка"
 //	@Failure		401		{string}	error	"пользователь не аутентифицирован"
 //	@Failure		500		{string}	error	"внутренняя ошибка сервера"
 //	@Router			/api/user/orders	[get]
 func (v1 v1Handler) GetOrders(ctx *fiber.Ctx) error {
 	currentUser := ctx.Locals("current_user").(*user.User)
+	return v1.listOrders(ctx, currentUser, false)
+}
+
+// listOrders answers with a page of orders uploaded by u, staff sees internal statuses of the orders
+func (v1 v1Handler) listOrders(ctx *fiber.Ctx, u *user.User, staff bool) error {
+	var listRequest request.ListRequest
+	if err := ctx.QueryParser(&listRequest); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(presenter.NewFailure(err))
+	}
+	filter, err := listRequest.OrderFilter()
+	if staff {
+		filter, err = listRequest.AdminOrderFilter()
+	}
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(presenter.NewFailure(err))
+	}
+
 	orderUsecase := usecase.NewOrderUsecase(v1.storage)
-	orders, err := orderUsecase.FindAllUserOrders(ctx.Context(), currentUser)
+	orders, next, err := orderUsecase.FindAllUserOrders(ctx.Context(), u, filter)
 	if err != nil {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(presenter.NewFailure(err))
 	}
+	setNextPage(ctx, next)
 
 	if len(orders) == 0 {
 		return ctx.Status(fiber.StatusNoContent).JSON(presenter.NewSuccess([]order.Order{}))
 	}
 
 	ctx.Set("Content-Type", "application/json")
+	if staff {
+		return ctx.Status(fiber.StatusOK).JSON(presenter.NewAdminOrdersResponse(orders))
+	}
 	return ctx.Status(fiber.StatusOK).JSON(presenter.NewOrdersResponse(orders))
 }
+
+// GetOrder godoc
+//
+//	@Summary		Получение заказа пользователя: статус, начисление, время последней проверки и списания по номеру заказа
+//	@Tags			Заказ
+//	@Produce		application/json
+//	@Param			number	path		string	true	"номер заказа"
+//	@Success		200		{string}	json	"успешная обработка запроса"
+//	@Failure		401		{string}	error	"пользователь не аутентифицирован"
+//	@Failure		403		{string}	error	"заказ загружен другим пользователем"
+//	@Failure		404		{string}	error	"заказ не найден"
+//	@Failure		500		{string}	error	"внутренняя ошибка сервера"
+//	@Router			/api/user/orders/{number}	[get]
+func (v1 v1Handler) GetOrder(ctx *fiber.Ctx) error {
+	currentUser := ctx.Locals("current_user").(*user.User)
+	orderUsecase := usecase.NewOrderUsecase(v1.storage)
+
+	foundOrder, err := orderUsecase.FindByNumber(ctx.Context(), ctx.Params("number"))
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(presenter.NewFailure(err))
+	}
+	if foundOrder == nil {
+		return ctx.Status(fiber.StatusNotFound).JSON(presenter.NewFailure(errOrderNotFound))
+	}
+	if foundOrder.UserID != currentUser.ID {
+		return ctx.Status(fiber.StatusForbidden).JSON(presenter.NewFailure(errForeignOrder))
+	}
+
+	withdrawalUsecase := usecase.NewWithdrawalUsecase(v1.storage)
+	withdrawals, err := withdrawalUsecase.FindByOrder(ctx.Context(), foundOrder.Number, currentUser)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(presenter.NewFailure(err))
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(presenter.NewOrderDetailsResponse(foundOrder, withdrawals))
+}
+
+// GetOrderHistory godoc
+//
+//	@Summary		Получение истории статусов заказа пользователя
+//	@Tags			Заказ
+//	@Produce		application/json
+//	@Param			number	path		string	true	"номер заказа"
+//	@Success		200		{string}	json	"успешная обработка запроса"
+//	@Failure		401		{string}	error	"пользователь не аутентифицирован"
+//	@Failure		403		{string}	error	"заказ загружен другим пользователем"
+//	@Failure		404		{string}	error	"заказ не найден"
+//	@Failure		500		{string}	error	"внутренняя ошибка сервера"
+//	@Router			/api/user/orders/{number}/history	[get]
+func (v1 v1Handler) GetOrderHistory(ctx *fiber.Ctx) error {
+	currentUser := ctx.Locals("current_user").(*user.User)
+	orderUsecase := usecase.NewOrderUsecase(v1.storage)
+
+	foundOrder, err := orderUsecase.FindByNumber(ctx.Context(), ctx.Params("number"))
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(presenter.NewFailure(err))
+	}
+	if foundOrder == nil {
+		return ctx.Status(fiber.StatusNotFound).JSON(presenter.NewFailure(errOrderNotFound))
+	}
+	if foundOrder.UserID != currentUser.ID {
+		return ctx.Status(fiber.StatusForbidden).JSON(presenter.NewFailure(errForeignOrder))
+	}
+
+	changes, err := orderUsecase.History(ctx.Context(), foundOrder)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(presenter.NewFailure(err))
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(presenter.NewOrderHistoryResponse(changes))
+}
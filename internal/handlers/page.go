@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"lystem/internal/models/page"
+)
+
+// setNextPage tells client how to get the next page: the same request with cursor replaced
+func setNextPage(ctx *fiber.Ctx, next *page.Cursor) {
+	if next == nil {
+		return
+	}
+
+	cursor := next.Encode()
+	args := fiber.AcquireArgs()
+	defer fiber.ReleaseArgs(args)
+	ctx.Request().URI().QueryArgs().CopyTo(args)
+	args.Set("cursor", cursor)
+
+	ctx.Set("X-Next-Cursor", cursor)
+	ctx.Links(ctx.Path()+"?"+args.String(), "next")
+}
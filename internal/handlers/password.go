@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+
+	"lystem/internal/models/user"
+	"lystem/internal/presenter"
+	"lystem/internal/request"
+	"lystem/internal/storage"
+	"lystem/internal/usecase"
+)
+
+// ChangePassword godoc
+//
+//	@Summary		Смена пароля, все сессии кроме текущей завершаются
+//	@Tags			Пароль
+//	@Accept			application/json
+//	@Produce		application/json
+//	@Param			payload	body		request.ChangePassword
+//	@Success		200		{string}	json	"пароль успешно изменён"
+//	@Failure		400		{string}	error	"неверный формат запроса"
+//	@Failure		401		{string}	error	"пользователь не аутентифицирован"
+//	@Failure		403		{string}	error	"неверный текущий пароль"
+//	@Failure		429		{string}	error	"слишком много неудачных попыток, повтор после Retry-After секунд"
+//	@Failure		500		{string}	error	"внутренняя ошибка сервера"
+//	@Router			/api/user/password	[post]
+func (v1 v1Handler) ChangePassword(ctx *fiber.Ctx) error {
+	currentUser := ctx.Locals("current_user").(*user.User)
+	currentSessionID := ctx.Locals("current_session").(string)
+
+	var changeRequest request.ChangePassword
+	if err := ctx.BodyParser(&changeRequest); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(presenter.NewFailure(err))
+	}
+	if err := changeRequest.Validate(); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(presenter.NewFailure(err))
+	}
+
+	passwordUsecase := usecase.NewPasswordUsecase(v1.storage, v1.notifier, v1.legacySalt)
+	err := passwordUsecase.Change(ctx.Context(), currentUser, changeRequest, currentSessionID, newClient(ctx), v1.loginPolicy)
+	var lockedErr *usecase.LoginLockedError
+	if err != nil && errors.As(err, &lockedErr) {
+		ctx.Set(fiber.HeaderRetryAfter, retryAfter(lockedErr.RetryAfter))
+		return ctx.Status(fiber.StatusTooManyRequests).JSON(presenter.NewFailure(err))
+	} else if err != nil && errors.Is(err, usecase.ErrWrongPassword) {
+		return ctx.Status(fiber.StatusForbidden).JSON(presenter.NewFailure(err))
+	} else if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(presenter.NewFailure(err))
+	}
+
+	return ctx.JSON(presenter.NewSuccess(nil))
+}
+
+// RequestPasswordReset godoc
+//
+//	@Summary		Запрос кода для сброса пароля
+//	@Tags			Пароль
+//	@Accept			application/json
+//	@Produce		application/json
+//	@Param			payload	body		request.RequestPasswordReset
+//	@Success		202		{string}	json	"если логин зарегистрирован и запросы не слишком часты, код будет отправлен пользователю"
+//	@Failure		400		{string}	error	"неверный формат запроса"
+//	@Failure		500		{string}	error	"внутренняя ошибка сервера"
+//	@Router			/api/user/password/reset	[post]
+func (v1 v1Handler) RequestPasswordReset(ctx *fiber.Ctx) error {
+	var resetRequest request.RequestPasswordReset
+	if err := ctx.BodyParser(&resetRequest); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(presenter.NewFailure(err))
+	}
+	if err := resetRequest.Validate(); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(presenter.NewFailure(err))
+	}
+
+	passwordUsecase := usecase.NewPasswordUsecase(v1.storage, v1.notifier, v1.legacySalt)
+	allowed, err := passwordUsecase.ReserveReset(ctx.Context(), resetRequest, newClient(ctx), v1.resetPolicy)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(presenter.NewFailure(err))
+	}
+	// the login is looked up by the queue, the answer takes the same time for any login;
+	// throttled requests are answered the same, otherwise the answer would tell the login was asked for
+	if allowed {
+		v1.resets.Enqueue(resetRequest)
+	}
+
+	return ctx.Status(fiber.StatusAccepted).JSON(presenter.NewSuccess(nil))
+}
+
+// ConfirmPasswordReset godoc
+//
+//	@Summary		Установка нового пароля по коду сброса, все сессии завершаются
+//	@Tags			Пароль
+//	@Accept			application/json
+//	@Produce		application/json
+//	@Param			payload	body		request.ConfirmPasswordReset
+//	@Success		200		{string}	json	"пароль успешно изменён"
+//	@Failure		400		{string}	error	"неверный формат запроса или недействительный код"
+//	@Failure		500		{string}	error	"внутренняя ошибка сервера"
+//	@Router			/api/user/password/reset/confirm	[post]
+func (v1 v1Handler) ConfirmPasswordReset(ctx *fiber.Ctx) error {
+	var confirmRequest request.ConfirmPasswordReset
+	if err := ctx.BodyParser(&confirmRequest); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(presenter.NewFailure(err))
+	}
+	if err := confirmRequest.Validate(); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(presenter.NewFailure(err))
+	}
+
+	passwordUsecase := usecase.NewPasswordUsecase(v1.storage, v1.notifier, v1.legacySalt)
+	err := passwordUsecase.ConfirmReset(ctx.Context(), confirmRequest)
+	if err != nil && errors.Is(err, storage.ErrResetTokenInvalid) {
+		return ctx.Status(fiber.StatusBadRequest).JSON(presenter.NewFailure(err))
+	} else if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(presenter.NewFailure(err))
+	}
+
+	return ctx.JSON(presenter.NewSuccess(nil))
+}
@@ -0,0 +1,128 @@
+package janitor
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"lystem/internal/config"
+	"lystem/internal/models/idempotency"
+	"lystem/internal/models/session"
+	"lystem/internal/storage"
+)
+
+// Janitor deletes sessions which can not be refreshed anymore, expired idempotency keys, login attempts
+// and password resets.
+// Sessions expired by idle TTL are kept, their refresh token still works.
+type Janitor struct {
+	storage        storage.Storage
+	logger         *zap.SugaredLogger
+	ttl            session.TTL
+	idempotencyTTL idempotency.TTL
+	// audit records of login attempts and used password resets are kept for attemptsRetention,
+	// counters are forgotten after failureWindow
+	attemptsRetention time.Duration
+	failureWindow     time.Duration
+	interval          time.Duration
+}
+
+func New(db storage.Storage, options config.Config, logger *zap.Logger) *Janitor {
+	return &Janitor{
+		storage:           db,
+		logger:            logger.Sugar(),
+		ttl:               session.TTL{Absolute: options.SessionTTL, Idle: options.SessionIdleTTL},
+		idempotencyTTL:    idempotency.TTL{Completed: options.IdempotencyKeyTTL, InProgress: options.IdempotencyLockTTL},
+		attemptsRetention: options.LoginAttemptsRetention,
+		failureWindow:     options.LoginFailureWindow,
+		interval:          options.SessionCleanupInterval,
+	}
+}
+
+func (j *Janitor) StartCleanup(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	if j.interval <= 0 {
+		return
+	}
+
+	cleanupTimer := time.NewTimer(j.interval)
+	for {
+		select {
+		case <-cleanupTimer.C:
+			j.DeleteEndedSessions(ctx)
+			j.DeleteExpiredIdempotencyKeys(ctx)
+			j.DeleteExpiredLoginAttempts(ctx)
+			j.DeleteExpiredPasswordResets(ctx)
+			cleanupTimer.Reset(j.interval)
+		case <-ctx.Done():
+			j.logger.Info("4 Gracefully stop storage cleanup timer")
+			cleanupTimer.Stop()
+			return
+		}
+	}
+}
+
+func (j *Janitor) DeleteEndedSessions(ctx context.Context) {
+	// sessions never end, nothing to clean
+	if j.ttl.Absolute <= 0 {
+		return
+	}
+
+	deleted, err := j.storage.DeleteEndedSessions(ctx, j.ttl.CreatedAfter(time.Now()))
+	if err != nil {
+		j.logger.Errorw("failed to delete ended sessions", "error", err)
+		return
+	}
+	if deleted > 0 {
+		j.logger.Infow("deleted ended sessions", "count", deleted)
+	}
+}
+
+func (j *Janitor) DeleteExpiredIdempotencyKeys(ctx context.Context) {
+	deleted, err := j.storage.DeleteExpiredIdempotencyKeys(ctx, j.idempotencyTTL.ExpiredBefore(time.Now()))
+	if err != nil {
+		j.logger.Errorw("failed to delete expired idempotency keys", "error", err)
+		return
+	}
+	if deleted > 0 {
+		j.logger.Infow("deleted expired idempotency keys", "count", deleted)
+	}
+}
+
+func (j *Janitor) DeleteExpiredLoginAttempts(ctx context.Context) {
+	// zero retention keeps audit records forever
+	var attemptedBefore time.Time
+	now := time.Now()
+	if j.attemptsRetention > 0 {
+		attemptedBefore = now.Add(-j.attemptsRetention)
+	}
+
+	deleted, err := j.storage.DeleteExpiredLoginAttempts(ctx, attemptedBefore, now.Add(-j.failureWindow))
+	if err != nil {
+		j.logger.Errorw("failed to delete expired login attempts", "error", err)
+		return
+	}
+	if deleted > 0 {
+		j.logger.Infow("deleted expired login attempts", "count", deleted)
+	}
+}
+
+func (j *Janitor) DeleteExpiredPasswordResets(ctx context.Context) {
+	// unused resets are useless once expired, used ones are audit records
+	var usedBefore time.Time
+	now := time.Now()
+	if j.attemptsRetention > 0 {
+		usedBefore = now.Add(-j.attemptsRetention)
+	}
+
+	deleted, err := j.storage.DeleteExpiredPasswordResets(ctx, now, usedBefore)
+	if err != nil {
+		j.logger.Errorw("failed to delete expired password resets", "error", err)
+		return
+	}
+	if deleted > 0 {
+		j.logger.Infow("deleted expired password resets", "count", deleted)
+	}
+}
@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"lystem/internal/models/idempotency"
+	"lystem/internal/models/user"
+	"lystem/internal/presenter"
+	"lystem/internal/storage"
+)
+
+const (
+	headerIdempotencyKey     = "Idempotency-Key"
+	headerIdempotentReplayed = "Idempotent-Replayed"
+	idempotencyKeyMaxLength  = 255
+	// idempotencyStoreTimeout bounds storing the outcome, which is done even if the request is cancelled
+	idempotencyStoreTimeout = 5 * time.Second
+)
+
+var (
+	errIdempotencyKeyTooLong    = errors.New("ключ идемпотентности слишком длинный")
+	errIdempotencyKeyInProgress = errors.New("запрос с этим ключом идемпотентности ещё обрабатывается")
+	errIdempotencyKeyReused     = errors.New("ключ идемпотентности уже использован для другого запроса")
+)
+
+// Idempotency replays stored response for repeated requests with the same Idempotency-Key header.
+// Keys are scoped to the current user, so it must run after Authorize.
+// Server errors are not stored - the client may retry them with the same key.
+// The key is released as well when the handler panics, the key of a request lost otherwise
+// (e.g. the instance stopped) is freed after ttl.InProgress.
+func Idempotency(db storage.Storage, ttl idempotency.TTL) func(ctx *fiber.Ctx) error {
+	return func(ctx *fiber.Ctx) error {
+		key := ctx.Get(headerIdempotencyKey)
+		if key == "" {
+			return ctx.Next()
+		}
+		if len(key) > idempotencyKeyMaxLength {
+			return ctx.Status(fiber.StatusBadRequest).JSON(presenter.NewFailure(errIdempotencyKeyTooLong))
+		}
+
+		currentUser := ctx.Locals("current_user").(*user.User)
+		rec := idempotency.Record{UserID: currentUser.ID, Key: key, RequestHash: requestHash(ctx)}
+
+		existing, err := db.ReserveIdempotencyKey(ctx.Context(), &rec, ttl)
+		if err != nil {
+			return ctx.Status(fiber.StatusInternalServerError).JSON(presenter.NewFailure(err))
+		}
+		if existing != nil {
+			return replay(ctx, existing, rec.RequestHash)
+		}
+
+		// outcome is stored with its own deadline, request context may be already cancelled
+		storeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx.Context()), idempotencyStoreTimeout)
+		defer cancel()
+
+		defer func() {
+			if r := recover(); r != nil {
+				_ = db.ReleaseIdempotencyKey(storeCtx, rec.UserID, rec.Key)
+				panic(r)
+			}
+		}()
+
+		if err = ctx.Next(); err != nil {
+			_ = db.ReleaseIdempotencyKey(storeCtx, rec.UserID, rec.Key)
+			return err
+		}
+
+		rec.StatusCode = ctx.Response().StatusCode()
+		if rec.StatusCode >= fiber.StatusInternalServerError {
+			return db.ReleaseIdempotencyKey(storeCtx, rec.UserID, rec.Key)
+		}
+		rec.ContentType = string(ctx.Response().Header.ContentType())
+		rec.Body = ctx.Response().Body()
+		return db.CompleteIdempotencyKey(storeCtx, &rec)
+	}
+}
+
+func replay(ctx *fiber.Ctx, existing *idempotency.Record, hash string) error {
+	if existing.RequestHash != hash {
+		return ctx.Status(fiber.StatusUnprocessableEntity).JSON(presenter.NewFailure(errIdempotencyKeyReused))
+	}
+	if !existing.Completed() {
+		return ctx.Status(fiber.StatusConflict).JSON(presenter.NewFailure(errIdempotencyKeyInProgress))
+	}
+
+	ctx.Set(headerIdempotentReplayed, "true")
+	ctx.Set(fiber.HeaderContentType, existing.ContentType)
+	return ctx.Status(existing.StatusCode).Send(existing.Body)
+}
+
+// requestHash fingerprints the request, so the key can not be reused for a different one
+func requestHash(ctx *fiber.Ctx) string {
+	h := sha256.New()
+	h.Write([]byte(ctx.Method()))
+	h.Write([]byte(ctx.Path()))
+	h.Write(ctx.Body())
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,99 @@
+package middleware_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/recover"
+
+	"lystem/internal/middleware"
+	"lystem/internal/models/idempotency"
+	"lystem/internal/models/user"
+	"lystem/internal/storage"
+	"lystem/pkg/memory"
+)
+
+const (
+	testUserID   = 1
+	withdrawBody = `{"order":"1","sum":1}`
+)
+
+// idempotentApp serves POST /withdraw behind Idempotency, handler answers 200 or panics when told to
+func idempotentApp(db storage.Storage, ttl idempotency.TTL, panics *bool) *fiber.App {
+	app := fiber.New()
+	app.Use(recover.New())
+	app.Use(func(ctx *fiber.Ctx) error {
+		ctx.Locals("current_user", &user.User{ID: testUserID})
+		return ctx.Next()
+	})
+	app.Post("/withdraw", middleware.Idempotency(db, ttl), func(ctx *fiber.Ctx) error {
+		if *panics {
+			panic("handler failed")
+		}
+		return ctx.SendString("done")
+	})
+	return app
+}
+
+func withdraw(t *testing.T, app *fiber.App, key string) int {
+	t.Helper()
+
+	req := httptest.NewRequest(fiber.MethodPost, "/withdraw", strings.NewReader(withdrawBody))
+	req.Header.Set("Idempotency-Key", key)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestIdempotencyReleasesKeyOnPanic(t *testing.T) {
+	panics := true
+	app := idempotentApp(memory.NewStorage(), idempotency.TTL{Completed: time.Hour, InProgress: time.Hour}, &panics)
+
+	if status := withdraw(t, app, "k"); status != fiber.StatusInternalServerError {
+		t.Fatalf("panicking request: got %d, want %d", status, fiber.StatusInternalServerError)
+	}
+	panics = false
+	if status := withdraw(t, app, "k"); status != fiber.StatusOK {
+		t.Fatalf("retry after panic: got %d, want %d", status, fiber.StatusOK)
+	}
+}
+
+func TestIdempotencyKeyInProgress(t *testing.T) {
+	tests := []struct {
+		name       string
+		inProgress time.Duration
+		want       int
+	}{
+		{name: "still processed", inProgress: time.Hour, want: fiber.StatusConflict},
+		{name: "abandoned", inProgress: time.Millisecond, want: fiber.StatusOK},
+		{name: "no lease", inProgress: 0, want: fiber.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := memory.NewStorage()
+			panics := false
+			app := idempotentApp(db, idempotency.TTL{Completed: time.Hour, InProgress: tt.inProgress}, &panics)
+
+			// the same request got the key before, but never answered
+			hash := sha256.Sum256([]byte(fiber.MethodPost + "/withdraw" + withdrawBody))
+			rec := idempotency.Record{UserID: testUserID, Key: "k", RequestHash: hex.EncodeToString(hash[:])}
+			if _, err := db.ReserveIdempotencyKey(context.Background(), &rec, idempotency.TTL{Completed: time.Hour}); err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(2 * time.Millisecond)
+
+			if status := withdraw(t, app, "k"); status != tt.want {
+				t.Fatalf("got %d, want %d", status, tt.want)
+			}
+		})
+	}
+}
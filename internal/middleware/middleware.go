@@ -4,55 +4,121 @@ import (
 	"errors"
 	"slices"
 	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 
+	"lystem/internal/models/session"
+	"lystem/internal/models/user"
 	"lystem/internal/presenter"
 	"lystem/internal/storage"
+	"lystem/internal/token"
 )
 
 var ignorePaths = []string{
 	"/api/user/login",
 	"/api/user/register",
+	"/api/user/refresh",
+	"/api/user/password/reset",
+	"/api/user/password/reset/confirm",
 }
 
-var errUnauththorized = errors.New("не удалось идентифицировать пользователя")
+var (
+	errUnauththorized = errors.New("не удалось идентифицировать пользователя")
+	errSessionExpired = errors.New("сессия истекла")
+	errUserBlocked    = errors.New("учётная запись заблокирована")
+)
 
-func Authorize(db storage.Storage) func(ctx *fiber.Ctx) error {
+// Authorize rejects requests with unknown or expired session token and renews last activity time of the session.
+// With signer set, signed access tokens of reading requests are verified without the storage; such a token stays valid
+// until it expires even if its session is revoked. Requests changing data re-read the user and the session, so a blocked
+// user or a revoked session can not withdraw, upload orders or change the password with a token issued before.
+func Authorize(db storage.Storage, ttl session.TTL, signer *token.Signer) func(ctx *fiber.Ctx) error {
 	return func(ctx *fiber.Ctx) error {
 		if ctx.Method() == fiber.MethodPost && slices.Contains(ignorePaths, ctx.Path()) {
 			return ctx.Next()
 		}
 
-		var token string
+		var sessionToken string
 		authHeader := ctx.Get(fiber.HeaderAuthorization)
 		if authHeader != "" {
-			token = extractToken(authHeader)
-			if token == "" {
+			sessionToken = extractToken(authHeader)
+			if sessionToken == "" {
+				return ctx.Status(fiber.StatusUnauthorized).JSON(presenter.NewFailure(errUnauththorized))
+			}
+		}
+
+		if signer != nil && token.LooksSigned(sessionToken) {
+			claims, err := signer.Verify(sessionToken, time.Now())
+			if err != nil && errors.Is(err, token.ErrExpired) {
+				return ctx.Status(fiber.StatusUnauthorized).JSON(presenter.NewFailure(errSessionExpired))
+			} else if err != nil {
 				return ctx.Status(fiber.StatusUnauthorized).JSON(presenter.NewFailure(errUnauththorized))
 			}
+
+			currentUser := &user.User{ID: claims.UserID}
+			if !readOnly(ctx.Method()) {
+				if currentUser, err = db.FindUserByID(ctx.Context(), claims.UserID); err != nil {
+					return ctx.Status(fiber.StatusUnauthorized).JSON(presenter.NewFailure(errUnauththorized))
+				}
+				if currentUser.Blocked() {
+					return ctx.Status(fiber.StatusForbidden).JSON(presenter.NewFailure(errUserBlocked))
+				}
+				// logout, password change and reset revoke sessions before their tokens expire
+				if _, err = db.FindSession(ctx.Context(), currentUser, claims.SessionID); err != nil {
+					return ctx.Status(fiber.StatusUnauthorized).JSON(presenter.NewFailure(errUnauththorized))
+				}
+			}
+
+			ctx.Locals("current_user", currentUser)
+			ctx.Locals("current_session", claims.SessionID)
+			return ctx.Next()
 		}
 
-		foundUser, err := db.FindUserByToken(ctx.Context(), token)
+		foundUser, foundSession, err := db.FindUserByToken(ctx.Context(), sessionToken)
 		if err != nil {
 			return ctx.Status(fiber.StatusUnauthorized).JSON(presenter.NewFailure(errUnauththorized))
 		}
 
+		if foundUser.Blocked() {
+			return ctx.Status(fiber.StatusForbidden).JSON(presenter.NewFailure(errUserBlocked))
+		}
+
+		now := time.Now()
+		if ttl.Expired(foundSession, now) {
+			return ctx.Status(fiber.StatusUnauthorized).JSON(presenter.NewFailure(errSessionExpired))
+		}
+		if ttl.NeedsTouch(foundSession, now) {
+			// failed renewal only shortens the session, the request itself is authorized
+			_ = db.TouchSession(ctx.Context(), sessionToken)
+		}
+
 		ctx.Locals("current_user", foundUser)
-		ctx.Locals("current_token", token)
+		ctx.Locals("current_session", foundSession.PublicID.String())
 		return ctx.Next()
 	}
 }
 
-// Extracts token from header value
+func readOnly(method string) bool {
+	return method == fiber.MethodGet || method == fiber.MethodHead || method == fiber.MethodOptions
+}
+
+// Extracts token from header value, both standard and legacy formats are accepted
+// Example: "Authorization": "Bearer <token>"
 // Example: "Authorization": "Token token=<session-id-as-token>"
 func extractToken(headerValue string) string {
-	parts := strings.Split(headerValue, "Token token=")
-	if len(parts) != 2 {
-		return ""
+	var token string
+	if scheme, credentials, ok := strings.Cut(headerValue, " "); ok && strings.EqualFold(scheme, "Bearer") {
+		token = credentials
+	} else {
+		parts := strings.Split(headerValue, "Token token=")
+		if len(parts) != 2 {
+			return ""
+		}
+		token = parts[1]
 	}
 
-	token := strings.TrimSpace(parts[1])
+	token = strings.TrimSpace(token)
 	if len(token) < 1 {
 		return ""
 	}
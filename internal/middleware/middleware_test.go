@@ -0,0 +1,131 @@
+package middleware_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"lystem/internal/middleware"
+	"lystem/internal/models/session"
+	"lystem/internal/models/user"
+	"lystem/internal/token"
+	"lystem/pkg/memory"
+)
+
+func TestSignedTokenOfBlockedUser(t *testing.T) {
+	ctx := context.Background()
+	db := memory.NewStorage()
+	u, err := db.CreateUser(ctx, &user.User{Login: "blocked"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := token.NewSigner([]token.Key{{ID: "k1", Secret: []byte("0123456789abcdef0123456789abcdef")}}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	accessToken, _, err := signer.Issue(u.ID, "sid", time.Now(), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.SetUserBlocked(ctx, u, true); err != nil {
+		t.Fatal(err)
+	}
+
+	app := fiber.New()
+	app.Use(middleware.Authorize(db, session.TTL{}, signer))
+	app.Get("/balance", func(ctx *fiber.Ctx) error { return ctx.SendString("balance") })
+	app.Post("/withdraw", func(ctx *fiber.Ctx) error { return ctx.SendString("done") })
+
+	tests := []struct {
+		method string
+		path   string
+		want   int
+	}{
+		{method: fiber.MethodGet, path: "/balance", want: fiber.StatusOK},
+		{method: fiber.MethodPost, path: "/withdraw", want: fiber.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set(fiber.HeaderAuthorization, "Bearer "+accessToken)
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tt.want {
+				t.Errorf("%s %s = %d, want %d", tt.method, tt.path, resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignedTokenOfRevokedSession(t *testing.T) {
+	ctx := context.Background()
+	db := memory.NewStorage()
+	signer, err := token.NewSigner([]token.Key{{ID: "k1", Secret: []byte("0123456789abcdef0123456789abcdef")}}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := fiber.New()
+	app.Use(middleware.Authorize(db, session.TTL{}, signer))
+	app.Get("/balance", func(ctx *fiber.Ctx) error { return ctx.SendString("balance") })
+	app.Post("/withdraw", func(ctx *fiber.Ctx) error { return ctx.SendString("done") })
+
+	do := func(t *testing.T, method, path, accessToken string) int {
+		t.Helper()
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set(fiber.HeaderAuthorization, "Bearer "+accessToken)
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	tests := []struct {
+		name   string
+		revoke func(u *user.User, s *session.Session) error
+	}{
+		{name: "logout", revoke: func(u *user.User, s *session.Session) error {
+			return db.DeleteSession(ctx, u, s.PublicID.String())
+		}},
+		{name: "password change from other session", revoke: func(u *user.User, _ *session.Session) error {
+			return db.ChangeUserPassword(ctx, u, "")
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := db.CreateUser(ctx, &user.User{Login: "u-" + tt.name})
+			if err != nil {
+				t.Fatal(err)
+			}
+			s, err := db.CreateSession(ctx, u, session.Client{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			accessToken, _, err := signer.Issue(u.ID, s.PublicID.String(), time.Now(), time.Now().Add(time.Hour))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if code := do(t, fiber.MethodPost, "/withdraw", accessToken); code != fiber.StatusOK {
+				t.Fatalf("POST /withdraw with live session = %d, want %d", code, fiber.StatusOK)
+			}
+			if err = tt.revoke(u, s); err != nil {
+				t.Fatal(err)
+			}
+			if code := do(t, fiber.MethodGet, "/balance", accessToken); code != fiber.StatusOK {
+				t.Errorf("GET /balance = %d, want %d", code, fiber.StatusOK)
+			}
+			if code := do(t, fiber.MethodPost, "/withdraw", accessToken); code != fiber.StatusUnauthorized {
+				t.Errorf("POST /withdraw = %d, want %d", code, fiber.StatusUnauthorized)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+
+	"lystem/internal/models/user"
+	"lystem/internal/presenter"
+	"lystem/internal/storage"
+)
+
+var errForbidden = errors.New("недостаточно прав")
+
+// RequireRole lets through users having one of the roles, it must run after Authorize.
+// The user is read again from the storage, so role changes and blocks apply at once
+// even to requests with signed access tokens.
+func RequireRole(db storage.Storage, roles ...string) func(ctx *fiber.Ctx) error {
+	return func(ctx *fiber.Ctx) error {
+		currentUser := ctx.Locals("current_user").(*user.User)
+
+		foundUser, err := db.FindUserByID(ctx.Context(), currentUser.ID)
+		if err != nil && errors.Is(err, storage.ErrUserNotFound) {
+			return ctx.Status(fiber.StatusUnauthorized).JSON(presenter.NewFailure(errUnauththorized))
+		} else if err != nil {
+			return ctx.Status(fiber.StatusInternalServerError).JSON(presenter.NewFailure(err))
+		}
+		if foundUser.Blocked() {
+			return ctx.Status(fiber.StatusForbidden).JSON(presenter.NewFailure(errUserBlocked))
+		}
+		if !foundUser.HasRole(roles...) {
+			return ctx.Status(fiber.StatusForbidden).JSON(presenter.NewFailure(errForbidden))
+		}
+
+		ctx.Locals("current_user", foundUser)
+		return ctx.Next()
+	}
+}
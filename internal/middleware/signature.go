@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"lystem/internal/presenter"
+)
+
+const (
+	signatureHeader = "X-Accrual-Signature"
+	timestampHeader = "X-Accrual-Timestamp"
+)
+
+var (
+	errInvalidSignature = errors.New("неверная подпись запроса")
+	errStaleRequest     = errors.New("время запроса вне допустимого окна")
+)
+
+// AccrualSignature lets through requests signed by accrual system.
+// Signature is hex HMAC-SHA256 of "<timestamp>.<body>" with shared secret, timestamp is unix seconds
+// and must not differ from now by more than maxSkew, so captured requests can not be replayed later.
+func AccrualSignature(secret string, maxSkew time.Duration) func(ctx *fiber.Ctx) error {
+	return func(ctx *fiber.Ctx) error {
+		timestamp := ctx.Get(timestampHeader)
+		unix, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return ctx.Status(fiber.StatusUnauthorized).JSON(presenter.NewFailure(errInvalidSignature))
+		}
+		if skew := time.Since(time.Unix(unix, 0)); skew > maxSkew || skew < -maxSkew {
+			return ctx.Status(fiber.StatusUnauthorized).JSON(presenter.NewFailure(errStaleRequest))
+		}
+
+		signature, err := hex.DecodeString(ctx.Get(signatureHeader))
+		if err != nil || !hmac.Equal(signature, Sign(secret, timestamp, ctx.Body())) {
+			return ctx.Status(fiber.StatusUnauthorized).JSON(presenter.NewFailure(errInvalidSignature))
+		}
+
+		return ctx.Next()
+	}
+}
+
+// Sign computes accrual callback signature, accrual system side uses the same scheme
+func Sign(secret, timestamp string, body []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
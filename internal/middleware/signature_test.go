@@ -0,0 +1,96 @@
+package middleware_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"lystem/internal/middleware"
+)
+
+const (
+	callbackSecret = "callback-secret"
+	callbackBody   = `{"order":"12345678903","status":"PROCESSED","accrual":500}`
+)
+
+// sign is the scheme accrual system follows, computed here independently of middleware.Sign
+func sign(secret, timestamp, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "." + body))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestAccrualSignature(t *testing.T) {
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	stale := strconv.FormatInt(time.Now().Add(-2*time.Minute).Unix(), 10)
+	future := strconv.FormatInt(time.Now().Add(2*time.Minute).Unix(), 10)
+
+	tests := []struct {
+		name      string
+		timestamp string
+		signature string
+		body      string
+		want      int
+	}{
+		{name: "valid", timestamp: now, signature: sign(callbackSecret, now, callbackBody), body: callbackBody, want: fiber.StatusOK},
+		{
+			name:      "tampered body",
+			timestamp: now,
+			signature: sign(callbackSecret, now, callbackBody),
+			body:      strings.Replace(callbackBody, "500", "50000", 1),
+			want:      fiber.StatusUnauthorized,
+		},
+		{name: "wrong secret", timestamp: now, signature: sign("other", now, callbackBody), body: callbackBody, want: fiber.StatusUnauthorized},
+		{
+			name:      "timestamp replaced",
+			timestamp: now,
+			signature: sign(callbackSecret, stale, callbackBody),
+			body:      callbackBody,
+			want:      fiber.StatusUnauthorized,
+		},
+		{name: "stale", timestamp: stale, signature: sign(callbackSecret, stale, callbackBody), body: callbackBody, want: fiber.StatusUnauthorized},
+		{name: "future", timestamp: future, signature: sign(callbackSecret, future, callbackBody), body: callbackBody, want: fiber.StatusUnauthorized},
+		{name: "missing timestamp", signature: sign(callbackSecret, "", callbackBody), body: callbackBody, want: fiber.StatusUnauthorized},
+		{name: "missing signature", timestamp: now, body: callbackBody, want: fiber.StatusUnauthorized},
+		{name: "signature not hex", timestamp: now, signature: "not-hex", body: callbackBody, want: fiber.StatusUnauthorized},
+	}
+
+	app := fiber.New()
+	app.Post("/callback", middleware.AccrualSignature(callbackSecret, time.Minute), func(ctx *fiber.Ctx) error {
+		return ctx.SendString("accepted")
+	})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(fiber.MethodPost, "/callback", strings.NewReader(tt.body))
+			if tt.timestamp != "" {
+				req.Header.Set("X-Accrual-Timestamp", tt.timestamp)
+			}
+			if tt.signature != "" {
+				req.Header.Set("X-Accrual-Signature", tt.signature)
+			}
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tt.want {
+				t.Errorf("status %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignMatchesScheme(t *testing.T) {
+	got := hex.EncodeToString(middleware.Sign(callbackSecret, "1700000000", []byte(callbackBody)))
+	if want := sign(callbackSecret, "1700000000", callbackBody); got != want {
+		t.Errorf("Sign() = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,260 @@
+// Package migrations applies numbered schema migrations kept in sql/ folder.
+//
+// Every migration is a pair of files NNNN_name.up.sql and NNNN_name.down.sql.
+// Applied versions are recorded in schema_migrations table, runs are guarded
+// with postgres advisory lock, so several instances starting at once do not
+// apply the same migration twice.
+package migrations
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+//go:embed sql/*.sql
+var files embed.FS
+
+// lockKey is an arbitrary application wide key for pg_advisory_lock
+const lockKey = 7_345_001
+
+var (
+	createSchemaMigrationsSQL = `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now())`
+	selectAppliedSQL  = `SELECT version, applied_at FROM schema_migrations ORDER BY version`
+	insertAppliedSQL  = `INSERT INTO schema_migrations (version, name) VALUES (@version, @name)`
+	deleteAppliedSQL  = `DELETE FROM schema_migrations WHERE version = @version`
+	advisoryLockSQL   = `SELECT pg_advisory_lock(@key)`
+	advisoryUnlockSQL = `SELECT pg_advisory_unlock(@key)`
+)
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+type Migrator struct {
+	conn       *pgxpool.Conn
+	migrations []Migration
+}
+
+func New(conn *pgxpool.Conn) (*Migrator, error) {
+	migrations, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{conn: conn, migrations: migrations}, nil
+}
+
+// Load reads embedded migrations sorted by version
+func Load() ([]Migration, error) {
+	return load(files)
+}
+
+// load reads migrations from sql folder of fsys
+func load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, "sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		name := entry.Name()
+		base, direction, ok := cutDirection(name)
+		if !ok {
+			return nil, fmt.Errorf("migration %s: expected .up.sql or .down.sql suffix", name)
+		}
+
+		versionStr, title, _ := strings.Cut(base, "_")
+		version, err := strconv.Atoi(versionStr)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: invalid version: %w", name, err)
+		}
+
+		body, err := fs.ReadFile(fsys, path.Join("sql", name))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: title}
+			byVersion[version] = m
+		}
+		if m.Name != title {
+			return nil, fmt.Errorf("migration %s: version %04d is taken by %s", name, version, m.Name)
+		}
+		target := &m.Down
+		if direction == "up" {
+			target = &m.Up
+		}
+		if *target != "" {
+			return nil, fmt.Errorf("migration %s: duplicate %s migration of version %04d", name, direction, version)
+		}
+		*target = string(body)
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %04d_%s: up migration is missing", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+func cutDirection(name string) (string, string, bool) {
+	if base, ok := strings.CutSuffix(name, ".up.sql"); ok {
+		return base, "up", true
+	}
+	if base, ok := strings.CutSuffix(name, ".down.sql"); ok {
+		return base, "down", true
+	}
+	return "", "", false
+}
+
+// Up applies all pending migrations, returns applied ones
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+	err := m.withLock(ctx, func() error {
+		done, err := m.applied(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := done[migration.Version]; ok {
+				continue
+			}
+			if err = m.apply(ctx, migration.Up, insertAppliedSQL, migration); err != nil {
+				return fmt.Errorf("migration %04d_%s up: %w", migration.Version, migration.Name, err)
+			}
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+
+	return applied, err
+}
+
+// Down rolls back the last applied migration, returns nil if there is nothing to roll back
+func (m *Migrator) Down(ctx context.Context) (*Migration, error) {
+	var rolledBack *Migration
+	err := m.withLock(ctx, func() error {
+		done, err := m.applied(ctx)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			migration := m.migrations[i]
+			if _, ok := done[migration.Version]; !ok {
+				continue
+			}
+			if migration.Down == "" {
+				return fmt.Errorf("migration %04d_%s: down migration is missing", migration.Version, migration.Name)
+			}
+			if err = m.apply(ctx, migration.Down, deleteAppliedSQL, migration); err != nil {
+				return fmt.Errorf("migration %04d_%s down: %w", migration.Version, migration.Name, err)
+			}
+			rolledBack = &migration
+			return nil
+		}
+		return nil
+	})
+
+	return rolledBack, err
+}
+
+// Status lists all known migrations with their applied state
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	if _, err := m.conn.Exec(ctx, createSchemaMigrationsSQL); err != nil {
+		return nil, err
+	}
+	done, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		appliedAt, ok := done[migration.Version]
+		statuses = append(statuses, Status{Migration: migration, Applied: ok, AppliedAt: appliedAt})
+	}
+	return statuses, nil
+}
+
+func (m *Migrator) withLock(ctx context.Context, fn func() error) error {
+	if _, err := m.conn.Exec(ctx, advisoryLockSQL, pgx.NamedArgs{"key": lockKey}); err != nil {
+		return err
+	}
+	// unlock with background context - lock is held by connection and must be released even on cancelled ctx
+	defer m.conn.Exec(context.Background(), advisoryUnlockSQL, pgx.NamedArgs{"key": lockKey})
+
+	if _, err := m.conn.Exec(ctx, createSchemaMigrationsSQL); err != nil {
+		return err
+	}
+	return fn()
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[int]time.Time, error) {
+	rows, err := m.conn.Query(ctx, selectAppliedSQL)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	done := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err = rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		done[version] = appliedAt
+	}
+	return done, rows.Err()
+}
+
+// apply runs migration body and bookkeeping query in one transaction
+func (m *Migrator) apply(ctx context.Context, body string, bookkeepingSQL string, migration Migration) error {
+	tx, err := m.conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec(ctx, body); err != nil {
+		_ = tx.Rollback(ctx)
+		return err
+	}
+	args := pgx.NamedArgs{"version": migration.Version, "name": migration.Name}
+	if _, err = tx.Exec(ctx, bookkeepingSQL, args); err != nil {
+		_ = tx.Rollback(ctx)
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,109 @@
+package migrations
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadEmbedded(t *testing.T) {
+	migrations, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("no embedded migrations")
+	}
+
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Errorf("migration %d has version %d, versions must go one by one from 1", i, m.Version)
+		}
+		if m.Name == "" || strings.TrimSpace(m.Up) == "" || strings.TrimSpace(m.Down) == "" {
+			t.Errorf("migration %04d_%s: name, up or down is empty", m.Version, m.Name)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	file := func(body string) *fstest.MapFile { return &fstest.MapFile{Data: []byte(body)} }
+
+	tests := []struct {
+		name    string
+		fsys    fstest.MapFS
+		want    []Migration
+		wantErr string
+	}{
+		{
+			name: "sorted by version",
+			fsys: fstest.MapFS{
+				"sql/0010_ten.up.sql":   file("up 10"),
+				"sql/0002_two.up.sql":   file("up 2"),
+				"sql/0002_two.down.sql": file("down 2"),
+				"sql/0001_one.up.sql":   file("up 1"),
+				"sql/0001_one.down.sql": file("down 1"),
+			},
+			want: []Migration{
+				{Version: 1, Name: "one", Up: "up 1", Down: "down 1"},
+				{Version: 2, Name: "two", Up: "up 2", Down: "down 2"},
+				{Version: 10, Name: "ten", Up: "up 10"},
+			},
+		},
+		{
+			name:    "no direction suffix",
+			fsys:    fstest.MapFS{"sql/0001_one.sql": file("up 1")},
+			wantErr: "expected .up.sql or .down.sql suffix",
+		},
+		{
+			name:    "no version",
+			fsys:    fstest.MapFS{"sql/one.up.sql": file("up 1")},
+			wantErr: "invalid version",
+		},
+		{
+			name: "missing up",
+			fsys: fstest.MapFS{
+				"sql/0001_one.up.sql":   file("up 1"),
+				"sql/0002_two.down.sql": file("down 2"),
+			},
+			wantErr: "0002_two: up migration is missing",
+		},
+		{
+			name: "duplicate version",
+			fsys: fstest.MapFS{
+				"sql/0001_one.up.sql":   file("up 1"),
+				"sql/0001_other.up.sql": file("up 1 again"),
+			},
+			wantErr: "version 0001 is taken",
+		},
+		{
+			name: "duplicate direction",
+			fsys: fstest.MapFS{
+				"sql/0001_one.up.sql": file("up 1"),
+				"sql/1_one.up.sql":    file("up 1 again"),
+			},
+			wantErr: "duplicate up migration of version 0001",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := load(tt.fsys)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("load() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("load() = %+v, want %+v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("migration %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+-- tables may already exist on databases created before migrations were introduced
+CREATE TABLE IF NOT EXISTS users (
+	id SERIAL PRIMARY KEY,
+	login TEXT NOT NULL,
+	hashed_password TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE UNIQUE INDEX IF NOT EXISTS users_login_key ON users(login);
+
+CREATE TABLE IF NOT EXISTS sessions (
+	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+	user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS orders (
+	id SERIAL PRIMARY KEY,
+	number VARCHAR NOT NULL UNIQUE,
+	accrual FLOAT,
+	status VARCHAR DEFAULT 'NEW',
+	user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	uploaded_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	UNIQUE (number, user_id)
+);
+
+CREATE TABLE IF NOT EXISTS balances (
+	user_id INTEGER PRIMARY KEY REFERENCES users(id),
+	current FLOAT NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS withdrawals (
+	id SERIAL PRIMARY KEY,
+	sum FLOAT NOT NULL,
+	balance_id INTEGER NOT NULL REFERENCES balances(user_id),
+	order_number VARCHAR NOT NULL,
+	proceeded_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,3 @@
+ALTER TABLE withdrawals ALTER COLUMN sum TYPE FLOAT USING sum::float;
+ALTER TABLE balances ALTER COLUMN current TYPE FLOAT USING current::float;
+ALTER TABLE orders ALTER COLUMN accrual TYPE FLOAT USING accrual::float;
@@ -0,0 +1,3 @@
+ALTER TABLE orders ALTER COLUMN accrual TYPE NUMERIC(20, 2) USING round(accrual::numeric, 2);
+ALTER TABLE balances ALTER COLUMN current TYPE NUMERIC(20, 2) USING round(current::numeric, 2);
+ALTER TABLE withdrawals ALTER COLUMN sum TYPE NUMERIC(20, 2) USING round(sum::numeric, 2);
@@ -0,0 +1,3 @@
+ALTER TABLE balances DROP CONSTRAINT IF EXISTS balances_current_non_negative;
+UPDATE balances b SET current = o.current FROM balances_overdrafts o WHERE o.user_id = b.user_id;
+DROP TABLE IF EXISTS balances_overdrafts;
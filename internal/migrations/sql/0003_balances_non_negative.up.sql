@@ -0,0 +1,14 @@
+-- the constraint guards new writes at once, existing rows are checked only after the repair below
+ALTER TABLE balances ADD CONSTRAINT balances_current_non_negative CHECK (current >= 0) NOT VALID;
+
+-- balances overdrawn by concurrent withdrawals before this migration are zeroed,
+-- the overdrawn amounts are kept for review
+CREATE TABLE balances_overdrafts (
+	user_id INTEGER PRIMARY KEY REFERENCES users(id),
+	current NUMERIC(20, 2) NOT NULL,
+	repaired_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+INSERT INTO balances_overdrafts (user_id, current) SELECT user_id, current FROM balances WHERE current < 0;
+UPDATE balances SET current = 0 WHERE current < 0;
+
+ALTER TABLE balances VALIDATE CONSTRAINT balances_current_non_negative;
@@ -0,0 +1,42 @@
+CREATE TABLE ledger_entries (
+	id SERIAL PRIMARY KEY,
+	transaction_id UUID NOT NULL,
+	account VARCHAR NOT NULL,
+	amount NUMERIC(20, 2) NOT NULL,
+	kind VARCHAR NOT NULL,
+	order_number VARCHAR,
+	comment TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX ledger_entries_account_idx ON ledger_entries(account, created_at);
+CREATE INDEX ledger_entries_transaction_idx ON ledger_entries(transaction_id);
+
+-- backfill history: credits for processed orders and debits for withdrawals
+WITH accruals AS (
+	SELECT gen_random_uuid() AS transaction_id, user_id, number, accrual, uploaded_at
+	FROM orders WHERE status = 'PROCESSED' AND accrual > 0
+)
+INSERT INTO ledger_entries (transaction_id, account, amount, kind, order_number, created_at)
+SELECT transaction_id, 'system:accruals', -accrual, 'ACCRUAL', number, uploaded_at FROM accruals
+UNION ALL
+SELECT transaction_id, 'user:' || user_id, accrual, 'ACCRUAL', number, uploaded_at FROM accruals;
+
+WITH debits AS (
+	SELECT gen_random_uuid() AS transaction_id, balance_id AS user_id, order_number, sum, proceeded_at
+	FROM withdrawals
+)
+INSERT INTO ledger_entries (transaction_id, account, amount, kind, order_number, created_at)
+SELECT transaction_id, 'user:' || user_id, -sum, 'WITHDRAWAL', order_number, proceeded_at FROM debits
+UNION ALL
+SELECT transaction_id, 'system:withdrawals', sum, 'WITHDRAWAL', order_number, proceeded_at FROM debits;
+
+-- whatever is not explained by orders and withdrawals becomes an opening adjustment
+WITH diffs AS (
+	SELECT gen_random_uuid() AS transaction_id, b.user_id,
+		b.current - COALESCE((SELECT SUM(e.amount) FROM ledger_entries e WHERE e.account = 'user:' || b.user_id), 0) AS diff
+	FROM balances b
+)
+INSERT INTO ledger_entries (transaction_id, account, amount, kind, comment)
+SELECT transaction_id, 'system:adjustments', -diff, 'ADJUSTMENT', 'opening balance' FROM diffs WHERE diff <> 0
+UNION ALL
+SELECT transaction_id, 'user:' || user_id, diff, 'ADJUSTMENT', 'opening balance' FROM diffs WHERE diff <> 0;
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS idempotency_keys;
+DROP INDEX IF EXISTS withdrawals_order_number_key;
+INSERT INTO withdrawals (id, sum, balance_id, order_number, proceeded_at)
+SELECT id, sum, balance_id, order_number, proceeded_at FROM withdrawals_duplicates;
+DROP TABLE IF EXISTS withdrawals_duplicates;
@@ -0,0 +1,24 @@
+-- repeated withdrawals against one order made before the unique index are moved aside for review,
+-- the earliest one stays; balances and ledger are left as they are, refunds are made by adjustments
+CREATE TABLE withdrawals_duplicates (LIKE withdrawals);
+ALTER TABLE withdrawals_duplicates ADD COLUMN removed_at TIMESTAMPTZ NOT NULL DEFAULT now();
+WITH duplicates AS (
+	DELETE FROM withdrawals w
+	WHERE EXISTS (SELECT 1 FROM withdrawals e WHERE e.order_number = w.order_number AND e.id < w.id)
+	RETURNING w.*
+)
+INSERT INTO withdrawals_duplicates SELECT * FROM duplicates;
+
+CREATE UNIQUE INDEX withdrawals_order_number_key ON withdrawals(order_number);
+
+CREATE TABLE idempotency_keys (
+	user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	key VARCHAR(255) NOT NULL,
+	request_hash VARCHAR NOT NULL,
+	status_code INTEGER,
+	content_type VARCHAR,
+	body BYTEA,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	PRIMARY KEY (user_id, key)
+);
+CREATE INDEX idempotency_keys_created_at_idx ON idempotency_keys(created_at);
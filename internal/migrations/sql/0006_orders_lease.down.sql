@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS orders_unprocessed_idx;
+ALTER TABLE orders DROP COLUMN IF EXISTS lease_until;
+ALTER TABLE orders DROP COLUMN IF EXISTS claimed_by;
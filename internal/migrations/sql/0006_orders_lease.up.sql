@@ -0,0 +1,3 @@
+ALTER TABLE orders ADD COLUMN claimed_by VARCHAR;
+ALTER TABLE orders ADD COLUMN lease_until TIMESTAMPTZ;
+CREATE INDEX orders_unprocessed_idx ON orders(id) WHERE status IN ('NEW', 'REGISTERED', 'PROCESSING');
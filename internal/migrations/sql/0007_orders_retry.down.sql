@@ -0,0 +1,4 @@
+UPDATE orders SET status = 'NEW' WHERE status = 'STUCK';
+ALTER TABLE orders DROP COLUMN IF EXISTS next_attempt_at;
+ALTER TABLE orders DROP COLUMN IF EXISTS last_error;
+ALTER TABLE orders DROP COLUMN IF EXISTS attempts;
@@ -0,0 +1,3 @@
+ALTER TABLE orders ADD COLUMN attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN last_error TEXT;
+ALTER TABLE orders ADD COLUMN next_attempt_at TIMESTAMPTZ;
@@ -0,0 +1,13 @@
+CREATE TABLE order_status_history (
+	id BIGSERIAL PRIMARY KEY,
+	order_id INTEGER NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+	from_status VARCHAR,
+	to_status VARCHAR NOT NULL,
+	reason TEXT NOT NULL DEFAULT '',
+	changed_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX order_status_history_order_id_idx ON order_status_history(order_id, changed_at);
+
+-- orders created before history was introduced get their current status as the only record
+INSERT INTO order_status_history (order_id, from_status, to_status, changed_at)
+SELECT id, NULL, COALESCE(status, 'NEW'), uploaded_at FROM orders;
@@ -0,0 +1 @@
+ALTER TABLE orders ADD COLUMN checked_at TIMESTAMPTZ;
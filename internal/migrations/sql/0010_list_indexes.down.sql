@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS withdrawals_balance_proceeded_idx;
+DROP INDEX IF EXISTS orders_user_uploaded_idx;
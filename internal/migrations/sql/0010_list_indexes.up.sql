@@ -0,0 +1,3 @@
+-- keyset pagination of user lists, see repository page queries
+CREATE INDEX orders_user_uploaded_idx ON orders(user_id, uploaded_at, id);
+CREATE INDEX withdrawals_balance_proceeded_idx ON withdrawals(balance_id, proceeded_at, id);
@@ -0,0 +1,3 @@
+ALTER TABLE sessions ADD COLUMN user_agent TEXT NOT NULL DEFAULT '';
+ALTER TABLE sessions ADD COLUMN ip TEXT NOT NULL DEFAULT '';
+CREATE INDEX sessions_user_id_idx ON sessions(user_id);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS sessions_created_at_idx;
+DROP INDEX IF EXISTS sessions_refresh_token_key;
+ALTER TABLE sessions DROP COLUMN IF EXISTS refresh_token;
+ALTER TABLE sessions DROP COLUMN IF EXISTS last_seen_at;
@@ -0,0 +1,4 @@
+ALTER TABLE sessions ADD COLUMN last_seen_at TIMESTAMPTZ NOT NULL DEFAULT now();
+ALTER TABLE sessions ADD COLUMN refresh_token UUID NOT NULL DEFAULT gen_random_uuid();
+CREATE UNIQUE INDEX sessions_refresh_token_key ON sessions(refresh_token);
+CREATE INDEX sessions_created_at_idx ON sessions(created_at);
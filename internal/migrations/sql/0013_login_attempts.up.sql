@@ -0,0 +1,20 @@
+CREATE TABLE login_attempts (
+	id BIGSERIAL PRIMARY KEY,
+	login TEXT NOT NULL,
+	ip TEXT NOT NULL,
+	result TEXT NOT NULL,
+	attempted_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX login_attempts_login_idx ON login_attempts(login, attempted_at);
+-- old audit records are purged by time
+CREATE INDEX login_attempts_attempted_at_idx ON login_attempts(attempted_at);
+
+-- failed attempts counters per login and per ip, rows are replaced on every failure
+CREATE TABLE login_failures (
+	scope TEXT NOT NULL,
+	key TEXT NOT NULL,
+	failures INTEGER NOT NULL,
+	last_failure_at TIMESTAMPTZ NOT NULL,
+	locked_until TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (scope, key)
+);
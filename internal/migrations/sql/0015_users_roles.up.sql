@@ -0,0 +1,2 @@
+ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'user' CHECK (role IN ('user', 'support', 'admin'));
+ALTER TABLE users ADD COLUMN blocked_at TIMESTAMPTZ;
@@ -0,0 +1,16 @@
+CREATE TABLE balance_adjustments (
+	id SERIAL PRIMARY KEY,
+	user_id INTEGER NOT NULL REFERENCES users(id),
+	amount NUMERIC(20, 2) NOT NULL CHECK (amount <> 0),
+	reason TEXT NOT NULL,
+	comment TEXT NOT NULL DEFAULT '',
+	order_number VARCHAR,
+	status TEXT NOT NULL CHECK (status IN ('PENDING', 'APPLIED', 'REJECTED')),
+	created_by INTEGER NOT NULL REFERENCES users(id),
+	decided_by INTEGER REFERENCES users(id),
+	decided_at TIMESTAMPTZ,
+	transaction_id UUID,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX balance_adjustments_user_id_idx ON balance_adjustments(user_id, created_at);
+CREATE INDEX balance_adjustments_pending_idx ON balance_adjustments(created_at) WHERE status = 'PENDING';
@@ -0,0 +1,3 @@
+-- session id is the bearer token, lists and revocation name sessions by public_id instead
+ALTER TABLE sessions ADD COLUMN public_id UUID NOT NULL DEFAULT gen_random_uuid();
+CREATE UNIQUE INDEX sessions_public_id_key ON sessions(public_id);
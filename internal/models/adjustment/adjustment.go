@@ -0,0 +1,90 @@
+package adjustment
+
+import (
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+
+	"lystem/internal/models/ledger"
+	"lystem/internal/models/money"
+)
+
+const (
+	StatusPending  = "PENDING"
+	StatusApplied  = "APPLIED"
+	StatusRejected = "REJECTED"
+)
+
+// Reason codes tell why the balance was changed by hand
+const (
+	ReasonGoodwill        = "GOODWILL"
+	ReasonAccrualReversal = "ACCRUAL_REVERSAL"
+	ReasonCorrection      = "CORRECTION"
+	ReasonCompensation    = "COMPENSATION"
+)
+
+var reasons = []string{ReasonGoodwill, ReasonAccrualReversal, ReasonCorrection, ReasonCompensation}
+
+var ErrUnknownReason = errors.New("неизвестная причина корректировки")
+
+// Adjustment is a manual change of user balance by staff. Positive amount credits the user, negative debits.
+// Rows are never deleted, so the table is the audit of who changed whose balance and who approved it.
+type Adjustment struct {
+	ID          int
+	UserID      int
+	Amount      money.Money
+	Reason      string
+	Comment     string
+	OrderNumber string
+	Status      string
+	// CreatedBy is the operator who made the adjustment, DecidedBy approved or rejected it
+	CreatedBy     int
+	DecidedBy     *int
+	DecidedAt     *time.Time
+	TransactionID *uuid.UUID
+	CreatedAt     time.Time
+}
+
+// Filter selects adjustments, zero fields match any
+type Filter struct {
+	ID     int
+	UserID int
+	Status string
+}
+
+func (f Filter) Match(a Adjustment) bool {
+	return (f.ID == 0 || a.ID == f.ID) && (f.UserID == 0 || a.UserID == f.UserID) && (f.Status == "" || a.Status == f.Status)
+}
+
+// Policy decides which adjustments wait for approval of another operator
+type Policy struct {
+	// ApprovalThreshold is the largest amount applied right away, zero disables approvals
+	ApprovalThreshold money.Money
+}
+
+func (p Policy) NeedsApproval(amount money.Money) bool {
+	if amount < 0 {
+		amount = -amount
+	}
+	return p.ApprovalThreshold > 0 && amount > p.ApprovalThreshold
+}
+
+func ValidReason(reason string) bool {
+	return slices.Contains(reasons, reason)
+}
+
+// Transfer builds ledger entries moving the amount between the user and system:adjustments account
+func (a *Adjustment) Transfer() []ledger.Entry {
+	comment := a.Reason
+	if a.Comment != "" {
+		comment += ": " + a.Comment
+	}
+
+	userAccount := ledger.UserAccount(a.UserID)
+	if a.Amount < 0 {
+		return ledger.Transfer(ledger.KindAdjustment, userAccount, ledger.AccountAdjustments, -a.Amount, a.OrderNumber, comment)
+	}
+	return ledger.Transfer(ledger.KindAdjustment, ledger.AccountAdjustments, userAccount, a.Amount, a.OrderNumber, comment)
+}
@@ -0,0 +1,31 @@
+package adjustment
+
+import (
+	"testing"
+
+	"lystem/internal/models/money"
+)
+
+func TestNeedsApproval(t *testing.T) {
+	tests := []struct {
+		name      string
+		threshold money.Money
+		amount    money.Money
+		want      bool
+	}{
+		{name: "approvals disabled", threshold: 0, amount: 1_000_000_00, want: false},
+		{name: "below threshold", threshold: 100_00, amount: 99_99, want: false},
+		{name: "at threshold", threshold: 100_00, amount: 100_00, want: false},
+		{name: "above threshold", threshold: 100_00, amount: 100_01, want: true},
+		{name: "debit at threshold", threshold: 100_00, amount: -100_00, want: false},
+		{name: "debit above threshold", threshold: 100_00, amount: -100_01, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := Policy{ApprovalThreshold: tt.threshold}
+			if got := p.NeedsApproval(tt.amount); got != tt.want {
+				t.Errorf("Policy{%s}.NeedsApproval(%s) = %v, want %v", tt.threshold, tt.amount, got, tt.want)
+			}
+		})
+	}
+}
@@ -1,6 +1,9 @@
 package balance
 
+import "lystem/internal/models/money"
+
 type Balance struct {
-	Current float64
-	UserID  int
+	Current   money.Money
+	Withdrawn money.Money
+	UserID    int
 }
@@ -0,0 +1,19 @@
+package idempotency
+
+import "time"
+
+// Record is a stored response for a request sent with Idempotency-Key header.
+// StatusCode is zero while the first request is still being processed.
+type Record struct {
+	UserID      int
+	Key         string
+	RequestHash string
+	StatusCode  int
+	ContentType string
+	Body        []byte
+	CreatedAt   time.Time
+}
+
+func (r *Record) Completed() bool {
+	return r.StatusCode != 0
+}
@@ -0,0 +1,32 @@
+package idempotency
+
+import "time"
+
+// TTL limits how long a key is taken. Completed record is replayed for Completed since the first request.
+// Record still in progress after InProgress is abandoned (the handler panicked or the instance died),
+// then the key may be reserved again. Zero InProgress keeps such records for Completed too.
+type TTL struct {
+	Completed  time.Duration
+	InProgress time.Duration
+}
+
+// ExpiredBefore is the creation time before which any record may be replaced
+func (t TTL) ExpiredBefore(now time.Time) time.Time {
+	return now.Add(-t.Completed)
+}
+
+// AbandonedBefore is the creation time before which record in progress may be replaced
+func (t TTL) AbandonedBefore(now time.Time) time.Time {
+	if t.InProgress <= 0 || t.InProgress > t.Completed {
+		return t.ExpiredBefore(now)
+	}
+	return now.Add(-t.InProgress)
+}
+
+// Expired reports whether the key of r may be reserved again
+func (t TTL) Expired(r *Record, now time.Time) bool {
+	if r.Completed() {
+		return r.CreatedAt.Before(t.ExpiredBefore(now))
+	}
+	return r.CreatedAt.Before(t.AbandonedBefore(now))
+}
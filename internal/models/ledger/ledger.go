@@ -0,0 +1,66 @@
+package ledger
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+
+	"lystem/internal/models/money"
+)
+
+// Entry is one leg of a ledger transaction. Every transaction moves amount
+// between two accounts, so amounts of its entries sum up to zero.
+type Entry struct {
+	ID            int
+	TransactionID uuid.UUID
+	Account       string
+	Amount        money.Money
+	Kind          string
+	OrderNumber   string
+	Comment       string
+	CreatedAt     time.Time
+}
+
+const (
+	KindAccrual    = "ACCRUAL"
+	KindWithdrawal = "WITHDRAWAL"
+	KindAdjustment = "ADJUSTMENT"
+)
+
+// System accounts, counterparts of user accounts
+const (
+	AccountAccruals    = "system:accruals"
+	AccountWithdrawals = "system:withdrawals"
+	AccountAdjustments = "system:adjustments"
+)
+
+func UserAccount(userID int) string {
+	return "user:" + strconv.Itoa(userID)
+}
+
+// Transfer builds balanced pair of entries moving amount from one account to another
+func Transfer(kind string, from, to string, amount money.Money, orderNumber string, comment string) []Entry {
+	transactionID := uuid.New()
+	return []Entry{
+		{TransactionID: transactionID, Account: from, Amount: -amount, Kind: kind, OrderNumber: orderNumber, Comment: comment},
+		{TransactionID: transactionID, Account: to, Amount: amount, Kind: kind, OrderNumber: orderNumber, Comment: comment},
+	}
+}
+
+// Mismatch is a user whose stored balance differs from the ledger sum
+type Mismatch struct {
+	UserID    int
+	Current   money.Money
+	LedgerSum money.Money
+}
+
+// Report is a result of ledger consistency check
+type Report struct {
+	Mismatches             []Mismatch
+	UnbalancedTransactions []uuid.UUID
+}
+
+func (r *Report) OK() bool {
+	return len(r.Mismatches) == 0 && len(r.UnbalancedTransactions) == 0
+}
@@ -0,0 +1,113 @@
+package lockout
+
+import "time"
+
+// Scopes of failed login counters: attempts against one login and attempts from one address
+const (
+	ScopeLogin = "login"
+	ScopeIP    = "ip"
+)
+
+// ActionPasswordReset counts password reset requests apart from login attempts, see Policy.Action
+const ActionPasswordReset = "password_reset"
+
+// Results of login attempts kept for audit
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+	// ResultLocked is an attempt rejected before password check
+	ResultLocked = "locked"
+	// ResultBlocked is an attempt with the right password to the account blocked by admin
+	ResultBlocked = "blocked"
+)
+
+// Attempt is an audit record of one login try
+type Attempt struct {
+	ID          int64
+	Login       string
+	IP          string
+	Result      string
+	AttemptedAt time.Time
+}
+
+// Counter counts failed login attempts in one scope, e.g. for one login
+type Counter struct {
+	Scope         string
+	Key           string
+	Failures      int
+	LastFailureAt time.Time
+	LockedUntil   time.Time
+}
+
+// Policy delays next attempt for the login after every failure, doubling the delay from BaseDelay up to MaxDelay,
+// and locks the login or address for Lockout after max failures within Window.
+// Address is not delayed before the lockout, users behind one NAT would slow each other down.
+// Zero max failures disables the scope.
+type Policy struct {
+	LoginMaxFailures int
+	IPMaxFailures    int
+	Window           time.Duration
+	Lockout          time.Duration
+	BaseDelay        time.Duration
+	MaxDelay         time.Duration
+	// Action keeps counters of other requests than login apart from login ones, empty for login
+	Action string
+}
+
+// Keys returns counter keys of the attempt in enabled scopes
+func (p Policy) Keys(login, ip string) []Counter {
+	var keys []Counter
+	if p.LoginMaxFailures > 0 {
+		keys = append(keys, Counter{Scope: p.Scope(ScopeLogin), Key: login})
+	}
+	if p.IPMaxFailures > 0 && ip != "" {
+		keys = append(keys, Counter{Scope: p.Scope(ScopeIP), Key: ip})
+	}
+	return keys
+}
+
+// Scope is the counter scope of the policy action, login counters keep the bare scope
+func (p Policy) Scope(scope string) string {
+	if p.Action == "" {
+		return scope
+	}
+	return p.Action + ":" + scope
+}
+
+// Fail counts one more failure, failures older than Window are forgotten
+func (p Policy) Fail(c *Counter, now time.Time) {
+	if now.Sub(c.LastFailureAt) >= p.Window {
+		c.Failures = 0
+	}
+	c.Failures++
+	c.LastFailureAt = now
+	c.LockedUntil = now.Add(p.lockFor(c.Scope, c.Failures))
+}
+
+// Forgive takes back one failure counted in advance for the attempt that turned out right
+func (p Policy) Forgive(c *Counter) {
+	if c.Failures > 0 {
+		c.Failures--
+	}
+	c.LockedUntil = c.LastFailureAt.Add(p.lockFor(c.Scope, c.Failures))
+}
+
+func (p Policy) lockFor(scope string, failures int) time.Duration {
+	if scope == p.Scope(ScopeIP) {
+		if failures >= p.IPMaxFailures {
+			return p.Lockout
+		}
+		return 0
+	}
+
+	switch {
+	case failures >= p.LoginMaxFailures:
+		return p.Lockout
+	// the first typo costs nothing
+	case failures < 2:
+		return 0
+	case failures-2 >= 32:
+		return p.MaxDelay
+	}
+	return min(p.BaseDelay<<(failures-2), p.MaxDelay)
+}
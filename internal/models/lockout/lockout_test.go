@@ -0,0 +1,113 @@
+package lockout
+
+import (
+	"testing"
+	"time"
+)
+
+var testPolicy = Policy{
+	LoginMaxFailures: 5,
+	IPMaxFailures:    3,
+	Window:           15 * time.Minute,
+	Lockout:          time.Hour,
+	BaseDelay:        time.Second,
+	MaxDelay:         4 * time.Second,
+}
+
+func TestFailDelays(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	tests := []struct {
+		scope string
+		// wantLocks is the lock after each failure in a row
+		wantLocks []time.Duration
+	}{
+		// the first typo is free, then the delay doubles up to MaxDelay and the login locks at max failures
+		{scope: ScopeLogin, wantLocks: []time.Duration{0, time.Second, 2 * time.Second, 4 * time.Second, time.Hour, time.Hour}},
+		// address is never delayed, only locked
+		{scope: ScopeIP, wantLocks: []time.Duration{0, 0, time.Hour, time.Hour}},
+	}
+	for _, tt := range tests {
+		c := Counter{Scope: tt.scope, Key: "k"}
+		for i, want := range tt.wantLocks {
+			at := now.Add(time.Duration(i) * time.Second)
+			testPolicy.Fail(&c, at)
+			if c.Failures != i+1 {
+				t.Errorf("%s failure %d: counted %d", tt.scope, i+1, c.Failures)
+			}
+			if got := c.LockedUntil.Sub(at); got != want {
+				t.Errorf("%s failure %d: locked for %v, want %v", tt.scope, i+1, got, want)
+			}
+		}
+	}
+}
+
+func TestFailDelayDoesNotOverflow(t *testing.T) {
+	p := testPolicy
+	p.LoginMaxFailures = 1000
+	c := Counter{Scope: ScopeLogin, Failures: 100, LastFailureAt: time.Now()}
+
+	at := c.LastFailureAt.Add(time.Second)
+	p.Fail(&c, at)
+	if got := c.LockedUntil.Sub(at); got != p.MaxDelay {
+		t.Fatalf("locked for %v after %d failures, want %v", got, c.Failures, p.MaxDelay)
+	}
+}
+
+func TestFailForgetsOldFailures(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	c := Counter{Scope: ScopeLogin, Failures: 4, LastFailureAt: now.Add(-testPolicy.Window)}
+
+	testPolicy.Fail(&c, now)
+	if c.Failures != 1 || !c.LockedUntil.Equal(now) {
+		t.Fatalf("failures out of window are counted: %d, locked until %v", c.Failures, c.LockedUntil)
+	}
+}
+
+func TestForgive(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	tests := []struct {
+		name         string
+		counter      Counter
+		wantFailures int
+		wantLock     time.Duration
+	}{
+		{name: "lock taken back", counter: Counter{Scope: ScopeIP, Failures: 3}, wantFailures: 2, wantLock: 0},
+		{name: "delay shortened", counter: Counter{Scope: ScopeLogin, Failures: 4}, wantFailures: 3, wantLock: 2 * time.Second},
+		{name: "nothing to forgive", counter: Counter{Scope: ScopeLogin}, wantFailures: 0, wantLock: 0},
+	}
+	for _, tt := range tests {
+		c := tt.counter
+		c.LastFailureAt = now
+		testPolicy.Forgive(&c)
+		if c.Failures != tt.wantFailures || c.LockedUntil.Sub(now) != tt.wantLock {
+			t.Errorf("%s: got %d failures locked for %v, want %d for %v",
+				tt.name, c.Failures, c.LockedUntil.Sub(now), tt.wantFailures, tt.wantLock)
+		}
+	}
+}
+
+func TestKeys(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy Policy
+		ip     string
+		want   []string
+	}{
+		{name: "both scopes", policy: testPolicy, ip: "10.0.0.1", want: []string{ScopeLogin, ScopeIP}},
+		{name: "unknown address", policy: testPolicy, ip: "", want: []string{ScopeLogin}},
+		{name: "address scope disabled", policy: Policy{LoginMaxFailures: 1}, ip: "10.0.0.1", want: []string{ScopeLogin}},
+		{name: "all disabled", policy: Policy{}, ip: "10.0.0.1", want: nil},
+	}
+	for _, tt := range tests {
+		keys := tt.policy.Keys("user", tt.ip)
+		if len(keys) != len(tt.want) {
+			t.Errorf("%s: got %v, want scopes %v", tt.name, keys, tt.want)
+			continue
+		}
+		for i, k := range keys {
+			if k.Scope != tt.want[i] {
+				t.Errorf("%s: key %d has scope %s, want %s", tt.name, i, k.Scope, tt.want[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Money is an amount of loyalty points kept in minor units (hundredths of a point),
+// so sums and differences are exact. 729.98 points is Money(72998).
+type Money int64
+
+// scale is number of decimal digits after the point
+const scale = 2
+
+// maxDigits limits input length, longer amounts do not fit into int64 minor units anyway
+const maxDigits = 40
+
+var (
+	minorPerUnit = big.NewInt(100)
+
+	errInvalidAmount = errors.New("неверный формат суммы")
+	errOutOfRange    = errors.New("сумма вне допустимого диапазона")
+	// ErrTooPrecise is returned by ParseExact for amounts with digits beyond hundredths
+	ErrTooPrecise = errors.New("сумма указана точнее сотых")
+)
+
+// Parse parses decimal amount like "729.98", "500" or "-0.5".
+// Digits beyond hundredths are rounded half away from zero.
+func Parse(s string) (Money, error) {
+	s = strings.TrimSpace(s)
+	// big.Rat also takes fractions like "1/3" and exponents, amounts are plain decimals only
+	if !isDecimal(s) {
+		return 0, errInvalidAmount
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return 0, errInvalidAmount
+	}
+	return fromRat(r)
+}
+
+// ParseExact parses amount like Parse, but rejects non-zero digits beyond hundredths
+// instead of rounding them, so amounts given by users are taken as they are.
+func ParseExact(s string) (Money, error) {
+	m, err := Parse(s)
+	if err != nil {
+		return 0, err
+	}
+	if _, fraction, _ := strings.Cut(strings.TrimSpace(s), "."); len(strings.TrimRight(fraction, "0")) > scale {
+		return 0, ErrTooPrecise
+	}
+	return m, nil
+}
+
+// isDecimal matches optional minus, digits and optional point followed by digits
+func isDecimal(s string) bool {
+	s = strings.TrimPrefix(s, "-")
+	units, fraction, hasPoint := strings.Cut(s, ".")
+	if units == "" || len(units)+len(fraction) > maxDigits || (hasPoint && fraction == "") {
+		return false
+	}
+	return onlyDigits(units) && onlyDigits(fraction)
+}
+
+func onlyDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func fromRat(r *big.Rat) (Money, error) {
+	r = new(big.Rat).Mul(r, new(big.Rat).SetInt(minorPerUnit))
+
+	// round half away from zero: q = (2*num + sign*den) / (2*den), truncated
+	num := new(big.Int).Mul(r.Num(), big.NewInt(2))
+	den := new(big.Int).Mul(r.Denom(), big.NewInt(2))
+	num.Add(num, new(big.Int).Mul(r.Denom(), big.NewInt(int64(r.Sign()))))
+	q := new(big.Int).Quo(num, den)
+
+	if !q.IsInt64() {
+		return 0, errOutOfRange
+	}
+	return Money(q.Int64()), nil
+}
+
+// String formats amount without trailing zeros: 500, 500.5, 729.98
+func (m Money) String() string {
+	sign := ""
+	minor := int64(m)
+	if minor < 0 {
+		sign = "-"
+	}
+	units := minor / 100
+	fraction := minor % 100
+	if units < 0 {
+		units = -units
+	}
+	if fraction < 0 {
+		fraction = -fraction
+	}
+
+	if fraction == 0 {
+		return sign + strconv.FormatInt(units, 10)
+	}
+	return sign + strconv.FormatInt(units, 10) + "." + strings.TrimRight(fmt.Sprintf("%0*d", scale, fraction), "0")
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON accepts JSON numbers as well as numbers in quotes
+func (m *Money) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+
+	parsed, err := Parse(strings.Trim(s, `"`))
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// UnmarshalText lets amounts be read from environment variables
+func (m *Money) UnmarshalText(text []byte) error {
+	parsed, err := Parse(string(text))
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// ScanNumeric implements pgtype.NumericScanner, NULL is scanned as zero
+func (m *Money) ScanNumeric(v pgtype.Numeric) error {
+	if !v.Valid {
+		*m = 0
+		return nil
+	}
+	if v.NaN || v.InfinityModifier != pgtype.Finite {
+		return errInvalidAmount
+	}
+
+	r := new(big.Rat).SetInt(v.Int)
+	exp := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs(v.Exp))), nil)
+	if v.Exp < 0 {
+		r.Quo(r, new(big.Rat).SetInt(exp))
+	} else {
+		r.Mul(r, new(big.Rat).SetInt(exp))
+	}
+
+	parsed, err := fromRat(r)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// NumericValue implements pgtype.NumericValuer
+func (m Money) NumericValue() (pgtype.Numeric, error) {
+	return pgtype.Numeric{Int: big.NewInt(int64(m)), Exp: -scale, Valid: true}, nil
+}
+
+func abs(v int32) int32 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,128 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Money
+		wantErr bool
+	}{
+		{in: "729.98", want: 72998},
+		{in: "500", want: 50000},
+		{in: " 500.5 ", want: 50050},
+		{in: "0.01", want: 1},
+		{in: "-0.5", want: -50},
+		{in: "0.005", want: 1},
+		{in: "0.004", want: 0},
+		{in: "-0.005", want: -1},
+		{in: "007.10", want: 710},
+		{in: "1/3", wantErr: true},
+		{in: "1e3", wantErr: true},
+		{in: "1E3", wantErr: true},
+		{in: "0x10", wantErr: true},
+		{in: ".5", wantErr: true},
+		{in: "5.", wantErr: true},
+		{in: "+5", wantErr: true},
+		{in: "--5", wantErr: true},
+		{in: "1.2.3", wantErr: true},
+		{in: "", wantErr: true},
+		{in: "-", wantErr: true},
+		{in: "abc", wantErr: true},
+		{in: "99999999999999999999", wantErr: true},
+		{in: "1111111111111111111111111111111111111111111", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := Parse(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("Parse(%q) = %d, want %d", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseExact(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Money
+		wantErr error
+	}{
+		{in: "729.98", want: 72998},
+		{in: "500", want: 50000},
+		{in: "0.5", want: 50},
+		{in: "10.000", want: 1000},
+		{in: "0.005", wantErr: ErrTooPrecise},
+		{in: "0.004", wantErr: ErrTooPrecise},
+		{in: "729.981", wantErr: ErrTooPrecise},
+		{in: "1e3", wantErr: errInvalidAmount},
+		{in: "abc", wantErr: errInvalidAmount},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseExact(tt.in)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParseExact(%q) error = %v, want %v", tt.in, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseExact(%q) = %d, want %d", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		in   Money
+		want string
+	}{
+		{in: 0, want: "0"},
+		{in: 72998, want: "729.98"},
+		{in: 50000, want: "500"},
+		{in: 50050, want: "500.5"},
+		{in: 1, want: "0.01"},
+		{in: -1, want: "-0.01"},
+		{in: -50, want: "-0.5"},
+		{in: -72998, want: "-729.98"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := tt.in.String(); got != tt.want {
+				t.Errorf("Money(%d).String() = %q, want %q", int64(tt.in), got, tt.want)
+			}
+			parsed, err := Parse(tt.in.String())
+			if err != nil || parsed != tt.in {
+				t.Errorf("Parse(String()) = %d, %v, want %d", parsed, err, tt.in)
+			}
+		})
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	for _, in := range []string{`729.98`, `"729.98"`, `0.1`, `-12`} {
+		var m Money
+		if err := json.Unmarshal([]byte(in), &m); err != nil {
+			t.Fatalf("Unmarshal(%s): %v", in, err)
+		}
+		out, err := json.Marshal(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var back Money
+		if err = json.Unmarshal(out, &back); err != nil || back != m {
+			t.Errorf("round trip of %s gave %s", in, out)
+		}
+	}
+
+	var m Money
+	if err := json.Unmarshal([]byte(`"1/3"`), &m); err == nil {
+		t.Errorf("Unmarshal accepted fraction, got %d", m)
+	}
+}
@@ -1,14 +1,51 @@
 package order
 
-import "time"
+import (
+	"math"
+	"math/rand/v2"
+	"time"
+
+	"lystem/internal/models/money"
+	"lystem/internal/models/page"
+)
 
 type Order struct {
 	ID         int
 	Number     string
-	Accrual    float64
+	Accrual    money.Money
 	UploadedAt time.Time
 	Status     string
 	UserID     int
+	// ClaimedBy is the poller instance holding the order till LeaseUntil
+	ClaimedBy  string
+	LeaseUntil time.Time
+	// Attempts counts failed accrual requests in a row, the order is not polled before NextAttemptAt
+	Attempts      int
+	LastError     string
+	NextAttemptAt time.Time
+	// CheckedAt is the time of the last answer from accrual system about the order
+	CheckedAt time.Time
+}
+
+// Filter selects a page of user orders sorted by upload time
+type Filter struct {
+	page.Query
+	// Statuses keeps orders in any of the statuses, empty means all
+	Statuses []string
+}
+
+// Results of uploading an order number
+const (
+	UploadAccepted      = "accepted"
+	UploadAlreadyYours  = "already_yours"
+	UploadOwnedByOther  = "owned_by_other"
+	UploadInvalidNumber = "invalid"
+)
+
+// Upload is the result of uploading a single number in a batch
+type Upload struct {
+	Number string
+	Result string
 }
 
 type Status string
@@ -19,4 +56,70 @@ const (
 	StatusInvalid    = "INVALID"
 	StatusProcessing = "PROCESSING"
 	StatusProcessed  = "PROCESSED"
+	// StatusStuck is set when accrual system failed to answer for the order too many times
+	StatusStuck = "STUCK"
 )
+
+// RetryPolicy describes exponential backoff between failed accrual requests
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// ScheduleRetry registers failed attempt and sets time of the next one.
+// Delay doubles with every attempt and is randomized by jitter,
+// so orders failed together are not retried at once. Without MaxDelay doubling stops
+// before the delay overflows. After MaxAttempts the order gets stuck.
+func (o *Order) ScheduleRetry(cause error, policy RetryPolicy) {
+	o.Attempts++
+	o.LastError = cause.Error()
+
+	if policy.MaxAttempts > 0 && o.Attempts >= policy.MaxAttempts {
+		o.Status = StatusStuck
+		o.NextAttemptAt = time.Time{}
+		return
+	}
+
+	delay := policy.BaseDelay
+	for i := 1; i < o.Attempts && delay <= math.MaxInt64/2 && (policy.MaxDelay <= 0 || delay < policy.MaxDelay); i++ {
+		delay *= 2
+	}
+	if policy.MaxDelay > 0 && delay > policy.MaxDelay {
+		delay = policy.MaxDelay
+	}
+
+	o.NextAttemptAt = time.Now().Add(jitter(delay))
+}
+
+// Throttle sets the next attempt after the pause accrual system asked for. The attempt is not counted
+// as failed: 429 and open circuit breaker are about all of our requests, not about this order.
+func (o *Order) Throttle(retryAfter time.Duration) {
+	o.NextAttemptAt = time.Now().Add(retryAfter)
+}
+
+// PostponeCheck sets time of the next check of the order unknown to accrual system yet.
+// The delay is the order age within [BaseDelay, MaxDelay], so checks thin out as exponentially
+// as after failures, but they are not failures and never make the order stuck.
+// Next check is not later than deadline, unless it is zero.
+func (o *Order) PostponeCheck(policy RetryPolicy, deadline time.Time) {
+	now := time.Now()
+
+	delay := max(now.Sub(o.UploadedAt), policy.BaseDelay)
+	if policy.MaxDelay > 0 && delay > policy.MaxDelay {
+		delay = policy.MaxDelay
+	}
+
+	o.NextAttemptAt = now.Add(jitter(delay))
+	if !deadline.IsZero() && o.NextAttemptAt.After(deadline) {
+		o.NextAttemptAt = deadline
+	}
+}
+
+// jitter randomizes delay within [delay/2, delay)
+func jitter(delay time.Duration) time.Duration {
+	if half := int64(delay / 2); half > 0 {
+		return time.Duration(half + rand.Int64N(half))
+	}
+	return delay
+}
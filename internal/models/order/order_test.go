@@ -0,0 +1,142 @@
+package order
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestScheduleRetry(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 4, BaseDelay: time.Second, MaxDelay: 3 * time.Second}
+	tests := []struct {
+		attempts   int
+		wantStatus string
+		minDelay   time.Duration
+		maxDelay   time.Duration
+	}{
+		{attempts: 0, wantStatus: StatusNew, minDelay: time.Second / 2, maxDelay: time.Second},
+		{attempts: 1, wantStatus: StatusNew, minDelay: time.Second, maxDelay: 2 * time.Second},
+		{attempts: 2, wantStatus: StatusNew, minDelay: 3 * time.Second / 2, maxDelay: 3 * time.Second},
+		{attempts: 3, wantStatus: StatusStuck},
+	}
+	for _, tt := range tests {
+		o := Order{Status: StatusNew, Attempts: tt.attempts}
+		before := time.Now()
+		o.ScheduleRetry(errors.New("timeout"), policy)
+
+		if o.Attempts != tt.attempts+1 || o.LastError != "timeout" || o.Status != tt.wantStatus {
+			t.Errorf("after %d attempts: got attempts %d, error %q, status %s", tt.attempts, o.Attempts, o.LastError, o.Status)
+		}
+		if tt.wantStatus == StatusStuck {
+			if !o.NextAttemptAt.IsZero() {
+				t.Errorf("stuck order has next attempt at %v", o.NextAttemptAt)
+			}
+			continue
+		}
+		if delay := o.NextAttemptAt.Sub(before); delay < tt.minDelay || delay > tt.maxDelay+time.Second/10 {
+			t.Errorf("after %d attempts: delay %v not in [%v, %v)", tt.attempts, delay, tt.minDelay, tt.maxDelay)
+		}
+	}
+}
+
+func TestScheduleRetryDelaySequence(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy RetryPolicy
+		// attempts are failures before the sequence
+		attempts int
+		// want is the delay before jitter after every failure, zero means the order is stuck
+		want []time.Duration
+	}{
+		{
+			name:   "doubles up to max delay",
+			policy: RetryPolicy{MaxAttempts: 6, BaseDelay: time.Second, MaxDelay: 5 * time.Second},
+			want:   []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second, 0},
+		},
+		{
+			name:   "doubles without max delay",
+			policy: RetryPolicy{MaxAttempts: 6, BaseDelay: time.Second},
+			want:   []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 16 * time.Second, 0},
+		},
+		{
+			name:   "never stuck without max attempts",
+			policy: RetryPolicy{BaseDelay: time.Second, MaxDelay: 2 * time.Second},
+			want:   []time.Duration{time.Second, 2 * time.Second, 2 * time.Second, 2 * time.Second},
+		},
+		{
+			name:     "does not overflow without max delay",
+			policy:   RetryPolicy{BaseDelay: time.Second},
+			attempts: 100,
+			want:     []time.Duration{time.Second << 33, time.Second << 33},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := Order{Status: StatusProcessing, Attempts: tt.attempts}
+			for i, want := range tt.want {
+				before := time.Now()
+				o.ScheduleRetry(errors.New("timeout"), tt.policy)
+
+				if want == 0 {
+					if o.Status != StatusStuck || !o.NextAttemptAt.IsZero() {
+						t.Errorf("failure %d: status %s, next attempt %v, want stuck", i+1, o.Status, o.NextAttemptAt)
+					}
+					continue
+				}
+				if o.Status != StatusProcessing {
+					t.Errorf("failure %d: status %s, want %s", i+1, o.Status, StatusProcessing)
+				}
+				if delay := o.NextAttemptAt.Sub(before); delay < want/2 || delay > want+time.Second/10 {
+					t.Errorf("failure %d: delay %v not in [%v, %v)", i+1, delay, want/2, want)
+				}
+			}
+		})
+	}
+}
+
+func TestPostponeCheck(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 1, BaseDelay: time.Second, MaxDelay: time.Hour}
+	tests := []struct {
+		name     string
+		age      time.Duration
+		deadline time.Duration
+		minDelay time.Duration
+		maxDelay time.Duration
+	}{
+		{name: "just uploaded", age: 0, minDelay: time.Second / 2, maxDelay: time.Second},
+		{name: "grows with age", age: 10 * time.Minute, minDelay: 5 * time.Minute, maxDelay: 10 * time.Minute},
+		{name: "capped", age: 24 * time.Hour, minDelay: 30 * time.Minute, maxDelay: time.Hour},
+		{name: "not after deadline", age: 24 * time.Hour, deadline: time.Minute, minDelay: time.Minute, maxDelay: time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Now()
+			o := Order{Status: StatusRegistered, UploadedAt: now.Add(-tt.age)}
+			var deadline time.Time
+			if tt.deadline > 0 {
+				deadline = now.Add(tt.deadline)
+			}
+			o.PostponeCheck(policy, deadline)
+
+			if delay := o.NextAttemptAt.Sub(now); delay < tt.minDelay || delay > tt.maxDelay+time.Second/10 {
+				t.Errorf("delay %v not in [%v, %v]", delay, tt.minDelay, tt.maxDelay)
+			}
+			if o.Attempts != 0 || o.Status != StatusRegistered {
+				t.Errorf("check counted as failure: attempts %d, status %s", o.Attempts, o.Status)
+			}
+		})
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	o := Order{Status: StatusProcessing, Attempts: 2, LastError: "timeout"}
+	before := time.Now()
+	o.Throttle(time.Minute)
+
+	if delay := o.NextAttemptAt.Sub(before); delay < time.Minute || delay > time.Minute+time.Second/10 {
+		t.Errorf("delay %v, want %v", delay, time.Minute)
+	}
+	if o.Attempts != 2 || o.LastError != "timeout" || o.Status != StatusProcessing {
+		t.Errorf("throttling counted as failure: attempts %d, error %q, status %s", o.Attempts, o.LastError, o.Status)
+	}
+}
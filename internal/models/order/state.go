@@ -0,0 +1,45 @@
+package order
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// ErrIllegalTransition is returned when the order can not move from its current status to the requested one
+var ErrIllegalTransition = errors.New("illegal order status transition")
+
+// transitions lists statuses reachable from each status. PROCESSED and INVALID are final,
+// so accrual, credited on the way to PROCESSED, is credited exactly once.
+// STUCK order may still be resolved by accrual system callback.
+var transitions = map[string][]string{
+	StatusNew:        {StatusRegistered, StatusProcessing, StatusProcessed, StatusInvalid, StatusStuck},
+	StatusRegistered: {StatusProcessing, StatusProcessed, StatusInvalid, StatusStuck},
+	StatusProcessing: {StatusProcessed, StatusInvalid, StatusStuck},
+	StatusStuck:      {StatusRegistered, StatusProcessing, StatusProcessed, StatusInvalid},
+}
+
+// StatusChange is a row of order status history
+type StatusChange struct {
+	ID        int
+	OrderID   int
+	From      string
+	To        string
+	Reason    string
+	ChangedAt time.Time
+}
+
+// IsFinal tells whether the order status can not change anymore
+func IsFinal(status string) bool {
+	return status == StatusProcessed || status == StatusInvalid
+}
+
+// CheckTransition returns nil if the order may move from one status to another.
+// Staying in the same status is allowed and is not a transition.
+func CheckTransition(from, to string) error {
+	if from == to || slices.Contains(transitions[from], to) {
+		return nil
+	}
+	return fmt.Errorf("%w: %s -> %s", ErrIllegalTransition, from, to)
+}
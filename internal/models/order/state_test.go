@@ -0,0 +1,68 @@
+package order
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckTransition(t *testing.T) {
+	tests := []struct {
+		from, to string
+		allowed  bool
+	}{
+		{StatusNew, StatusNew, true},
+		{StatusNew, StatusRegistered, true},
+		{StatusNew, StatusProcessing, true},
+		{StatusNew, StatusProcessed, true},
+		{StatusNew, StatusInvalid, true},
+		{StatusNew, StatusStuck, true},
+		{StatusRegistered, StatusNew, false},
+		{StatusRegistered, StatusProcessing, true},
+		{StatusRegistered, StatusProcessed, true},
+		{StatusProcessing, StatusRegistered, false},
+		{StatusProcessing, StatusNew, false},
+		{StatusProcessing, StatusProcessed, true},
+		{StatusProcessing, StatusInvalid, true},
+		{StatusProcessing, StatusStuck, true},
+		{StatusStuck, StatusProcessing, true},
+		{StatusStuck, StatusProcessed, true},
+		{StatusStuck, StatusNew, false},
+		// final statuses never change, so accrual is credited once
+		{StatusProcessed, StatusProcessed, true},
+		{StatusProcessed, StatusProcessing, false},
+		{StatusProcessed, StatusInvalid, false},
+		{StatusProcessed, StatusStuck, false},
+		{StatusInvalid, StatusProcessed, false},
+		{StatusInvalid, StatusRegistered, false},
+		{"UNKNOWN", StatusProcessed, false},
+	}
+	for _, tt := range tests {
+		err := CheckTransition(tt.from, tt.to)
+		if tt.allowed && err != nil {
+			t.Errorf("%s -> %s: unexpected error %v", tt.from, tt.to, err)
+		}
+		if !tt.allowed && !errors.Is(err, ErrIllegalTransition) {
+			t.Errorf("%s -> %s: got %v, want ErrIllegalTransition", tt.from, tt.to, err)
+		}
+	}
+}
+
+func TestIsFinal(t *testing.T) {
+	tests := map[string]bool{
+		StatusNew:        false,
+		StatusRegistered: false,
+		StatusProcessing: false,
+		StatusStuck:      false,
+		StatusProcessed:  true,
+		StatusInvalid:    true,
+	}
+	for status, want := range tests {
+		if got := IsFinal(status); got != want {
+			t.Errorf("IsFinal(%s) = %v, want %v", status, got, want)
+		}
+		// nothing leads out of a final status
+		if want && len(transitions[status]) > 0 {
+			t.Errorf("final status %s has transitions %v", status, transitions[status])
+		}
+	}
+}
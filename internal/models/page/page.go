@@ -0,0 +1,80 @@
+// Package page describes keyset pagination of user lists.
+package page
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultLimit is page size of requests with cursor but without limit,
+	// lists requested with neither of them are returned whole as before pagination
+	DefaultLimit = 100
+	MaxLimit     = 1000
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor points at the last item of the previous page: lists are sorted by time and then by id,
+// so the next page starts right after (At, ID) in the requested direction
+type Cursor struct {
+	At time.Time
+	ID int
+}
+
+// Encode makes opaque cursor for clients
+func (c Cursor) Encode() string {
+	raw := strconv.FormatInt(c.At.UnixNano(), 10) + ":" + strconv.Itoa(c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func DecodeCursor(s string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	at, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+	nanos, err := strconv.ParseInt(at, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	cursorID, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &Cursor{At: time.Unix(0, nanos), ID: cursorID}, nil
+}
+
+// Query is common part of list filters
+type Query struct {
+	// Limit is page size, zero means the whole list
+	Limit int
+	// Desc sorts from the newest to the oldest
+	Desc  bool
+	After *Cursor
+	// From and To limit item time to [From, To), zero value means no limit
+	From time.Time
+	To   time.Time
+}
+
+// Follows tells whether item with time at and id goes after the cursor in query order
+func (q Query) Follows(at time.Time, id int) bool {
+	if q.After == nil {
+		return true
+	}
+	if q.Desc {
+		return at.Before(q.After.At) || at.Equal(q.After.At) && id < q.After.ID
+	}
+	return at.After(q.After.At) || at.Equal(q.After.At) && id > q.After.ID
+}
+
+// InRange tells whether time at is within [From, To)
+func (q Query) InRange(at time.Time) bool {
+	return (q.From.IsZero() || !at.Before(q.From)) && (q.To.IsZero() || at.Before(q.To))
+}
@@ -0,0 +1,73 @@
+package page
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	tests := []Cursor{
+		{At: time.Date(2024, 3, 1, 12, 30, 0, 123456789, time.UTC), ID: 42},
+		{At: time.Unix(0, 0), ID: 0},
+		{At: time.Date(1969, 12, 31, 23, 59, 59, 0, time.UTC), ID: 1},
+		{At: time.Date(2262, 1, 1, 0, 0, 0, 0, time.UTC), ID: 1<<31 - 1},
+	}
+	for _, c := range tests {
+		got, err := DecodeCursor(c.Encode())
+		if err != nil {
+			t.Fatalf("DecodeCursor(%+v): %v", c, err)
+		}
+		if !got.At.Equal(c.At) || got.ID != c.ID {
+			t.Errorf("round trip of %+v gave %+v", c, *got)
+		}
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	encode := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+	tests := map[string]string{
+		"empty":          "",
+		"not base64":     "!!!",
+		"padded base64":  base64.URLEncoding.EncodeToString([]byte("12:1")),
+		"no separator":   encode("12345"),
+		"time not int":   encode("now:1"),
+		"id not int":     encode("12345:x"),
+		"time overflows": encode("99999999999999999999:1"),
+	}
+	for name, s := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := DecodeCursor(s); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("DecodeCursor(%q) = %v, want ErrInvalidCursor", s, err)
+			}
+		})
+	}
+}
+
+func TestQueryFollows(t *testing.T) {
+	at := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	cursor := &Cursor{At: at, ID: 10}
+	tests := []struct {
+		name string
+		desc bool
+		at   time.Time
+		id   int
+		want bool
+	}{
+		{"asc later", false, at.Add(time.Second), 1, true},
+		{"asc same time bigger id", false, at, 11, true},
+		{"asc cursor itself", false, at, 10, false},
+		{"asc earlier", false, at.Add(-time.Second), 99, false},
+		{"desc earlier", true, at.Add(-time.Second), 99, true},
+		{"desc same time smaller id", true, at, 9, true},
+		{"desc cursor itself", true, at, 10, false},
+		{"desc later", true, at.Add(time.Second), 1, false},
+	}
+	for _, tt := range tests {
+		q := Query{Desc: tt.desc, After: cursor}
+		if got := q.Follows(tt.at, tt.id); got != tt.want {
+			t.Errorf("%s: Follows = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
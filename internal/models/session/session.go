@@ -7,7 +7,30 @@ import (
 )
 
 type Session struct {
-	ID        uuid.UUID
-	CreatedAt time.Time
-	UserID    int
+	// ID is the bearer token of the session, it is never shown to the user
+	ID uuid.UUID
+	// PublicID names the session in lists and revocation, it survives refreshes
+	PublicID   uuid.UUID
+	CreatedAt  time.Time
+	LastSeenAt time.Time
+	UserID     int
+	// RefreshToken issues a new session ID when the current one is idle for too long
+	RefreshToken uuid.UUID
+	Client
+}
+
+// Client describes device the session was started from
+type Client struct {
+	UserAgent string
+	IP        string
+}
+
+// maxUserAgentLen keeps stored user agent reasonably short, browsers send about 150 characters
+const maxUserAgentLen = 512
+
+func NewClient(userAgent, ip string) Client {
+	if len(userAgent) > maxUserAgentLen {
+		userAgent = userAgent[:maxUserAgentLen]
+	}
+	return Client{UserAgent: userAgent, IP: ip}
 }
@@ -0,0 +1,60 @@
+package session
+
+import "time"
+
+// maxTouchGranularity limits how stale LastSeenAt may get before it is renewed
+const maxTouchGranularity = time.Minute
+
+// TTL limits session lifetime. Absolute counts since login and survives refreshes,
+// Idle counts since the last request made with the session. Zero duration disables the limit.
+type TTL struct {
+	Absolute time.Duration
+	Idle     time.Duration
+}
+
+// Expired reports whether the session can not authorize requests anymore
+func (t TTL) Expired(s *Session, now time.Time) bool {
+	return t.Ended(s, now) || (t.Idle > 0 && now.Sub(s.LastSeenAt) >= t.Idle)
+}
+
+// Ended reports whether the session can not be refreshed anymore
+func (t TTL) Ended(s *Session, now time.Time) bool {
+	return t.Absolute > 0 && now.Sub(s.CreatedAt) >= t.Absolute
+}
+
+// CreatedAfter is the earliest login time of sessions not ended by now
+func (t TTL) CreatedAfter(now time.Time) time.Time {
+	if t.Absolute <= 0 {
+		return time.Time{}
+	}
+	return now.Add(-t.Absolute)
+}
+
+// NeedsTouch reports whether LastSeenAt should be renewed.
+// Renewal is skipped for sessions seen recently, so not every request writes to the storage.
+func (t TTL) NeedsTouch(s *Session, now time.Time) bool {
+	if t.Idle <= 0 {
+		return false
+	}
+	return now.Sub(s.LastSeenAt) >= min(t.Idle/10, maxTouchGranularity)
+}
+
+// ExpiresAt is when the session expires if no more requests are made, zero time if never
+func (t TTL) ExpiresAt(s *Session) time.Time {
+	var at time.Time
+	if t.Idle > 0 {
+		at = s.LastSeenAt.Add(t.Idle)
+	}
+	if end := t.EndsAt(s); !end.IsZero() && (at.IsZero() || end.Before(at)) {
+		at = end
+	}
+	return at
+}
+
+// EndsAt is when the session can not be refreshed anymore, zero time if never
+func (t TTL) EndsAt(s *Session) time.Time {
+	if t.Absolute <= 0 {
+		return time.Time{}
+	}
+	return s.CreatedAt.Add(t.Absolute)
+}
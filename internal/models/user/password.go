@@ -0,0 +1,134 @@
+package user
+
+import (
+	"crypto/rand"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// PasswordParams are argon2id cost parameters, defaults follow OWASP recommendation
+type PasswordParams struct {
+	Memory  uint32 // KiB
+	Time    uint32
+	Threads uint8
+	SaltLen uint32
+	KeyLen  uint32
+}
+
+var DefaultPasswordParams = PasswordParams{Memory: 19 * 1024, Time: 2, Threads: 1, SaltLen: 16, KeyLen: 32}
+
+const argon2idPrefix = "$argon2id$"
+
+var errHashFormat = errors.New("unsupported password hash format")
+
+// dummyHash is compared against when user is not found, so response time does not reveal existing logins
+var dummyHash = mustHash("dummy password", DefaultPasswordParams)
+
+// SetPassword hashes password with argon2id and random per-user salt.
+// Result is PHC string: $argon2id$v=19$m=19456,t=2,p=1$<salt>$<hash>
+func (u *User) SetPassword(password string) error {
+	hash, err := hashPassword(password, DefaultPasswordParams)
+	if err != nil {
+		return err
+	}
+	u.HashedPassword = hash
+	return nil
+}
+
+// ValidatePassword compares password with the stored hash in constant time.
+// legacySalt is the global salt of SHA-512 hashes made before argon2id.
+func (u *User) ValidatePassword(password string, legacySalt string) bool {
+	if !strings.HasPrefix(u.HashedPassword, argon2idPrefix) {
+		given := legacyHash(password, legacySalt)
+		return subtle.ConstantTimeCompare([]byte(given), []byte(u.HashedPassword)) == 1
+	}
+
+	params, salt, key, err := decodeHash(u.HashedPassword)
+	if err != nil {
+		return false
+	}
+	given := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, uint32(len(key)))
+	return subtle.ConstantTimeCompare(given, key) == 1
+}
+
+// NeedsRehash tells whether the hash is legacy or made with parameters weaker than current defaults
+func (u *User) NeedsRehash() bool {
+	params, _, _, err := decodeHash(u.HashedPassword)
+	if err != nil {
+		return true
+	}
+	return params.Memory != DefaultPasswordParams.Memory ||
+		params.Time != DefaultPasswordParams.Time ||
+		params.Threads != DefaultPasswordParams.Threads
+}
+
+// SpendPasswordCheck burns the same time as ValidatePassword for unknown users
+func SpendPasswordCheck(password string) {
+	u := User{HashedPassword: dummyHash}
+	u.ValidatePassword(password, "")
+}
+
+func hashPassword(password string, params PasswordParams) (string, error) {
+	salt := make([]byte, params.SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, params.KeyLen)
+
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2idPrefix, argon2.Version,
+		params.Memory, params.Time, params.Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func mustHash(password string, params PasswordParams) string {
+	hash, err := hashPassword(password, params)
+	if err != nil {
+		panic(err)
+	}
+	return hash
+}
+
+func decodeHash(hash string) (PasswordParams, []byte, []byte, error) {
+	var params PasswordParams
+
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", salt, key
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return params, nil, nil, errHashFormat
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, errHashFormat
+	}
+	// argon2 panics on zero time or threads
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Time, &params.Threads); err != nil ||
+		params.Time == 0 || params.Threads == 0 {
+		return params, nil, nil, errHashFormat
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, errHashFormat
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, errHashFormat
+	}
+	params.SaltLen, params.KeyLen = uint32(len(salt)), uint32(len(key))
+
+	return params, salt, key, nil
+}
+
+// legacyHash is hex SHA-512 of password with global salt appended
+func legacyHash(password string, salt string) string {
+	sum := sha512.Sum512([]byte(password + salt))
+	return hex.EncodeToString(sum[:])
+}
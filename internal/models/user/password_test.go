@@ -0,0 +1,154 @@
+package user
+
+import (
+	"strings"
+	"testing"
+)
+
+// hex SHA-512 of "password" + "pepper"
+const legacyPasswordHash = "7542291488234e03d5c2cc832aaf77449b65fd92d6021ded11bdfaa51b565f47acf7dd28a6138ad7d6ed4a2ed57ffc4719db0423cc43a9311f7c08ef2ee95e54"
+
+func TestSetPassword(t *testing.T) {
+	var u, same User
+	if err := u.SetPassword("password"); err != nil {
+		t.Fatal(err)
+	}
+	if err := same.SetPassword("password"); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(u.HashedPassword, "$argon2id$v=19$m=19456,t=2,p=1$") {
+		t.Errorf("unexpected hash format %s", u.HashedPassword)
+	}
+	if u.HashedPassword == same.HashedPassword {
+		t.Error("same password is hashed with the same salt")
+	}
+
+	params, salt, key, err := decodeHash(u.HashedPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params != DefaultPasswordParams {
+		t.Errorf("got params %+v, want %+v", params, DefaultPasswordParams)
+	}
+	if len(salt) != int(DefaultPasswordParams.SaltLen) || len(key) != int(DefaultPasswordParams.KeyLen) {
+		t.Errorf("got salt of %d and key of %d bytes", len(salt), len(key))
+	}
+
+	if !u.ValidatePassword("password", "") {
+		t.Error("password does not match its hash")
+	}
+	if u.ValidatePassword("Password", "") {
+		t.Error("wrong password matches the hash")
+	}
+}
+
+func TestValidatePasswordWithCustomParams(t *testing.T) {
+	u := User{HashedPassword: mustHash("password", PasswordParams{Memory: 1024, Time: 1, Threads: 2, SaltLen: 8, KeyLen: 16})}
+	if !u.ValidatePassword("password", "") {
+		t.Error("hash made with other params is not validated by its own params")
+	}
+}
+
+func TestMalformedHash(t *testing.T) {
+	valid := mustHash("password", DefaultPasswordParams)
+	parts := strings.Split(valid, "$")
+	replace := func(i int, part string) string {
+		changed := append([]string(nil), parts...)
+		changed[i] = part
+		return strings.Join(changed, "$")
+	}
+
+	tests := map[string]string{
+		"empty":            "",
+		"prefix only":      "$argon2id$",
+		"missing key":      strings.Join(parts[:5], "$"),
+		"extra part":       valid + "$extra",
+		"argon2i":          replace(1, "argon2i"),
+		"old version":      replace(2, "v=16"),
+		"no version":       replace(2, "19"),
+		"params order":     replace(3, "t=2,m=19456,p=1"),
+		"missing params":   replace(3, "m=19456,t=2"),
+		"zero time":        replace(3, "m=19456,t=0,p=1"),
+		"zero threads":     replace(3, "m=19456,t=2,p=0"),
+		"threads overflow": replace(3, "m=19456,t=2,p=256"),
+		"salt not base64":  replace(4, "!!!"),
+		"key not base64":   replace(5, "!!!"),
+		"empty key":        replace(5, ""),
+		"padded key":       replace(5, parts[5]+"=="),
+	}
+	for name, hash := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, _, _, err := decodeHash(hash); err != errHashFormat {
+				t.Errorf("got error %v, want %v", err, errHashFormat)
+			}
+			u := User{HashedPassword: hash}
+			if u.ValidatePassword("password", "") {
+				t.Error("malformed hash matches the password")
+			}
+			if !u.NeedsRehash() {
+				t.Error("malformed hash does not need rehash")
+			}
+		})
+	}
+}
+
+func TestLegacyPassword(t *testing.T) {
+	u := User{HashedPassword: legacyPasswordHash}
+
+	tests := []struct {
+		name     string
+		password string
+		salt     string
+		valid    bool
+	}{
+		{name: "right password and salt", password: "password", salt: "pepper", valid: true},
+		{name: "wrong password", password: "passwort", salt: "pepper"},
+		{name: "wrong salt", password: "password", salt: "salt"},
+		{name: "no salt", password: "password"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := u.ValidatePassword(tt.password, tt.salt); got != tt.valid {
+				t.Errorf("got %t, want %t", got, tt.valid)
+			}
+		})
+	}
+
+	upper := User{HashedPassword: strings.ToUpper(legacyPasswordHash)}
+	if upper.ValidatePassword("password", "pepper") {
+		t.Error("legacy hash is not compared as stored")
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	weaker := DefaultPasswordParams
+	weaker.Time = 1
+	lessMemory := DefaultPasswordParams
+	lessMemory.Memory = 1024
+	moreThreads := DefaultPasswordParams
+	moreThreads.Threads = 4
+	longerKey := DefaultPasswordParams
+	longerKey.SaltLen, longerKey.KeyLen = 32, 64
+
+	tests := []struct {
+		name string
+		hash string
+		want bool
+	}{
+		{name: "current params", hash: mustHash("password", DefaultPasswordParams), want: false},
+		{name: "salt and key length are not cost", hash: mustHash("password", longerKey), want: false},
+		{name: "legacy", hash: legacyPasswordHash, want: true},
+		{name: "fewer passes", hash: mustHash("password", weaker), want: true},
+		{name: "less memory", hash: mustHash("password", lessMemory), want: true},
+		{name: "other threads", hash: mustHash("password", moreThreads), want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := User{HashedPassword: tt.hash}
+			if got := u.NeedsRehash(); got != tt.want {
+				t.Errorf("got %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
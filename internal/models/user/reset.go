@@ -0,0 +1,40 @@
+package user
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+)
+
+// resetTokenLen is random bytes in the reset token, 256 bits can not be guessed within token TTL
+const resetTokenLen = 32
+
+// PasswordReset lets the user set a new password once before ExpiresAt.
+// Only token hash is stored, so leaked table can not be used to take over accounts.
+type PasswordReset struct {
+	ID        int
+	UserID    int
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// NewPasswordReset returns the reset and the token to send to the user
+func NewPasswordReset(userID int, ttl time.Duration) (*PasswordReset, string, error) {
+	b := make([]byte, resetTokenLen)
+	if _, err := rand.Read(b); err != nil {
+		return nil, "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	return &PasswordReset{UserID: userID, TokenHash: HashResetToken(token), ExpiresAt: time.Now().Add(ttl)}, token, nil
+}
+
+// HashResetToken is how the token is looked up, random token needs no salt or slow hash
+func HashResetToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,20 @@
+package user
+
+import "slices"
+
+// Roles: support looks users' data up and re-checks orders, admin also blocks accounts and manages roles
+const (
+	RoleUser    = "user"
+	RoleSupport = "support"
+	RoleAdmin   = "admin"
+)
+
+var roles = []string{RoleUser, RoleSupport, RoleAdmin}
+
+func ValidRole(role string) bool {
+	return slices.Contains(roles, role)
+}
+
+func (u *User) HasRole(roles ...string) bool {
+	return slices.Contains(roles, u.Role)
+}
@@ -1,35 +1,18 @@
 package user
 
-import (
-	"crypto/sha512"
-	"encoding/hex"
-)
+import "time"
 
 type User struct {
-	ID             int
-	Login          string
+	ID    int
+	Login string
+	// HashedPassword is argon2id hash in PHC string format, see password.go;
+	// users registered before it keep hex SHA-512 hash until the next login
 	HashedPassword string
+	Role           string
+	// BlockedAt is set while the account is blocked by admin
+	BlockedAt *time.Time
 }
 
-func (u *User) SetHashedPassword(password string, salt string) {
-	u.HashedPassword = u.buildHashedPassword(password, salt)
-}
-func (u *User) buildHashedPassword(password string, salt string) string {
-	passwordBytes := []byte(password)
-	sha512Hasher := sha512.New()
-	passwordBytes = append(passwordBytes, []byte(salt)...)
-	// Write password bytes to the hasher
-	sha512Hasher.Write(passwordBytes)
-	// Get the SHA-512 hashed password
-	hashedPasswordBytes := sha512Hasher.Sum(nil)
-	// Convert the password to a hex string
-	hashedPasswordHex := hex.EncodeToString(hashedPasswordBytes)
-
-	return hashedPasswordHex
-}
-
-func (u *User) ValidatePassword(password string, salt string) bool {
-	givenPasswordHash := u.buildHashedPassword(password, salt)
-
-	return givenPasswordHash == u.HashedPassword
+func (u *User) Blocked() bool {
+	return u.BlockedAt != nil
 }
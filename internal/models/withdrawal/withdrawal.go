@@ -1,10 +1,14 @@
 package withdrawal
 
-import "time"
+import (
+	"time"
+
+	"lystem/internal/models/money"
+)
 
 type Withdrawal struct {
 	ID          int
-	Sum         float64
+	Sum         money.Money
 	ProcessedAt time.Time
 	OrderNumber string
 	BalanceID   int
@@ -0,0 +1,48 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+const KindPasswordReset = "password_reset"
+
+// Notifier delivers messages to users. Users are known by login only,
+// so the implementation decides how to reach them, e.g. looks the address up in a directory.
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+type Notification struct {
+	Login string `json:"login"`
+	Kind  string `json:"kind"`
+	Text  string `json:"text"`
+	// Data carries values for message templates, e.g. reset token
+	Data      map[string]string `json:"data,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+// Writer writes notifications as JSON lines to a file or stdout, so flows needing them work offline
+type Writer struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+func (n *Writer) Notify(_ context.Context, notification Notification) error {
+	line, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	_, err = n.w.Write(append(line, '\n'))
+	return err
+}
@@ -0,0 +1,67 @@
+package passwordreset
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"lystem/internal/notifier"
+	"lystem/internal/request"
+	"lystem/internal/storage"
+	"lystem/internal/usecase"
+)
+
+// queueSize bounds requests waiting for delivery, further requests are dropped until the worker catches up
+const queueSize = 256
+
+// Queue creates and delivers password resets in background. The request is answered before the login
+// is looked up, so the response time does not tell whether the login is registered.
+type Queue struct {
+	storage  storage.Storage
+	notifier notifier.Notifier
+	logger   *zap.SugaredLogger
+	ttl      time.Duration
+	requests chan request.RequestPasswordReset
+}
+
+// New creates the queue of resets valid for ttl
+func New(db storage.Storage, n notifier.Notifier, ttl time.Duration, logger *zap.Logger) *Queue {
+	return &Queue{
+		storage:  db,
+		notifier: n,
+		logger:   logger.Sugar(),
+		ttl:      ttl,
+		requests: make(chan request.RequestPasswordReset, queueSize),
+	}
+}
+
+// Enqueue schedules the reset without waiting, the request is dropped if the queue has no room.
+// Callers throttle requests per login and address, so one client can not fill the queue.
+func (q *Queue) Enqueue(resetRequest request.RequestPasswordReset) {
+	select {
+	case q.requests <- resetRequest:
+	default:
+		// the user did not get a token and may ask again
+		q.logger.Warnw("password resets queue is full, request dropped")
+	}
+}
+
+func (q *Queue) StartDelivery(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	passwordUsecase := usecase.NewPasswordUsecase(q.storage, q.notifier, "")
+	for {
+		select {
+		case resetRequest := <-q.requests:
+			if err := passwordUsecase.RequestReset(ctx, resetRequest, q.ttl); err != nil {
+				q.logger.Errorw("failed to deliver password reset", "error", err)
+			}
+		case <-ctx.Done():
+			// the user did not get a token and may ask again
+			q.logger.Infow("4 Gracefully stop password resets delivery", "dropped", len(q.requests))
+			return
+		}
+	}
+}
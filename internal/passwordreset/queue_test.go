@@ -0,0 +1,86 @@
+package passwordreset_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"lystem/internal/models/user"
+	"lystem/internal/notifier"
+	"lystem/internal/passwordreset"
+	"lystem/internal/request"
+	"lystem/pkg/memory"
+)
+
+type recorder struct {
+	mu   sync.Mutex
+	sent []notifier.Notification
+}
+
+func (r *recorder) Notify(_ context.Context, n notifier.Notification) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sent = append(r.sent, n)
+	return nil
+}
+
+func (r *recorder) logins() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var logins []string
+	for _, n := range r.sent {
+		logins = append(logins, n.Login)
+	}
+	return logins
+}
+
+func TestEnqueueDoesNotWait(t *testing.T) {
+	q := passwordreset.New(memory.NewStorage(), &recorder{}, time.Minute, zap.NewNop())
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		// no worker takes requests, the ones beyond the queue size are dropped
+		for i := 0; i < 10000; i++ {
+			q.Enqueue(request.RequestPasswordReset{Login: "user"})
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Enqueue waits for room in the queue")
+	}
+}
+
+func TestOnlyKnownLoginsGetToken(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	db := memory.NewStorage()
+	if _, err := db.CreateUser(ctx, &user.User{Login: "known"}); err != nil {
+		t.Fatal(err)
+	}
+	sent := &recorder{}
+	q := passwordreset.New(db, sent, time.Minute, zap.NewNop())
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go q.StartDelivery(ctx, &wg)
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	for _, login := range []string{"unknown", "known"} {
+		q.Enqueue(request.RequestPasswordReset{Login: login})
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for len(sent.logins()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if logins := sent.logins(); len(logins) != 1 || logins[0] != "known" {
+		t.Errorf("tokens sent to %v, want [known]", logins)
+	}
+}
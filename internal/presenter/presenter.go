@@ -3,8 +3,13 @@ package presenter
 import (
 	"time"
 
+	"lystem/internal/models/adjustment"
 	"lystem/internal/models/balance"
+	"lystem/internal/models/ledger"
+	"lystem/internal/models/money"
 	"lystem/internal/models/order"
+	"lystem/internal/models/session"
+	"lystem/internal/models/user"
 	"lystem/internal/models/withdrawal"
 )
 
@@ -23,37 +28,208 @@ func NewFailure(err error) *Common {
 }
 
 type ResponseOrder struct {
-	Number     string    `json:"number"`
-	Status     string    `json:"status"`
-	Accrual    float64   `json:"accrual"`
-	UploadedAt time.Time `json:"uploaded_at"`
+	Number     string      `json:"number"`
+	Status     string      `json:"status"`
+	Accrual    money.Money `json:"accrual"`
+	UploadedAt time.Time   `json:"uploaded_at"`
 }
 
+// NewOrdersResponse presents orders to their owner in statuses of the public contract
 func NewOrdersResponse(orders []order.Order) []ResponseOrder {
+	return newOrdersResponse(orders, userStatus)
+}
+
+// NewAdminOrdersResponse presents orders with internal statuses, STUCK included
+func NewAdminOrdersResponse(orders []order.Order) []ResponseOrder {
+	return newOrdersResponse(orders, adminStatus)
+}
+
+func newOrdersResponse(orders []order.Order, status func(string) string) []ResponseOrder {
 	var rOrders []ResponseOrder
 	for _, o := range orders {
-		rOrders = append(rOrders, ResponseOrder{Number: o.Number, Status: o.Status, Accrual: o.Accrual, UploadedAt: o.UploadedAt})
+		rOrders = append(rOrders, ResponseOrder{Number: o.Number, Status: status(o.Status), Accrual: o.Accrual, UploadedAt: o.UploadedAt})
 	}
 	return rOrders
 }
 
-type ResponseBalance struct {
-	Current   float64 `json:"current"`
-	Withdrawn float64 `json:"withdrawn"`
+// userStatus hides STUCK from users: for them the order is still being processed
+func userStatus(status string) string {
+	if status == order.StatusStuck {
+		return order.StatusProcessing
+	}
+	return status
 }
 
-func NewBalanceResponse(b *balance.Balance, ws []withdrawal.Withdrawal) ResponseBalance {
-	var withdrawnSum float64
-	for _, w := range ws {
-		withdrawnSum += w.Sum
+func adminStatus(status string) string {
+	return status
+}
+
+type ResponseUpload struct {
+	Number string `json:"number"`
+	Result string `json:"result"`
+}
+
+func NewUploadsResponse(uploads []order.Upload) []ResponseUpload {
+	responses := make([]ResponseUpload, 0, len(uploads))
+	for _, u := range uploads {
+		responses = append(responses, ResponseUpload{Number: u.Number, Result: u.Result})
+	}
+	return responses
+}
+
+type ResponseOrderDetails struct {
+	ResponseOrder
+	CheckedAt   *time.Time            `json:"checked_at,omitempty"`
+	Withdrawals []ResponseWithdrawals `json:"withdrawals"`
+}
+
+// NewOrderDetailsResponse presents the order to its owner in statuses of the public contract
+func NewOrderDetailsResponse(o *order.Order, ws []withdrawal.Withdrawal) ResponseOrderDetails {
+	return newOrderDetailsResponse(o, ws, userStatus)
+}
+
+// NewAdminOrderDetailsResponse presents the order with internal status, STUCK included
+func NewAdminOrderDetailsResponse(o *order.Order, ws []withdrawal.Withdrawal) ResponseOrderDetails {
+	return newOrderDetailsResponse(o, ws, adminStatus)
+}
+
+func newOrderDetailsResponse(o *order.Order, ws []withdrawal.Withdrawal, status func(string) string) ResponseOrderDetails {
+	details := ResponseOrderDetails{
+		ResponseOrder: ResponseOrder{Number: o.Number, Status: status(o.Status), Accrual: o.Accrual, UploadedAt: o.UploadedAt},
+		Withdrawals:   NewWithdrawalsResponse(ws),
+	}
+	if !o.CheckedAt.IsZero() {
+		details.CheckedAt = &o.CheckedAt
+	}
+	if details.Withdrawals == nil {
+		details.Withdrawals = []ResponseWithdrawals{}
+	}
+	return details
+}
+
+type ResponseStatusChange struct {
+	From      string    `json:"from,omitempty"`
+	To        string    `json:"to"`
+	Reason    string    `json:"reason,omitempty"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// NewOrderHistoryResponse presents status changes to the order owner, changes to and from STUCK
+// look like PROCESSING to PROCESSING and are left out
+func NewOrderHistoryResponse(changes []order.StatusChange) []ResponseStatusChange {
+	var responses []ResponseStatusChange
+	for _, c := range changes {
+		from, to := c.From, userStatus(c.To)
+		if from != "" {
+			from = userStatus(from)
+		}
+		if from == to {
+			continue
+		}
+		responses = append(responses, ResponseStatusChange{From: from, To: to, Reason: c.Reason, ChangedAt: c.ChangedAt})
+	}
+	return responses
+}
+
+type ResponseToken struct {
+	Token            string     `json:"token"`
+	RefreshToken     string     `json:"refresh_token"`
+	ExpiresAt        *time.Time `json:"expires_at,omitempty"`
+	RefreshExpiresAt *time.Time `json:"refresh_expires_at,omitempty"`
+	// signed access token is issued only when signing keys are configured
+	AccessToken     string     `json:"access_token,omitempty"`
+	AccessExpiresAt *time.Time `json:"access_expires_at,omitempty"`
+}
+
+// NewTokenResponse tells when the token expires without requests and when it can not be refreshed anymore
+func NewTokenResponse(s *session.Session, ttl session.TTL) ResponseToken {
+	response := ResponseToken{Token: s.ID.String(), RefreshToken: s.RefreshToken.String()}
+	if at := ttl.ExpiresAt(s); !at.IsZero() {
+		response.ExpiresAt = &at
+	}
+	if at := ttl.EndsAt(s); !at.IsZero() {
+		response.RefreshExpiresAt = &at
 	}
-	return ResponseBalance{Current: b.Current, Withdrawn: withdrawnSum}
+	return response
+}
+
+type ResponseSession struct {
+	ID         string    `json:"id"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+	IP         string    `json:"ip,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	Current    bool      `json:"current"`
+}
+
+// NewSessionsResponse shows sessions by public id and marks the session the request is made with
+func NewSessionsResponse(sessions []session.Session, currentSessionID string) []ResponseSession {
+	var responses []ResponseSession
+	for _, s := range sessions {
+		id := s.PublicID.String()
+		responses = append(responses, ResponseSession{ID: id, UserAgent: s.UserAgent, IP: s.IP, CreatedAt: s.CreatedAt, LastSeenAt: s.LastSeenAt, Current: id == currentSessionID})
+	}
+	return responses
+}
+
+type ResponseUser struct {
+	ID        int        `json:"id"`
+	Login     string     `json:"login"`
+	Role      string     `json:"role"`
+	BlockedAt *time.Time `json:"blocked_at,omitempty"`
+}
+
+func NewUserResponse(u *user.User) ResponseUser {
+	return ResponseUser{ID: u.ID, Login: u.Login, Role: u.Role, BlockedAt: u.BlockedAt}
+}
+
+type ResponseAdjustment struct {
+	ID            int         `json:"id"`
+	UserID        int         `json:"user_id"`
+	Amount        money.Money `json:"amount"`
+	Reason        string      `json:"reason"`
+	Comment       string      `json:"comment,omitempty"`
+	Order         string      `json:"order,omitempty"`
+	Status        string      `json:"status"`
+	CreatedBy     int         `json:"created_by"`
+	DecidedBy     *int        `json:"decided_by,omitempty"`
+	DecidedAt     *time.Time  `json:"decided_at,omitempty"`
+	TransactionID string      `json:"transaction_id,omitempty"`
+	CreatedAt     time.Time   `json:"created_at"`
+}
+
+func NewAdjustmentResponse(a *adjustment.Adjustment) ResponseAdjustment {
+	r := ResponseAdjustment{
+		ID: a.ID, UserID: a.UserID, Amount: a.Amount, Reason: a.Reason, Comment: a.Comment, Order: a.OrderNumber,
+		Status: a.Status, CreatedBy: a.CreatedBy, DecidedBy: a.DecidedBy, DecidedAt: a.DecidedAt, CreatedAt: a.CreatedAt,
+	}
+	if a.TransactionID != nil {
+		r.TransactionID = a.TransactionID.String()
+	}
+	return r
+}
+
+func NewAdjustmentsResponse(adjustments []adjustment.Adjustment) []ResponseAdjustment {
+	var responses []ResponseAdjustment
+	for i := range adjustments {
+		responses = append(responses, NewAdjustmentResponse(&adjustments[i]))
+	}
+	return responses
+}
+
+type ResponseBalance struct {
+	Current   money.Money `json:"current"`
+	Withdrawn money.Money `json:"withdrawn"`
+}
+
+func NewBalanceResponse(b *balance.Balance) ResponseBalance {
+	return ResponseBalance{Current: b.Current, Withdrawn: b.Withdrawn}
 }
 
 type ResponseWithdrawals struct {
-	Order       string    `json:"order"`
-	Sum         float64   `json:"sum"`
-	ProcessedAt time.Time `json:"processed_at"`
+	Order       string      `json:"order"`
+	Sum         money.Money `json:"sum"`
+	ProcessedAt time.Time   `json:"processed_at"`
 }
 
 func NewWithdrawalsResponse(ws []withdrawal.Withdrawal) []ResponseWithdrawals {
@@ -63,3 +239,19 @@ func NewWithdrawalsResponse(ws []withdrawal.Withdrawal) []ResponseWithdrawals {
 	}
 	return responses
 }
+
+type ResponseLedgerEntry struct {
+	Kind      string      `json:"kind"`
+	Amount    money.Money `json:"amount"`
+	Order     string      `json:"order,omitempty"`
+	Comment   string      `json:"comment,omitempty"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+func NewLedgerResponse(entries []ledger.Entry) []ResponseLedgerEntry {
+	var responses []ResponseLedgerEntry
+	for _, e := range entries {
+		responses = append(responses, ResponseLedgerEntry{Kind: e.Kind, Amount: e.Amount, Order: e.OrderNumber, Comment: e.Comment, CreatedAt: e.CreatedAt})
+	}
+	return responses
+}
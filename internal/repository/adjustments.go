@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"lystem/internal/models/adjustment"
+)
+
+const adjustmentColumns = `id, user_id, amount, reason, comment, COALESCE(order_number, ''), status,
+	created_by, decided_by, decided_at, transaction_id, created_at`
+
+var (
+	insertAdjustmentSQL = `INSERT INTO balance_adjustments (user_id, amount, reason, comment, order_number, status, created_by)
+		VALUES (@user_id, @amount, @reason, @comment, @order_number, @status, @created_by) RETURNING id, created_at`
+	lockAdjustmentSQL   = `SELECT ` + adjustmentColumns + ` FROM balance_adjustments WHERE id = @id FOR UPDATE`
+	decideAdjustmentSQL = `UPDATE balance_adjustments SET status = @status, decided_by = @decided_by, decided_at = now(),
+		transaction_id = @transaction_id WHERE id = @id RETURNING decided_at`
+	selectAdjustmentsSQL = `SELECT ` + adjustmentColumns + ` FROM balance_adjustments
+		WHERE (@id = 0 OR id = @id) AND (@user_id = 0 OR user_id = @user_id) AND (@status = '' OR status = @status) ORDER BY created_at, id`
+)
+
+type AdjustmentsRepository struct {
+	conn *pgxpool.Conn
+}
+
+func NewAdjustmentsRepository(conn *pgxpool.Conn) *AdjustmentsRepository {
+	return &AdjustmentsRepository{conn}
+}
+
+func (r *AdjustmentsRepository) Create(ctx context.Context, tx pgx.Tx, a *adjustment.Adjustment) error {
+	args := pgx.NamedArgs{
+		"user_id":      a.UserID,
+		"amount":       a.Amount,
+		"reason":       a.Reason,
+		"comment":      a.Comment,
+		"order_number": nullable(a.OrderNumber),
+		"status":       a.Status,
+		"created_by":   a.CreatedBy,
+	}
+	return tx.QueryRow(ctx, insertAdjustmentSQL, args).Scan(&a.ID, &a.CreatedAt)
+}
+
+// Lock returns the adjustment locked till the end of tx, pgx.ErrNoRows if there is none
+func (r *AdjustmentsRepository) Lock(ctx context.Context, tx pgx.Tx, id int) (*adjustment.Adjustment, error) {
+	return scanAdjustment(tx.QueryRow(ctx, lockAdjustmentSQL, pgx.NamedArgs{"id": id}))
+}
+
+// Decide stores status, decider and ledger transaction of the adjustment
+func (r *AdjustmentsRepository) Decide(ctx context.Context, tx pgx.Tx, a *adjustment.Adjustment) error {
+	args := pgx.NamedArgs{"id": a.ID, "status": a.Status, "decided_by": a.DecidedBy, "transaction_id": a.TransactionID}
+	return tx.QueryRow(ctx, decideAdjustmentSQL, args).Scan(&a.DecidedAt)
+}
+
+func (r *AdjustmentsRepository) FindAll(ctx context.Context, f adjustment.Filter) ([]adjustment.Adjustment, error) {
+	rows, err := r.conn.Query(ctx, selectAdjustmentsSQL, pgx.NamedArgs{"id": f.ID, "user_id": f.UserID, "status": f.Status})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var adjustments []adjustment.Adjustment
+	for rows.Next() {
+		a, err := scanAdjustment(rows)
+		if err != nil {
+			return nil, err
+		}
+		adjustments = append(adjustments, *a)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return adjustments, nil
+}
+
+func scanAdjustment(row pgx.Row) (*adjustment.Adjustment, error) {
+	var a adjustment.Adjustment
+	err := row.Scan(&a.ID, &a.UserID, &a.Amount, &a.Reason, &a.Comment, &a.OrderNumber, &a.Status,
+		&a.CreatedBy, &a.DecidedBy, &a.DecidedAt, &a.TransactionID, &a.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
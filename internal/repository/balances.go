@@ -7,6 +7,8 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 
 	"lystem/internal/models/balance"
+	"lystem/internal/models/ledger"
+	"lystem/internal/models/money"
 	"lystem/internal/models/order"
 	"lystem/internal/models/user"
 	"lystem/internal/models/withdrawal"
@@ -14,9 +16,11 @@ import (
 
 var (
 	insertBalanceSQL     = `INSERT INTO balances (user_id, current) VALUES (@user_id, @current)`
-	selectBalanceSQL     = `SELECT current, user_id FROM balances WHERE user_id = @user_id`
+	selectBalanceSQL     = `SELECT current, user_id, (SELECT COALESCE(-SUM(amount), 0) FROM ledger_entries WHERE account = @account AND kind = 'WITHDRAWAL') FROM balances WHERE user_id = @user_id`
+	lockBalanceSQL       = `SELECT current, user_id FROM balances WHERE user_id = @user_id FOR UPDATE`
 	increaseBalanceSQL   = `UPDATE balances SET current = current + @accrual WHERE user_id = @user_id`
 	deductFromBalanceSQL = `UPDATE balances SET current = current - @sum WHERE user_id = @user_id`
+	adjustBalanceSQL     = `UPDATE balances SET current = current + @amount WHERE user_id = @user_id`
 )
 
 type BalancesRepository struct {
@@ -36,27 +40,57 @@ func (r *BalancesRepository) Create(ctx context.Context, tx pgx.Tx, currUser *us
 }
 
 func (r *BalancesRepository) FindByUser(ctx context.Context, currUser *user.User) (*balance.Balance, error) {
-	args := pgx.NamedArgs{"user_id": currUser.ID}
+	args := pgx.NamedArgs{"user_id": currUser.ID, "account": ledger.UserAccount(currUser.ID)}
 	result := r.conn.QueryRow(ctx, selectBalanceSQL, args)
 	var b balance.Balance
+	if err := result.Scan(&b.Current, &b.UserID, &b.Withdrawn); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// FindByUserForUpdate locks user's balance row until the end of transaction
+func (r *BalancesRepository) FindByUserForUpdate(ctx context.Context, tx pgx.Tx, currUser *user.User) (*balance.Balance, error) {
+	args := pgx.NamedArgs{"user_id": currUser.ID}
+	result := tx.QueryRow(ctx, lockBalanceSQL, args)
+	var b balance.Balance
 	if err := result.Scan(&b.Current, &b.UserID); err != nil {
 		return nil, err
 	}
 	return &b, nil
 }
 
+// Accrual credits order accrual to the owner, returns pgx.ErrNoRows if the owner has no balance
 func (r *BalancesRepository) Accrual(ctx context.Context, tx pgx.Tx, o *order.Order) error {
 	args := pgx.NamedArgs{"accrual": o.Accrual, "user_id": o.UserID}
-	if _, err := tx.Exec(ctx, increaseBalanceSQL, args); err != nil {
+	tag, err := tx.Exec(ctx, increaseBalanceSQL, args)
+	if err != nil {
 		return err
 	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
 	return nil
 }
 
-func (r *BalancesRepository) Decrease(ctx context.Context, w *withdrawal.Withdrawal, currUser *user.User) error {
+func (r *BalancesRepository) Decrease(ctx context.Context, tx pgx.Tx, w *withdrawal.Withdrawal, currUser *user.User) error {
 	args := pgx.NamedArgs{"sum": w.Sum, "user_id": currUser.ID}
-	if _, err := r.conn.Exec(ctx, deductFromBalanceSQL, args); err != nil {
+	if _, err := tx.Exec(ctx, deductFromBalanceSQL, args); err != nil {
 		return err
 	}
 	return nil
 }
+
+// Adjust adds signed amount to the balance, balances_current_non_negative rejects negative result.
+// It returns pgx.ErrNoRows if the user has no balance
+func (r *BalancesRepository) Adjust(ctx context.Context, tx pgx.Tx, userID int, amount money.Money) error {
+	args := pgx.NamedArgs{"amount": amount, "user_id": userID}
+	tag, err := tx.Exec(ctx, adjustBalanceSQL, args)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"lystem/internal/models/idempotency"
+)
+
+var (
+	// reserves the key, expired or abandoned record with the same key is replaced
+	reserveIdempotencyKeySQL = `INSERT INTO idempotency_keys (user_id, key, request_hash) VALUES (@user_id, @key, @request_hash)
+		ON CONFLICT (user_id, key) DO UPDATE
+		SET request_hash = EXCLUDED.request_hash, status_code = NULL, content_type = NULL, body = NULL, created_at = now()
+		WHERE idempotency_keys.created_at < @expired_before
+			OR (idempotency_keys.status_code IS NULL AND idempotency_keys.created_at < @abandoned_before)
+		RETURNING created_at`
+	selectIdempotencyKeySQL = `SELECT user_id, key, request_hash, COALESCE(status_code, 0), COALESCE(content_type, ''), COALESCE(body, ''::bytea), created_at
+		FROM idempotency_keys WHERE user_id = @user_id AND key = @key`
+	completeIdempotencyKeySQL = `UPDATE idempotency_keys SET (status_code, content_type, body) = (@status_code, @content_type, @body)
+		WHERE user_id = @user_id AND key = @key`
+	deleteIdempotencyKeySQL         = `DELETE FROM idempotency_keys WHERE user_id = @user_id AND key = @key`
+	deleteExpiredIdempotencyKeysSQL = `DELETE FROM idempotency_keys WHERE created_at < @created_before`
+)
+
+type IdempotencyRepository struct {
+	conn *pgxpool.Conn
+}
+
+func NewIdempotencyRepository(conn *pgxpool.Conn) *IdempotencyRepository {
+	return &IdempotencyRepository{conn}
+}
+
+// Reserve stores the record if the key is free, expired or abandoned. Returns false if the key is already taken.
+func (r *IdempotencyRepository) Reserve(ctx context.Context, rec *idempotency.Record, ttl idempotency.TTL, now time.Time) (bool, error) {
+	args := pgx.NamedArgs{
+		"user_id":          rec.UserID,
+		"key":              rec.Key,
+		"request_hash":     rec.RequestHash,
+		"expired_before":   ttl.ExpiredBefore(now),
+		"abandoned_before": ttl.AbandonedBefore(now),
+	}
+	err := r.conn.QueryRow(ctx, reserveIdempotencyKeySQL, args).Scan(&rec.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *IdempotencyRepository) Find(ctx context.Context, userID int, key string) (*idempotency.Record, error) {
+	result := r.conn.QueryRow(ctx, selectIdempotencyKeySQL, pgx.NamedArgs{"user_id": userID, "key": key})
+	var rec idempotency.Record
+	if err := result.Scan(&rec.UserID, &rec.Key, &rec.RequestHash, &rec.StatusCode, &rec.ContentType, &rec.Body, &rec.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+func (r *IdempotencyRepository) Complete(ctx context.Context, rec *idempotency.Record) error {
+	args := pgx.NamedArgs{
+		"user_id":      rec.UserID,
+		"key":          rec.Key,
+		"status_code":  rec.StatusCode,
+		"content_type": rec.ContentType,
+		"body":         rec.Body,
+	}
+	_, err := r.conn.Exec(ctx, completeIdempotencyKeySQL, args)
+	return err
+}
+
+func (r *IdempotencyRepository) Delete(ctx context.Context, userID int, key string) error {
+	_, err := r.conn.Exec(ctx, deleteIdempotencyKeySQL, pgx.NamedArgs{"user_id": userID, "key": key})
+	return err
+}
+
+func (r *IdempotencyRepository) DeleteExpired(ctx context.Context, createdBefore time.Time) (int64, error) {
+	tag, err := r.conn.Exec(ctx, deleteExpiredIdempotencyKeysSQL, pgx.NamedArgs{"created_before": createdBefore})
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
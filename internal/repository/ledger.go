@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"lystem/internal/models/ledger"
+)
+
+var (
+	insertLedgerEntrySQL = `INSERT INTO ledger_entries (transaction_id, account, amount, kind, order_number, comment)
+		VALUES (@transaction_id, @account, @amount, @kind, @order_number, @comment) RETURNING id, created_at`
+	selectLedgerEntriesSQL = `SELECT id, transaction_id, account, amount, kind, COALESCE(order_number, ''), comment, created_at
+		FROM ledger_entries WHERE account = @account ORDER BY created_at DESC, id DESC`
+	selectBalanceMismatchesSQL = `SELECT b.user_id, b.current, COALESCE(SUM(e.amount), 0)
+		FROM balances b LEFT JOIN ledger_entries e ON e.account = 'user:' || b.user_id
+		GROUP BY b.user_id, b.current
+		HAVING b.current <> COALESCE(SUM(e.amount), 0)`
+	selectUnbalancedTransactionsSQL = `SELECT transaction_id FROM ledger_entries GROUP BY transaction_id HAVING SUM(amount) <> 0`
+)
+
+type LedgerRepository struct {
+	conn *pgxpool.Conn
+}
+
+func NewLedgerRepository(conn *pgxpool.Conn) *LedgerRepository {
+	return &LedgerRepository{conn}
+}
+
+func (r *LedgerRepository) Post(ctx context.Context, tx pgx.Tx, entries []ledger.Entry) error {
+	for i := range entries {
+		args := pgx.NamedArgs{
+			"transaction_id": entries[i].TransactionID,
+			"account":        entries[i].Account,
+			"amount":         entries[i].Amount,
+			"kind":           entries[i].Kind,
+			"order_number":   nullable(entries[i].OrderNumber),
+			"comment":        entries[i].Comment,
+		}
+		if err := tx.QueryRow(ctx, insertLedgerEntrySQL, args).Scan(&entries[i].ID, &entries[i].CreatedAt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *LedgerRepository) FindByAccount(ctx context.Context, account string) ([]ledger.Entry, error) {
+	rows, err := r.conn.Query(ctx, selectLedgerEntriesSQL, pgx.NamedArgs{"account": account})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []ledger.Entry
+	for rows.Next() {
+		var e ledger.Entry
+		if err = rows.Scan(&e.ID, &e.TransactionID, &e.Account, &e.Amount, &e.Kind, &e.OrderNumber, &e.Comment, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (r *LedgerRepository) Check(ctx context.Context) (*ledger.Report, error) {
+	var report ledger.Report
+
+	rows, err := r.conn.Query(ctx, selectBalanceMismatchesSQL)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var m ledger.Mismatch
+		if err = rows.Scan(&m.UserID, &m.Current, &m.LedgerSum); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		report.Mismatches = append(report.Mismatches, m)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = r.conn.Query(ctx, selectUnbalancedTransactionsSQL)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id uuid.UUID
+		if err = rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		report.UnbalancedTransactions = append(report.UnbalancedTransactions, id)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &report, nil
+}
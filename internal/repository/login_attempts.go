@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"lystem/internal/models/lockout"
+)
+
+var (
+	insertLoginAttemptSQL = `INSERT INTO login_attempts (login, ip, result) VALUES (@login, @ip, @result) RETURNING id, attempted_at`
+	// empty counter is inserted first, so concurrent failures lock the same row instead of overwriting each other
+	ensureLoginFailuresSQL = `INSERT INTO login_failures (scope, key, failures, last_failure_at, locked_until)
+		VALUES (@scope, @key, 0, 'epoch', 'epoch') ON CONFLICT (scope, key) DO NOTHING`
+	lockLoginFailuresSQL = `SELECT scope, key, failures, last_failure_at, locked_until FROM login_failures
+		WHERE scope = @scope AND key = @key FOR UPDATE`
+	updateLoginFailuresSQL = `UPDATE login_failures SET (failures, last_failure_at, locked_until) = (@failures, @last_failure_at, @locked_until)
+		WHERE scope = @scope AND key = @key`
+	deleteLoginFailuresSQL = `DELETE FROM login_failures WHERE scope = @scope AND key = @key`
+	// addresses are unlocked together with the login, attempts from them are known by the audit records
+	deleteLoginIPFailuresSQL = `DELETE FROM login_failures
+		WHERE scope = 'ip' AND key IN (SELECT ip FROM login_attempts WHERE login = @login)`
+	deleteOldLoginAttemptsSQL = `DELETE FROM login_attempts WHERE attempted_at < @attempted_before`
+	deleteOldLoginFailuresSQL = `DELETE FROM login_failures WHERE last_failure_at < @failed_before AND locked_until < now()`
+)
+
+type LoginAttemptsRepository struct {
+	conn *pgxpool.Conn
+}
+
+func NewLoginAttemptsRepository(conn *pgxpool.Conn) *LoginAttemptsRepository {
+	return &LoginAttemptsRepository{conn: conn}
+}
+
+func (r *LoginAttemptsRepository) Add(ctx context.Context, tx pgx.Tx, a *lockout.Attempt) error {
+	args := pgx.NamedArgs{"login": a.Login, "ip": a.IP, "result": a.Result}
+	return tx.QueryRow(ctx, insertLoginAttemptSQL, args).Scan(&a.ID, &a.AttemptedAt)
+}
+
+// LockCounter returns the failures counter locked till the end of tx, creating it if needed
+func (r *LoginAttemptsRepository) LockCounter(ctx context.Context, tx pgx.Tx, scope, key string) (*lockout.Counter, error) {
+	args := pgx.NamedArgs{"scope": scope, "key": key}
+	if _, err := tx.Exec(ctx, ensureLoginFailuresSQL, args); err != nil {
+		return nil, err
+	}
+
+	var c lockout.Counter
+	result := tx.QueryRow(ctx, lockLoginFailuresSQL, args)
+	if err := result.Scan(&c.Scope, &c.Key, &c.Failures, &c.LastFailureAt, &c.LockedUntil); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (r *LoginAttemptsRepository) SaveCounter(ctx context.Context, tx pgx.Tx, c *lockout.Counter) error {
+	args := pgx.NamedArgs{
+		"scope":           c.Scope,
+		"key":             c.Key,
+		"failures":        c.Failures,
+		"last_failure_at": c.LastFailureAt,
+		"locked_until":    c.LockedUntil,
+	}
+	_, err := tx.Exec(ctx, updateLoginFailuresSQL, args)
+	return err
+}
+
+// DeleteCounter returns false if there was no such counter
+func (r *LoginAttemptsRepository) DeleteCounter(ctx context.Context, tx pgx.Tx, scope, key string) (bool, error) {
+	tag, err := tx.Exec(ctx, deleteLoginFailuresSQL, pgx.NamedArgs{"scope": scope, "key": key})
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// DeleteIPCounters removes counters of the addresses the login was tried from, returns their number
+func (r *LoginAttemptsRepository) DeleteIPCounters(ctx context.Context, tx pgx.Tx, login string) (int64, error) {
+	tag, err := tx.Exec(ctx, deleteLoginIPFailuresSQL, pgx.NamedArgs{"login": login})
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+func (r *LoginAttemptsRepository) DeleteExpired(ctx context.Context, attemptedBefore, failedBefore time.Time) (int64, error) {
+	attempts, err := r.conn.Exec(ctx, deleteOldLoginAttemptsSQL, pgx.NamedArgs{"attempted_before": attemptedBefore})
+	if err != nil {
+		return 0, err
+	}
+	counters, err := r.conn.Exec(ctx, deleteOldLoginFailuresSQL, pgx.NamedArgs{"failed_before": failedBefore})
+	if err != nil {
+		return 0, err
+	}
+	return attempts.RowsAffected() + counters.RowsAffected(), nil
+}
@@ -0,0 +1,19 @@
+package repository
+
+import "time"
+
+// nullable turns empty string into SQL NULL
+func nullable(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// nullableTime turns zero time into SQL NULL
+func nullableTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"lystem/internal/models/order"
+)
+
+var (
+	insertOrderStatusChangeSQL = `INSERT INTO order_status_history (order_id, from_status, to_status, reason)
+		VALUES (@order_id, @from_status, @to_status, @reason) RETURNING id, changed_at`
+	selectOrderStatusHistorySQL = `SELECT id, order_id, COALESCE(from_status, ''), to_status, reason, changed_at
+		FROM order_status_history WHERE order_id = @order_id ORDER BY changed_at, id`
+)
+
+type OrderHistoryRepository struct {
+	conn *pgxpool.Conn
+}
+
+func NewOrderHistoryRepository(conn *pgxpool.Conn) *OrderHistoryRepository {
+	return &OrderHistoryRepository{conn}
+}
+
+func (r *OrderHistoryRepository) Add(ctx context.Context, tx pgx.Tx, change *order.StatusChange) error {
+	args := pgx.NamedArgs{
+		"order_id":    change.OrderID,
+		"from_status": nullable(change.From),
+		"to_status":   change.To,
+		"reason":      change.Reason,
+	}
+	return tx.QueryRow(ctx, insertOrderStatusChangeSQL, args).Scan(&change.ID, &change.ChangedAt)
+}
+
+func (r *OrderHistoryRepository) FindByOrder(ctx context.Context, orderID int) ([]order.StatusChange, error) {
+	rows, err := r.conn.Query(ctx, selectOrderStatusHistorySQL, pgx.NamedArgs{"order_id": orderID})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []order.StatusChange
+	for rows.Next() {
+		var c order.StatusChange
+		if err = rows.Scan(&c.ID, &c.OrderID, &c.From, &c.To, &c.Reason, &c.ChangedAt); err != nil {
+			return nil, err
+		}
+		changes = append(changes, c)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return changes, nil
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -11,12 +12,44 @@ import (
 )
 
 var (
-	selectOrderByNumberSQL    = `SELECT id, number, user_id, status FROM orders WHERE number = @number`
-	insertOrderSQL            = `INSERT INTO orders (number, user_id, accrual, status) VALUES (@number, @user_id, @accrual, @status) RETURNING id`
-	updateOrderSQL            = `UPDATE orders SET (accrual, status) = (@accrual, @status) WHERE number = @number`
-	updateReturningOrderSQL   = `UPDATE orders SET (accrual, status) = (@accrual, @status) WHERE number = @number RETURNING id, number, user_id, status, accrual, uploaded_at`
-	selectOrdersByUserIDSQL   = `SELECT id, number, user_id, status, accrual, uploaded_at FROM orders WHERE user_id = @user_id`
-	selectOrdersByStatusesSQL = `SELECT id, number, user_id, status, accrual, uploaded_at FROM orders WHERE status IN ('NEW','REGISTERED','PROCESSING') LIMIT @limit`
+	selectOrderByNumberSQL = `SELECT id, number, user_id, status, COALESCE(accrual, 0), uploaded_at, checked_at FROM orders WHERE number = @number`
+	insertOrderSQL         = `INSERT INTO orders (number, user_id, accrual, status) VALUES (@number, @user_id, @accrual, @status) RETURNING id`
+	// numbers already uploaded by anyone are skipped and reported by selectOrderOwnersSQL
+	insertOrdersBatchSQL = `INSERT INTO orders (number, user_id, accrual, status)
+		SELECT number, @user_id, 0, 'NEW' FROM unnest(@numbers::varchar[]) AS number
+		ON CONFLICT (number) DO NOTHING
+		RETURNING id, number`
+	selectOrderOwnersSQL = `SELECT number, user_id FROM orders WHERE number = ANY(@numbers)`
+	// locks the order so concurrent poll and callback check status transition one by one
+	lockOrderByNumberSQL = `SELECT id, number, user_id, status FROM orders WHERE number = @number FOR UPDATE`
+	// successful answer from accrual system resets retry schedule
+	updateReturningOrderSQL = `UPDATE orders SET (accrual, status, attempts, last_error, next_attempt_at, checked_at) = (@accrual, @status, 0, NULL, NULL, now())
+		WHERE number = @number RETURNING id, number, user_id, status, accrual, uploaded_at`
+	scheduleOrderRetrySQL = `UPDATE orders SET (status, attempts, last_error, next_attempt_at, claimed_by, lease_until) = (@status, @attempts, @last_error, @next_attempt_at, NULL, NULL)
+		WHERE number = @number`
+	selectOrdersPageTemplate = `SELECT id, number, user_id, status, accrual, uploaded_at FROM orders
+		WHERE user_id = @user_id
+			AND (@statuses::varchar[] IS NULL OR status = ANY(@statuses))
+			AND (@from::timestamptz IS NULL OR uploaded_at >= @from)
+			AND (@to::timestamptz IS NULL OR uploaded_at < @to)
+			AND (@after_at::timestamptz IS NULL OR (uploaded_at, id) {after} (@after_at, @after_id))
+		ORDER BY uploaded_at {dir}, id {dir}
+		LIMIT @limit`
+	selectOrdersPageAscSQL  = keysetSQL(selectOrdersPageTemplate, false)
+	selectOrdersPageDescSQL = keysetSQL(selectOrdersPageTemplate, true)
+	// claims free or expired unprocessed orders, rows locked by concurrent claim are skipped
+	claimUnprocessedOrdersSQL = `UPDATE orders SET (claimed_by, lease_until) = (@owner, now() + make_interval(secs => @lease_seconds))
+		WHERE id IN (
+			SELECT id FROM orders
+			WHERE status IN ('NEW','REGISTERED','PROCESSING')
+				AND (lease_until IS NULL OR lease_until < now() OR claimed_by = @owner)
+				AND (next_attempt_at IS NULL OR next_attempt_at <= now())
+			ORDER BY id
+			LIMIT @limit
+			FOR UPDATE SKIP LOCKED)
+		RETURNING id, number, user_id, status, accrual, uploaded_at, claimed_by, lease_until, attempts, COALESCE(last_error, '')`
+	releaseOrderClaimsSQL = `UPDATE orders SET (claimed_by, lease_until) = (NULL, NULL) WHERE claimed_by = @owner`
+	markOrderCheckedSQL   = `UPDATE orders SET checked_at = now() WHERE number = @number`
 )
 
 type OrdersRepository struct {
@@ -30,27 +63,80 @@ func NewOrdersRepository(conn *pgxpool.Conn) *OrdersRepository {
 func (r *OrdersRepository) FindByNumber(ctx context.Context, number string) (*order.Order, error) {
 	result := r.conn.QueryRow(ctx, selectOrderByNumberSQL, pgx.NamedArgs{"number": number})
 	var scannedOrder order.Order
-	if err := result.Scan(&scannedOrder.ID, &scannedOrder.Number, &scannedOrder.UserID, &scannedOrder.Status); err != nil {
+	var checkedAt *time.Time
+	if err := result.Scan(&scannedOrder.ID, &scannedOrder.Number, &scannedOrder.UserID, &scannedOrder.Status, &scannedOrder.Accrual, &scannedOrder.UploadedAt, &checkedAt); err != nil {
 		return nil, err
 	}
+	if checkedAt != nil {
+		scannedOrder.CheckedAt = *checkedAt
+	}
 	return &scannedOrder, nil
 }
 
-func (r *OrdersRepository) Save(ctx context.Context, number string, userID int) (*order.Order, error) {
+func (r *OrdersRepository) Save(ctx context.Context, tx pgx.Tx, number string, userID int) (*order.Order, error) {
 	args := pgx.NamedArgs{"number": number, "user_id": userID, "accrual": 0, "status": order.StatusNew}
-	result := r.conn.QueryRow(ctx, insertOrderSQL, args)
+	result := tx.QueryRow(ctx, insertOrderSQL, args)
 	var id int
 	if err := result.Scan(&id); err != nil {
 		return nil, err
 	}
 
-	return &order.Order{ID: id, Number: number, UserID: userID}, nil
+	return &order.Order{ID: id, Number: number, UserID: userID, Status: order.StatusNew}, nil
 }
 
-func (r *OrdersRepository) Update(ctx context.Context, newOrder *order.Order) error {
-	args := pgx.NamedArgs{"number": newOrder.Number, "accrual": newOrder.Accrual, "status": newOrder.Status}
-	_, err := r.conn.Exec(ctx, updateOrderSQL, args)
-	return err
+// SaveBatch inserts new numbers and returns the orders created, conflicting numbers are left as is
+func (r *OrdersRepository) SaveBatch(ctx context.Context, tx pgx.Tx, numbers []string, userID int) ([]order.Order, error) {
+	rows, err := tx.Query(ctx, insertOrdersBatchSQL, pgx.NamedArgs{"numbers": numbers, "user_id": userID})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var orders []order.Order
+	for rows.Next() {
+		o := order.Order{UserID: userID, Status: order.StatusNew}
+		if err = rows.Scan(&o.ID, &o.Number); err != nil {
+			return nil, err
+		}
+		orders = append(orders, o)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+// FindOwners maps order numbers to ids of users who uploaded them
+func (r *OrdersRepository) FindOwners(ctx context.Context, tx pgx.Tx, numbers []string) (map[string]int, error) {
+	rows, err := tx.Query(ctx, selectOrderOwnersSQL, pgx.NamedArgs{"numbers": numbers})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	owners := make(map[string]int, len(numbers))
+	for rows.Next() {
+		var number string
+		var userID int
+		if err = rows.Scan(&number, &userID); err != nil {
+			return nil, err
+		}
+		owners[number] = userID
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return owners, nil
+}
+
+// LockByNumber selects the order and locks it until the end of transaction
+func (r *OrdersRepository) LockByNumber(ctx context.Context, tx pgx.Tx, number string) (*order.Order, error) {
+	result := tx.QueryRow(ctx, lockOrderByNumberSQL, pgx.NamedArgs{"number": number})
+	var scannedOrder order.Order
+	if err := result.Scan(&scannedOrder.ID, &scannedOrder.Number, &scannedOrder.UserID, &scannedOrder.Status); err != nil {
+		return nil, err
+	}
+	return &scannedOrder, nil
 }
 
 func (r *OrdersRepository) UpdateReturning(ctx context.Context, tx pgx.Tx, newOrder *order.Order) (*order.Order, error) {
@@ -64,8 +150,19 @@ func (r *OrdersRepository) UpdateReturning(ctx context.Context, tx pgx.Tx, newOr
 	return &scannedOrder, nil
 }
 
-func (r *OrdersRepository) FindAllUserOrders(ctx context.Context, u *user.User) ([]order.Order, error) {
-	rows, err := r.conn.Query(ctx, selectOrdersByUserIDSQL, pgx.NamedArgs{"user_id": u.ID})
+func (r *OrdersRepository) FindAllUserOrders(ctx context.Context, u *user.User, filter order.Filter) ([]order.Order, error) {
+	args := pageArgs(filter.Query)
+	args["user_id"] = u.ID
+	args["statuses"] = nil
+	if len(filter.Statuses) > 0 {
+		args["statuses"] = filter.Statuses
+	}
+	query := selectOrdersPageAscSQL
+	if filter.Desc {
+		query = selectOrdersPageDescSQL
+	}
+
+	rows, err := r.conn.Query(ctx, query, args)
 	if err != nil {
 		return nil, err
 	}
@@ -86,8 +183,9 @@ func (r *OrdersRepository) FindAllUserOrders(ctx context.Context, u *user.User)
 	return orders, nil
 }
 
-func (r *OrdersRepository) SelectUnprocessed(ctx context.Context, limit int) ([]order.Order, error) {
-	rows, err := r.conn.Query(ctx, selectOrdersByStatusesSQL, pgx.NamedArgs{"limit": limit})
+func (r *OrdersRepository) ClaimUnprocessed(ctx context.Context, tx pgx.Tx, owner string, limit int, lease time.Duration) ([]order.Order, error) {
+	args := pgx.NamedArgs{"owner": owner, "limit": limit, "lease_seconds": lease.Seconds()}
+	rows, err := tx.Query(ctx, claimUnprocessedOrdersSQL, args)
 	if err != nil {
 		return nil, err
 	}
@@ -96,7 +194,7 @@ func (r *OrdersRepository) SelectUnprocessed(ctx context.Context, limit int) ([]
 	var orders []order.Order
 	for rows.Next() {
 		var sOrder order.Order
-		if err = rows.Scan(&sOrder.ID, &sOrder.Number, &sOrder.UserID, &sOrder.Status, &sOrder.Accrual, &sOrder.UploadedAt); err != nil {
+		if err = rows.Scan(&sOrder.ID, &sOrder.Number, &sOrder.UserID, &sOrder.Status, &sOrder.Accrual, &sOrder.UploadedAt, &sOrder.ClaimedBy, &sOrder.LeaseUntil, &sOrder.Attempts, &sOrder.LastError); err != nil {
 			return nil, err
 		}
 		orders = append(orders, sOrder)
@@ -107,3 +205,25 @@ func (r *OrdersRepository) SelectUnprocessed(ctx context.Context, limit int) ([]
 
 	return orders, nil
 }
+
+func (r *OrdersRepository) ScheduleRetry(ctx context.Context, tx pgx.Tx, o *order.Order) error {
+	args := pgx.NamedArgs{
+		"number":          o.Number,
+		"status":          o.Status,
+		"attempts":        o.Attempts,
+		"last_error":      o.LastError,
+		"next_attempt_at": nullableTime(o.NextAttemptAt),
+	}
+	_, err := tx.Exec(ctx, scheduleOrderRetrySQL, args)
+	return err
+}
+
+func (r *OrdersRepository) MarkChecked(ctx context.Context, number string) error {
+	_, err := r.conn.Exec(ctx, markOrderCheckedSQL, pgx.NamedArgs{"number": number})
+	return err
+}
+
+func (r *OrdersRepository) ReleaseClaims(ctx context.Context, owner string) error {
+	_, err := r.conn.Exec(ctx, releaseOrderClaimsSQL, pgx.NamedArgs{"owner": owner})
+	return err
+}
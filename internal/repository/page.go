@@ -0,0 +1,38 @@
+package repository
+
+import (
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+
+	"lystem/internal/models/page"
+)
+
+// keysetSQL fills direction placeholders of a page query: {after} compares (time, id) with cursor, {dir} sorts
+func keysetSQL(template string, desc bool) string {
+	after, dir := ">", "ASC"
+	if desc {
+		after, dir = "<", "DESC"
+	}
+	return strings.NewReplacer("{after}", after, "{dir}", dir).Replace(template)
+}
+
+// pageArgs are arguments for @from, @to, @after_at, @after_id and @limit of a page query, zero limit takes all rows
+func pageArgs(q page.Query) pgx.NamedArgs {
+	args := pgx.NamedArgs{
+		"from":     nullableTime(q.From),
+		"to":       nullableTime(q.To),
+		"after_at": nil,
+		"after_id": 0,
+		"limit":    nil,
+	}
+	// LIMIT NULL returns all rows
+	if q.Limit > 0 {
+		args["limit"] = q.Limit
+	}
+	if q.After != nil {
+		args["after_at"] = q.After.At
+		args["after_id"] = q.After.ID
+	}
+	return args
+}
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"lystem/internal/models/user"
+)
+
+var (
+	insertPasswordResetSQL = `INSERT INTO password_resets (user_id, token_hash, expires_at) VALUES (@user_id, @token_hash, @expires_at)
+		RETURNING id, created_at`
+	// a new reset makes previous tokens useless, used ones are kept for audit
+	deleteUnusedResetsSQL = `DELETE FROM password_resets WHERE user_id = @user_id AND used_at IS NULL`
+	lockValidResetSQL     = `SELECT id, user_id, token_hash, expires_at, created_at FROM password_resets
+		WHERE token_hash = @token_hash AND used_at IS NULL AND expires_at > now() FOR UPDATE`
+	markResetUsedSQL   = `UPDATE password_resets SET used_at = now() WHERE id = @id`
+	deleteOldResetsSQL = `DELETE FROM password_resets
+		WHERE (used_at IS NULL AND expires_at < @expired_before) OR used_at < @used_before`
+)
+
+type PasswordResetsRepository struct {
+	conn *pgxpool.Conn
+}
+
+func NewPasswordResetsRepository(conn *pgxpool.Conn) *PasswordResetsRepository {
+	return &PasswordResetsRepository{conn: conn}
+}
+
+// Create stores the reset replacing unused resets of the user
+func (r *PasswordResetsRepository) Create(ctx context.Context, tx pgx.Tx, reset *user.PasswordReset) error {
+	if _, err := tx.Exec(ctx, deleteUnusedResetsSQL, pgx.NamedArgs{"user_id": reset.UserID}); err != nil {
+		return err
+	}
+
+	args := pgx.NamedArgs{"user_id": reset.UserID, "token_hash": reset.TokenHash, "expires_at": reset.ExpiresAt}
+	return tx.QueryRow(ctx, insertPasswordResetSQL, args).Scan(&reset.ID, &reset.CreatedAt)
+}
+
+// LockValid returns unused and not expired reset locked till the end of tx, pgx.ErrNoRows if there is none
+func (r *PasswordResetsRepository) LockValid(ctx context.Context, tx pgx.Tx, tokenHash string) (*user.PasswordReset, error) {
+	var reset user.PasswordReset
+	result := tx.QueryRow(ctx, lockValidResetSQL, pgx.NamedArgs{"token_hash": tokenHash})
+	if err := result.Scan(&reset.ID, &reset.UserID, &reset.TokenHash, &reset.ExpiresAt, &reset.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &reset, nil
+}
+
+func (r *PasswordResetsRepository) MarkUsed(ctx context.Context, tx pgx.Tx, id int) error {
+	_, err := tx.Exec(ctx, markResetUsedSQL, pgx.NamedArgs{"id": id})
+	return err
+}
+
+func (r *PasswordResetsRepository) DeleteExpired(ctx context.Context, expiredBefore, usedBefore time.Time) (int64, error) {
+	tag, err := r.conn.Exec(ctx, deleteOldResetsSQL, pgx.NamedArgs{"expired_before": expiredBefore, "used_before": usedBefore})
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -11,9 +12,22 @@ import (
 )
 
 var (
-	insertSQL   = `INSERT INTO sessions (user_id) VALUES (@user_id) RETURNING id, created_at`
-	deleteSQL   = `DELETE FROM sessions WHERE user_id = @user_id`
-	findByIDSQL = `SELECT id, user_id, created_at FROM sessions WHERE id = @id`
+	insertSQL = `INSERT INTO sessions (user_id, user_agent, ip) VALUES (@user_id, @user_agent, @ip)
+		RETURNING id, public_id, created_at, last_seen_at, refresh_token`
+	// deletes one session of the user by public id, sessions of other users are not touched even by known id
+	deleteSQL       = `DELETE FROM sessions WHERE public_id = @public_id AND user_id = @user_id`
+	findByIDSQL     = `SELECT id, public_id, user_id, created_at, last_seen_at FROM sessions WHERE id = @id`
+	findByUserIDSQL = `SELECT id, public_id, user_id, created_at, last_seen_at, user_agent, ip FROM sessions WHERE user_id = @user_id ORDER BY created_at DESC`
+	touchSQL        = `UPDATE sessions SET last_seen_at = now() WHERE id = @id`
+	// refresh keeps created_at, so refreshed sessions still end SessionTTL after login
+	refreshSQL = `UPDATE sessions SET (id, refresh_token, last_seen_at, user_agent, ip) = (gen_random_uuid(), gen_random_uuid(), now(), @user_agent, @ip)
+		WHERE refresh_token = @refresh_token AND created_at > @created_after
+		RETURNING id, public_id, user_id, created_at, last_seen_at, refresh_token`
+	deleteEndedSQL = `DELETE FROM sessions WHERE created_at <= @created_before`
+	// empty keep_id matches no session, so all of them are deleted
+	deleteOtherSessionsSQL = `DELETE FROM sessions WHERE user_id = @user_id AND public_id::text <> @keep_id`
+	// like deleteSQL, sessions of other users are not found even by known public id
+	findByPublicIDSQL = `SELECT id, public_id, user_id, created_at, last_seen_at FROM sessions WHERE public_id = @public_id AND user_id = @user_id`
 )
 
 type SessionsRepository struct {
@@ -24,15 +38,11 @@ func NewSessionsRepository(conn *pgxpool.Conn) *SessionsRepository {
 	return &SessionsRepository{conn: conn}
 }
 
-func (r *SessionsRepository) Create(ctx context.Context, tx pgx.Tx, u *user.User) (*session.Session, error) {
-	// delete all user's prev sessions
-	if _, err := r.conn.Exec(ctx, deleteSQL, pgx.NamedArgs{"user_id": u.ID}); err != nil {
-		return nil, err
-	}
-
-	result := tx.QueryRow(ctx, insertSQL, pgx.NamedArgs{"user_id": u.ID})
-	var newSession = session.Session{UserID: u.ID}
-	if err := result.Scan(&newSession.ID, &newSession.CreatedAt); err != nil {
+func (r *SessionsRepository) Create(ctx context.Context, u *user.User, client session.Client) (*session.Session, error) {
+	args := pgx.NamedArgs{"user_id": u.ID, "user_agent": client.UserAgent, "ip": client.IP}
+	result := r.conn.QueryRow(ctx, insertSQL, args)
+	var newSession = session.Session{UserID: u.ID, Client: client}
+	if err := result.Scan(&newSession.ID, &newSession.PublicID, &newSession.CreatedAt, &newSession.LastSeenAt, &newSession.RefreshToken); err != nil {
 		return nil, err
 	}
 
@@ -42,14 +52,86 @@ func (r *SessionsRepository) Create(ctx context.Context, tx pgx.Tx, u *user.User
 func (r *SessionsRepository) FindByID(ctx context.Context, tx pgx.Tx, id string) (*session.Session, error) {
 	result := tx.QueryRow(ctx, findByIDSQL, pgx.NamedArgs{"id": id})
 	var foundSession session.Session
-	if err := result.Scan(&foundSession.ID, &foundSession.UserID, &foundSession.CreatedAt); err != nil {
+	if err := result.Scan(&foundSession.ID, &foundSession.PublicID, &foundSession.UserID, &foundSession.CreatedAt, &foundSession.LastSeenAt); err != nil {
+		return nil, err
+	}
+
+	return &foundSession, nil
+}
+
+// FindByPublicID returns pgx.ErrNoRows if the user has no session with such public id
+func (r *SessionsRepository) FindByPublicID(ctx context.Context, u *user.User, publicID string) (*session.Session, error) {
+	result := r.conn.QueryRow(ctx, findByPublicIDSQL, pgx.NamedArgs{"public_id": publicID, "user_id": u.ID})
+	var foundSession session.Session
+	if err := result.Scan(&foundSession.ID, &foundSession.PublicID, &foundSession.UserID, &foundSession.CreatedAt, &foundSession.LastSeenAt); err != nil {
 		return nil, err
 	}
 
 	return &foundSession, nil
 }
 
-func (r *SessionsRepository) Delete(ctx context.Context, u *user.User) error {
-	_, err := r.conn.Exec(ctx, deleteSQL, pgx.NamedArgs{"user_id": u.ID})
+func (r *SessionsRepository) FindByUser(ctx context.Context, u *user.User) ([]session.Session, error) {
+	rows, err := r.conn.Query(ctx, findByUserIDSQL, pgx.NamedArgs{"user_id": u.ID})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []session.Session
+	for rows.Next() {
+		var s session.Session
+		if err = rows.Scan(&s.ID, &s.PublicID, &s.UserID, &s.CreatedAt, &s.LastSeenAt, &s.UserAgent, &s.IP); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// Delete returns false if the user has no session with such public id
+func (r *SessionsRepository) Delete(ctx context.Context, u *user.User, publicID string) (bool, error) {
+	tag, err := r.conn.Exec(ctx, deleteSQL, pgx.NamedArgs{"public_id": publicID, "user_id": u.ID})
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func (r *SessionsRepository) Touch(ctx context.Context, id string) error {
+	_, err := r.conn.Exec(ctx, touchSQL, pgx.NamedArgs{"id": id})
+	return err
+}
+
+// Refresh replaces session id and refresh token of the session created after createdAfter,
+// returns pgx.ErrNoRows if there is no such session
+func (r *SessionsRepository) Refresh(ctx context.Context, refreshToken string, client session.Client, createdAfter time.Time) (*session.Session, error) {
+	args := pgx.NamedArgs{
+		"refresh_token": refreshToken,
+		"user_agent":    client.UserAgent,
+		"ip":            client.IP,
+		"created_after": createdAfter,
+	}
+	result := r.conn.QueryRow(ctx, refreshSQL, args)
+	refreshed := session.Session{Client: client}
+	if err := result.Scan(&refreshed.ID, &refreshed.PublicID, &refreshed.UserID, &refreshed.CreatedAt, &refreshed.LastSeenAt, &refreshed.RefreshToken); err != nil {
+		return nil, err
+	}
+	return &refreshed, nil
+}
+
+func (r *SessionsRepository) DeleteEnded(ctx context.Context, createdBefore time.Time) (int64, error) {
+	tag, err := r.conn.Exec(ctx, deleteEndedSQL, pgx.NamedArgs{"created_before": createdBefore})
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// DeleteOthers revokes all sessions of the user except one with public id keepID, all of them if keepID is empty
+func (r *SessionsRepository) DeleteOthers(ctx context.Context, tx pgx.Tx, userID int, keepID string) error {
+	_, err := tx.Exec(ctx, deleteOtherSessionsSQL, pgx.NamedArgs{"user_id": userID, "keep_id": keepID})
 	return err
 }
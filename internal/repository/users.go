@@ -10,9 +10,14 @@ import (
 )
 
 var (
-	insertUserSQL      = `INSERT INTO users (login, hashed_password) VALUES (@login, @hashed_password) RETURNING id`
-	findUserByLoginSQL = `SELECT id, login, hashed_password FROM users WHERE login = @login`
-	findUserByIDSQL    = `SELECT id FROM users WHERE id = @id`
+	insertUserSQL      = `INSERT INTO users (login, hashed_password) VALUES (@login, @hashed_password) RETURNING id, role`
+	findUserByLoginSQL = `SELECT id, login, hashed_password, role, blocked_at FROM users WHERE login = @login`
+	findUserByIDSQL    = `SELECT id, login, hashed_password, role, blocked_at FROM users WHERE id = @id`
+	updatePasswordSQL  = `UPDATE users SET (hashed_password, updated_at) = (@hashed_password, now()) WHERE id = @id`
+	updateRoleSQL      = `UPDATE users SET (role, updated_at) = (@role, now()) WHERE id = @id`
+	// blocking keeps the original block time if the user is already blocked
+	blockUserSQL   = `UPDATE users SET (blocked_at, updated_at) = (COALESCE(blocked_at, now()), now()) WHERE id = @id RETURNING blocked_at`
+	unblockUserSQL = `UPDATE users SET (blocked_at, updated_at) = (NULL, now()) WHERE id = @id`
 )
 
 type UsersRepository struct {
@@ -28,30 +33,54 @@ func (r *UsersRepository) Create(ctx context.Context, tx pgx.Tx, u *user.User) (
 	result := tx.QueryRow(ctx, insertUserSQL, args)
 
 	var id int
-	if err := result.Scan(&id); err != nil {
+	var role string
+	if err := result.Scan(&id, &role); err != nil {
 		return nil, err
 	}
 	return &user.User{
 		ID:             id,
 		Login:          u.Login,
 		HashedPassword: u.HashedPassword,
+		Role:           role,
 	}, nil
 }
 
 func (r *UsersRepository) FindByLogin(ctx context.Context, login string) (*user.User, error) {
 	var u user.User
 	result := r.conn.QueryRow(ctx, findUserByLoginSQL, pgx.NamedArgs{"login": login})
-	if err := result.Scan(&u.ID, &u.Login, &u.HashedPassword); err != nil {
+	if err := result.Scan(&u.ID, &u.Login, &u.HashedPassword, &u.Role, &u.BlockedAt); err != nil {
 		return nil, err
 	}
 	return &u, nil
 }
 
+func (r *UsersRepository) UpdatePassword(ctx context.Context, tx pgx.Tx, u *user.User) error {
+	_, err := tx.Exec(ctx, updatePasswordSQL, pgx.NamedArgs{"id": u.ID, "hashed_password": u.HashedPassword})
+	return err
+}
+
 func (r *UsersRepository) FindByID(ctx context.Context, tx pgx.Tx, id int) (*user.User, error) {
 	var u user.User
 	result := tx.QueryRow(ctx, findUserByIDSQL, pgx.NamedArgs{"id": id})
-	if err := result.Scan(&u.ID); err != nil {
+	if err := result.Scan(&u.ID, &u.Login, &u.HashedPassword, &u.Role, &u.BlockedAt); err != nil {
 		return nil, err
 	}
 	return &u, nil
 }
+
+func (r *UsersRepository) UpdateRole(ctx context.Context, u *user.User) error {
+	_, err := r.conn.Exec(ctx, updateRoleSQL, pgx.NamedArgs{"id": u.ID, "role": u.Role})
+	return err
+}
+
+// SetBlocked blocks or unblocks the user, BlockedAt of u is updated
+func (r *UsersRepository) SetBlocked(ctx context.Context, tx pgx.Tx, u *user.User, blocked bool) error {
+	if !blocked {
+		if _, err := tx.Exec(ctx, unblockUserSQL, pgx.NamedArgs{"id": u.ID}); err != nil {
+			return err
+		}
+		u.BlockedAt = nil
+		return nil
+	}
+	return tx.QueryRow(ctx, blockUserSQL, pgx.NamedArgs{"id": u.ID}).Scan(&u.BlockedAt)
+}
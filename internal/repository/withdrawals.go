@@ -7,12 +7,24 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 
 	"lystem/internal/models/balance"
+	"lystem/internal/models/money"
+	"lystem/internal/models/page"
 	"lystem/internal/models/withdrawal"
 )
 
 var (
-	insertWithdrawalSQL  = `INSERT INTO withdrawals (sum, order_number, balance_id) VALUES (@sum, @order_number, @balance_id) RETURNING id, sum, balance_id`
-	selectWithdrawalsSQL = `SELECT id, sum, order_number, balance_id FROM withdrawals WHERE balance_id = @balance_id`
+	insertWithdrawalSQL           = `INSERT INTO withdrawals (sum, order_number, balance_id) VALUES (@sum, @order_number, @balance_id) RETURNING id, sum, balance_id`
+	selectWithdrawalsPageTemplate = `SELECT id, sum, order_number, balance_id, proceeded_at FROM withdrawals
+		WHERE balance_id = @balance_id
+			AND (@from::timestamptz IS NULL OR proceeded_at >= @from)
+			AND (@to::timestamptz IS NULL OR proceeded_at < @to)
+			AND (@after_at::timestamptz IS NULL OR (proceeded_at, id) {after} (@after_at, @after_id))
+		ORDER BY proceeded_at {dir}, id {dir}
+		LIMIT @limit`
+	selectWithdrawalsPageAscSQL  = keysetSQL(selectWithdrawalsPageTemplate, false)
+	selectWithdrawalsPageDescSQL = keysetSQL(selectWithdrawalsPageTemplate, true)
+	selectOrderWithdrawalsSQL    = `SELECT id, sum, order_number, balance_id, proceeded_at FROM withdrawals
+		WHERE order_number = @order_number AND balance_id = @balance_id ORDER BY proceeded_at`
 )
 
 type WithdrawalsRepository struct {
@@ -23,8 +35,15 @@ func NewWithdrawalsRepository(conn *pgxpool.Conn) *WithdrawalsRepository {
 	return &WithdrawalsRepository{conn}
 }
 
-func (r *WithdrawalsRepository) FindAll(ctx context.Context, b *balance.Balance) ([]withdrawal.Withdrawal, error) {
-	rows, err := r.conn.Query(ctx, selectWithdrawalsSQL, pgx.NamedArgs{"balance_id": b.UserID})
+func (r *WithdrawalsRepository) FindAll(ctx context.Context, b *balance.Balance, q page.Query) ([]withdrawal.Withdrawal, error) {
+	args := pageArgs(q)
+	args["balance_id"] = b.UserID
+	query := selectWithdrawalsPageAscSQL
+	if q.Desc {
+		query = selectWithdrawalsPageDescSQL
+	}
+
+	rows, err := r.conn.Query(ctx, query, args)
 	if err != nil {
 		return nil, err
 	}
@@ -33,7 +52,7 @@ func (r *WithdrawalsRepository) FindAll(ctx context.Context, b *balance.Balance)
 	var withdrawals []withdrawal.Withdrawal
 	for rows.Next() {
 		var w withdrawal.Withdrawal
-		if err = rows.Scan(&w.ID, &w.Sum, &w.OrderNumber, &w.BalanceID); err != nil {
+		if err = rows.Scan(&w.ID, &w.Sum, &w.OrderNumber, &w.BalanceID, &w.ProcessedAt); err != nil {
 			return nil, err
 		}
 
@@ -46,7 +65,28 @@ func (r *WithdrawalsRepository) FindAll(ctx context.Context, b *balance.Balance)
 	return withdrawals, nil
 }
 
-func (r *WithdrawalsRepository) Create(ctx context.Context, tx pgx.Tx, orderNumber string, userBalance *balance.Balance, sum float64) (*withdrawal.Withdrawal, error) {
+func (r *WithdrawalsRepository) FindByOrder(ctx context.Context, orderNumber string, userID int) ([]withdrawal.Withdrawal, error) {
+	rows, err := r.conn.Query(ctx, selectOrderWithdrawalsSQL, pgx.NamedArgs{"order_number": orderNumber, "balance_id": userID})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var withdrawals []withdrawal.Withdrawal
+	for rows.Next() {
+		var w withdrawal.Withdrawal
+		if err = rows.Scan(&w.ID, &w.Sum, &w.OrderNumber, &w.BalanceID, &w.ProcessedAt); err != nil {
+			return nil, err
+		}
+		withdrawals = append(withdrawals, w)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return withdrawals, nil
+}
+
+func (r *WithdrawalsRepository) Create(ctx context.Context, tx pgx.Tx, orderNumber string, userBalance *balance.Balance, sum money.Money) (*withdrawal.Withdrawal, error) {
 	args := pgx.NamedArgs{"order_number": orderNumber, "balance_id": userBalance.UserID, "sum": sum}
 	result := tx.QueryRow(ctx, insertWithdrawalSQL, args)
 	var wd = withdrawal.Withdrawal{OrderNumber: orderNumber}
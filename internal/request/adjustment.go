@@ -0,0 +1,87 @@
+package request
+
+import (
+	"encoding/json"
+	"errors"
+	"unicode/utf8"
+
+	"lystem/internal/models/adjustment"
+	"lystem/internal/models/money"
+)
+
+const (
+	AdjustmentCredit = "credit"
+	AdjustmentDebit  = "debit"
+
+	maxAdjustmentComment = 1000
+)
+
+type CreateAdjustment struct {
+	Type    string      `json:"type"`
+	Amount  money.Money `json:"amount"`
+	Reason  string      `json:"reason"`
+	Comment string      `json:"comment"`
+	Order   string      `json:"order"`
+	// amountErr keeps amount given with digits beyond hundredths for Validate
+	amountErr error
+}
+
+var (
+	errAdjustmentType     = errors.New("тип корректировки должен быть credit или debit")
+	errNonPositiveAmount  = errors.New("сумма корректировки должна быть больше нуля")
+	errAdjustmentComment  = errors.New("комментарий длиннее 1000 символов")
+	errAdjustmentNoReason = errors.New("не указана причина корректировки")
+)
+
+// UnmarshalJSON reads amount as is: amount more precise than hundredths is rejected, not rounded
+func (ca *CreateAdjustment) UnmarshalJSON(data []byte) error {
+	type plain CreateAdjustment
+	body := struct {
+		*plain
+		Amount json.RawMessage `json:"amount"`
+	}{plain: (*plain)(ca)}
+	if err := json.Unmarshal(data, &body); err != nil {
+		return err
+	}
+
+	ca.Amount, ca.amountErr = exactAmount(body.Amount)
+	if ca.amountErr != nil && !errors.Is(ca.amountErr, money.ErrTooPrecise) {
+		return ca.amountErr
+	}
+	return nil
+}
+
+func (ca CreateAdjustment) Validate() error {
+	if ca.amountErr != nil {
+		return ca.amountErr
+	}
+	if ca.Type != AdjustmentCredit && ca.Type != AdjustmentDebit {
+		return errAdjustmentType
+	}
+	if ca.Amount <= 0 {
+		return errNonPositiveAmount
+	}
+	if ca.Reason == "" {
+		return errAdjustmentNoReason
+	}
+	if !adjustment.ValidReason(ca.Reason) {
+		return adjustment.ErrUnknownReason
+	}
+	if utf8.RuneCountInString(ca.Comment) > maxAdjustmentComment {
+		return errAdjustmentComment
+	}
+	// the order is optional, e.g. the one whose accrual is reversed
+	if ca.Order != "" && !validLuhn(ca.Order) {
+		return errInvalidOrderNumber
+	}
+
+	return nil
+}
+
+// SignedAmount is negative for debit
+func (ca CreateAdjustment) SignedAmount() money.Money {
+	if ca.Type == AdjustmentDebit {
+		return -ca.Amount
+	}
+	return ca.Amount
+}
@@ -0,0 +1,125 @@
+package request
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"lystem/internal/models/order"
+	"lystem/internal/models/page"
+)
+
+// ListRequest is query string of user lists:
+// ?limit=50&cursor=...&sort=-uploaded_at&status=NEW,PROCESSING&from=2024-01-01T00:00:00Z&to=2024-02-01T00:00:00Z
+type ListRequest struct {
+	Limit  int    `query:"limit"`
+	Cursor string `query:"cursor"`
+	Sort   string `query:"sort"`
+	Status string `query:"status"`
+	From   string `query:"from"`
+	To     string `query:"to"`
+}
+
+var (
+	errInvalidLimit  = errors.New("limit должен быть от 1 до 1000")
+	errInvalidCursor = errors.New("неверный курсор")
+	errInvalidSort   = errors.New("неверное поле сортировки")
+	errInvalidStatus = errors.New("неизвестный статус заказа")
+	errInvalidPeriod = errors.New("from и to должны быть в формате RFC3339, from раньше to")
+)
+
+// orderStatuses are statuses of the public orders contract, users see STUCK orders as PROCESSING
+var orderStatuses = []string{order.StatusNew, order.StatusRegistered, order.StatusProcessing, order.StatusProcessed, order.StatusInvalid}
+
+// adminOrderStatuses are all statuses orders are stored in
+var adminOrderStatuses = append(slices.Clone(orderStatuses), order.StatusStuck)
+
+// Query validates common list parameters, sortField is the only field the list may be sorted by,
+// prefixed with "-" it sorts from the newest to the oldest
+func (l *ListRequest) Query(sortField string) (page.Query, error) {
+	var q page.Query
+
+	switch {
+	case l.Limit == 0 && l.Cursor != "":
+		q.Limit = page.DefaultLimit
+	case l.Limit != 0:
+		if l.Limit < 0 || l.Limit > page.MaxLimit {
+			return q, errInvalidLimit
+		}
+		q.Limit = l.Limit
+	}
+
+	switch l.Sort {
+	case "", sortField:
+	case "-" + sortField:
+		q.Desc = true
+	default:
+		return q, errInvalidSort
+	}
+
+	if l.Cursor != "" {
+		cursor, err := page.DecodeCursor(l.Cursor)
+		if err != nil {
+			return q, errInvalidCursor
+		}
+		q.After = cursor
+	}
+
+	var err error
+	if q.From, err = parseTime(l.From); err != nil {
+		return q, errInvalidPeriod
+	}
+	if q.To, err = parseTime(l.To); err != nil {
+		return q, errInvalidPeriod
+	}
+	if !q.From.IsZero() && !q.To.IsZero() && !q.From.Before(q.To) {
+		return q, errInvalidPeriod
+	}
+
+	return q, nil
+}
+
+// OrderFilter validates list parameters of user orders. PROCESSING selects STUCK orders as well,
+// as they are shown to users as PROCESSING
+func (l *ListRequest) OrderFilter() (order.Filter, error) {
+	filter, err := l.orderFilter(orderStatuses)
+	if err != nil {
+		return filter, err
+	}
+	if slices.Contains(filter.Statuses, order.StatusProcessing) {
+		filter.Statuses = append(filter.Statuses, order.StatusStuck)
+	}
+	return filter, nil
+}
+
+// AdminOrderFilter validates list parameters of user orders looked up by staff, STUCK is filtered on its own
+func (l *ListRequest) AdminOrderFilter() (order.Filter, error) {
+	return l.orderFilter(adminOrderStatuses)
+}
+
+func (l *ListRequest) orderFilter(statuses []string) (order.Filter, error) {
+	q, err := l.Query("uploaded_at")
+	if err != nil {
+		return order.Filter{}, err
+	}
+
+	filter := order.Filter{Query: q}
+	if l.Status != "" {
+		for _, status := range strings.Split(l.Status, ",") {
+			status = strings.ToUpper(strings.TrimSpace(status))
+			if !slices.Contains(statuses, status) {
+				return order.Filter{}, errInvalidStatus
+			}
+			filter.Statuses = append(filter.Statuses, status)
+		}
+	}
+	return filter, nil
+}
+
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
@@ -0,0 +1,69 @@
+package request
+
+import (
+	"slices"
+	"testing"
+
+	"lystem/internal/models/order"
+	"lystem/internal/models/page"
+)
+
+func TestListLimit(t *testing.T) {
+	cursor := page.Cursor{ID: 1}.Encode()
+	tests := []struct {
+		name    string
+		req     ListRequest
+		limit   int
+		wantErr error
+	}{
+		{name: "neither limit nor cursor takes whole list", req: ListRequest{}, limit: 0},
+		{name: "cursor without limit", req: ListRequest{Cursor: cursor}, limit: page.DefaultLimit},
+		{name: "limit", req: ListRequest{Limit: 10}, limit: 10},
+		{name: "limit with cursor", req: ListRequest{Limit: 10, Cursor: cursor}, limit: 10},
+		{name: "max limit", req: ListRequest{Limit: page.MaxLimit}, limit: page.MaxLimit},
+		{name: "negative limit", req: ListRequest{Limit: -1}, wantErr: errInvalidLimit},
+		{name: "limit over max", req: ListRequest{Limit: page.MaxLimit + 1}, wantErr: errInvalidLimit},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := tt.req.Query("uploaded_at")
+			if err != tt.wantErr {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && q.Limit != tt.limit {
+				t.Errorf("got limit %d, want %d", q.Limit, tt.limit)
+			}
+		})
+	}
+}
+
+func TestOrderFilterStatuses(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   string
+		user     []string
+		userErr  error
+		admin    []string
+		adminErr error
+	}{
+		{name: "no status", status: ""},
+		{name: "processing covers stuck for users", status: "processing", user: []string{order.StatusProcessing, order.StatusStuck}, admin: []string{order.StatusProcessing}},
+		{name: "several", status: "NEW, invalid", user: []string{order.StatusNew, order.StatusInvalid}, admin: []string{order.StatusNew, order.StatusInvalid}},
+		{name: "stuck is for staff only", status: "STUCK", userErr: errInvalidStatus, admin: []string{order.StatusStuck}},
+		{name: "unknown", status: "DONE", userErr: errInvalidStatus, adminErr: errInvalidStatus},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := ListRequest{Status: tt.status}
+
+			filter, err := req.OrderFilter()
+			if err != tt.userErr || !slices.Equal(filter.Statuses, tt.user) {
+				t.Errorf("OrderFilter() = %v, %v, want %v, %v", filter.Statuses, err, tt.user, tt.userErr)
+			}
+			filter, err = req.AdminOrderFilter()
+			if err != tt.adminErr || !slices.Equal(filter.Statuses, tt.admin) {
+				t.Errorf("AdminOrderFilter() = %v, %v, want %v, %v", filter.Statuses, err, tt.admin, tt.adminErr)
+			}
+		})
+	}
+}
@@ -1,22 +1,92 @@
 package request
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"lystem/internal/models/money"
+	"lystem/internal/models/order"
 )
 
 type SaveOrderRequest struct {
 	Number string
 }
 
+// SaveOrdersBatchRequest is a JSON array of order numbers or numbers separated by new lines
+type SaveOrdersBatchRequest struct {
+	Numbers []string
+}
+
+// MaxBatchSize limits the number of orders uploaded in one request
+const MaxBatchSize = 1000
+
 var (
 	errInvalidOrderNumber = errors.New("неверный формат номера заказа")
+	errEmptyBatch         = errors.New("не передано ни одного номера заказа")
+	errBatchTooLarge      = fmt.Errorf("можно загрузить не больше %d номеров заказов за раз", MaxBatchSize)
 )
 
+var accrualStatuses = []string{order.StatusRegistered, order.StatusInvalid, order.StatusProcessing, order.StatusProcessed}
+
 func (s *SaveOrderRequest) Parse(body []byte) error {
 	s.Number = string(body)
 	return nil
 }
 
+// Parse reads JSON array if body looks like one, new line separated list otherwise.
+// Repeated numbers are kept once.
+func (b *SaveOrdersBatchRequest) Parse(body []byte) error {
+	var numbers []string
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &numbers); err != nil {
+			return err
+		}
+	} else {
+		numbers = strings.Split(string(body), "\n")
+	}
+
+	b.Numbers = b.Numbers[:0]
+	seen := make(map[string]struct{}, len(numbers))
+	for _, number := range numbers {
+		number = strings.TrimSpace(number)
+		if number == "" {
+			continue
+		}
+		if _, ok := seen[number]; ok {
+			continue
+		}
+		seen[number] = struct{}{}
+		b.Numbers = append(b.Numbers, number)
+	}
+	return nil
+}
+
+func (b *SaveOrdersBatchRequest) Validate() error {
+	if len(b.Numbers) == 0 {
+		return errEmptyBatch
+	}
+	if len(b.Numbers) > MaxBatchSize {
+		return errBatchTooLarge
+	}
+	return nil
+}
+
+// ValidNumbers splits numbers passing Luhn check from invalid ones
+func (b *SaveOrdersBatchRequest) ValidNumbers() (valid []string, invalid []string) {
+	for _, number := range b.Numbers {
+		if validLuhn(number) {
+			valid = append(valid, number)
+		} else {
+			invalid = append(invalid, number)
+		}
+	}
+	return valid, invalid
+}
+
 func (s *SaveOrderRequest) Validate() error {
 	if validLuhn(s.Number) {
 		return nil
@@ -25,8 +95,28 @@ func (s *SaveOrderRequest) Validate() error {
 	return errInvalidOrderNumber
 }
 
+// GetOrderRequest is accrual system answer about the order
 type GetOrderRequest struct {
-	Order   string  `json:"order"`
-	Status  string  `json:"status"`
-	Accrual float64 `json:"accrual"`
+	Order   string      `json:"order"`
+	Status  string      `json:"status"`
+	Accrual money.Money `json:"accrual"`
+}
+
+var (
+	errUnknownAccrualStatus = errors.New("unknown accrual status")
+	errNegativeAccrual      = errors.New("negative accrual")
+	errOrderNumberMismatch  = errors.New("accrual answered about another order")
+)
+
+func (g *GetOrderRequest) Validate(number string) error {
+	if g.Order != "" && g.Order != number {
+		return errOrderNumberMismatch
+	}
+	if !slices.Contains(accrualStatuses, g.Status) {
+		return errUnknownAccrualStatus
+	}
+	if g.Accrual < 0 {
+		return errNegativeAccrual
+	}
+	return nil
 }
@@ -0,0 +1,82 @@
+package request
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestParseBatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		numbers []string
+		wantErr bool
+	}{
+		{name: "JSON array", body: `["79927398713", "4561261212345467"]`, numbers: []string{"79927398713", "4561261212345467"}},
+		{name: "JSON array after spaces", body: "\n  [\"79927398713\"]\n", numbers: []string{"79927398713"}},
+		{name: "new line separated", body: "79927398713\n4561261212345467\n", numbers: []string{"79927398713", "4561261212345467"}},
+		{name: "CRLF and blank lines", body: "79927398713\r\n\r\n  4561261212345467  \r\n", numbers: []string{"79927398713", "4561261212345467"}},
+		{name: "repeated numbers are kept once in order", body: "4561261212345467\n79927398713\n4561261212345467", numbers: []string{"4561261212345467", "79927398713"}},
+		{name: "repeated numbers in JSON", body: `["79927398713", " 79927398713 ", ""]`, numbers: []string{"79927398713"}},
+		{name: "single number", body: "79927398713", numbers: []string{"79927398713"}},
+		{name: "empty body", body: "", numbers: []string{}},
+		{name: "broken JSON", body: `["79927398713"`, wantErr: true},
+		{name: "JSON array of numbers", body: `[79927398713]`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b SaveOrdersBatchRequest
+			err := b.Parse([]byte(tt.body))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %t", err, tt.wantErr)
+			}
+			if err == nil && !slices.Equal(b.Numbers, tt.numbers) {
+				t.Errorf("got numbers %q, want %q", b.Numbers, tt.numbers)
+			}
+		})
+	}
+}
+
+func TestValidateBatch(t *testing.T) {
+	numbers := func(n int) string {
+		lines := make([]string, n)
+		for i := range lines {
+			lines[i] = strconv.Itoa(i + 1)
+		}
+		return strings.Join(lines, "\n")
+	}
+	tests := []struct {
+		name    string
+		body    string
+		wantErr error
+	}{
+		{name: "empty", body: "\n\n", wantErr: errEmptyBatch},
+		{name: "max size", body: numbers(MaxBatchSize)},
+		{name: "over max size", body: numbers(MaxBatchSize + 1), wantErr: errBatchTooLarge},
+		{name: "repeats do not count against max size", body: numbers(MaxBatchSize) + "\n" + numbers(MaxBatchSize)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b SaveOrdersBatchRequest
+			if err := b.Parse([]byte(tt.body)); err != nil {
+				t.Fatal(err)
+			}
+			if err := b.Validate(); err != tt.wantErr {
+				t.Errorf("got error %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBatchValidNumbers(t *testing.T) {
+	b := SaveOrdersBatchRequest{Numbers: []string{"79927398713", "79927398710", "4561261212345467", "12ab", "-18"}}
+	valid, invalid := b.ValidNumbers()
+	if want := []string{"79927398713", "4561261212345467"}; !slices.Equal(valid, want) {
+		t.Errorf("got valid %q, want %q", valid, want)
+	}
+	if want := []string{"79927398710", "12ab", "-18"}; !slices.Equal(invalid, want) {
+		t.Errorf("got invalid %q, want %q", invalid, want)
+	}
+}
@@ -0,0 +1,49 @@
+package request
+
+import "errors"
+
+type ChangePassword struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+type RequestPasswordReset struct {
+	Login string `json:"login"`
+}
+
+type ConfirmPasswordReset struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+var errSamePassword = errors.New("новый пароль совпадает с текущим")
+
+func (c ChangePassword) Validate() error {
+	if c.CurrentPassword == "" {
+		return errInvalidCreds
+	}
+	if err := validatePassword(c.NewPassword); err != nil {
+		return err
+	}
+	if c.NewPassword == c.CurrentPassword {
+		return errSamePassword
+	}
+
+	return nil
+}
+
+func (r RequestPasswordReset) Validate() error {
+	if r.Login == "" {
+		return errNoLogin
+	}
+
+	return nil
+}
+
+func (c ConfirmPasswordReset) Validate() error {
+	if c.Token == "" {
+		return errInvalidCreds
+	}
+
+	return validatePassword(c.NewPassword)
+}
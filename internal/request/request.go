@@ -1,6 +1,12 @@
 package request
 
-import "strconv"
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"lystem/internal/models/money"
+)
 
 // // https://ru.wikibooks.org/wiki/%D0%A0%D0%B5%D0%B0%D0%BB%D0%B8%D0%B7%D0%B0%D1%86%D0%B8%D0%B8_%D0%B0%D0%BB%D0%B3%D0%BE%D1%80%D0%B8%D1%82%D0%BC%D0%BE%D0%B2/%D0%90%D0%BB%D0%B3%D0%BE%D1%80%D0%B8%D1%82%D0%BC_%D0%9B%D1%83%D0%BD%D0%B0
 func validLuhn(number string) bool {
@@ -34,3 +40,13 @@ func luhnSum(number int) int {
 	}
 	return luhn % 10
 }
+
+// exactAmount parses amount given by user, a JSON number or a number in quotes, without rounding.
+// Missing amount is zero.
+func exactAmount(raw json.RawMessage) (money.Money, error) {
+	s := string(raw)
+	if s == "" || s == "null" {
+		return 0, nil
+	}
+	return money.ParseExact(strings.Trim(s, `"`))
+}
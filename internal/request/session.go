@@ -7,6 +7,10 @@ type CreateSession struct {
 	Password string `json:"password"`
 }
 
+type RefreshSession struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
 var errInvalidCreds = errors.New("неверный формат запроса")
 
 func (s *CreateSession) Validate() error {
@@ -16,3 +20,11 @@ func (s *CreateSession) Validate() error {
 
 	return nil
 }
+
+func (r *RefreshSession) Validate() error {
+	if r.RefreshToken == "" {
+		return errInvalidCreds
+	}
+
+	return nil
+}
@@ -19,7 +19,11 @@ func (cu CreateUser) Validate() error {
 		return errNoLogin
 	}
 
-	if cu.Password == "" || len(cu.Password) < 8 {
+	return validatePassword(cu.Password)
+}
+
+func validatePassword(password string) error {
+	if password == "" || len(password) < 8 {
 		return errWrongPassword
 	}
 
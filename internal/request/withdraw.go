@@ -1,15 +1,49 @@
 package request
 
+import (
+	"encoding/json"
+	"errors"
+
+	"lystem/internal/models/money"
+)
+
 type WithdrawRequest struct {
-	Order string  `json:"order"`
-	Sum   float64 `json:"sum"`
+	Order string      `json:"order"`
+	Sum   money.Money `json:"sum"`
+	// sumErr keeps sum given with digits beyond hundredths for Validate
+	sumErr error
 }
 
-func (wr *WithdrawRequest) Validate() error {
-	if validLuhn(wr.Order) {
-		return nil
+var errNonPositiveSum = errors.New("сумма списания должна быть больше нуля")
+
+// UnmarshalJSON reads sum as is: debit more precise than hundredths is rejected, not rounded
+func (wr *WithdrawRequest) UnmarshalJSON(data []byte) error {
+	type plain WithdrawRequest
+	body := struct {
+		*plain
+		Sum json.RawMessage `json:"sum"`
+	}{plain: (*plain)(wr)}
+	if err := json.Unmarshal(data, &body); err != nil {
+		return err
 	}
 
-	return errInvalidOrderNumber
+	wr.Sum, wr.sumErr = exactAmount(body.Sum)
+	if wr.sumErr != nil && !errors.Is(wr.sumErr, money.ErrTooPrecise) {
+		return wr.sumErr
+	}
+	return nil
+}
+
+func (wr *WithdrawRequest) Validate() error {
+	if wr.sumErr != nil {
+		return wr.sumErr
+	}
+	if !validLuhn(wr.Order) {
+		return errInvalidOrderNumber
+	}
+	if wr.Sum <= 0 {
+		return errNonPositiveSum
+	}
 
+	return nil
 }
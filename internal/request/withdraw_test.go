@@ -0,0 +1,48 @@
+package request
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"lystem/internal/models/money"
+)
+
+func TestWithdrawRequestSum(t *testing.T) {
+	tests := []struct {
+		name          string
+		body          string
+		sum           money.Money
+		wantDecodeErr bool
+		wantErr       error
+	}{
+		{name: "number", body: `{"order": "2377225624", "sum": 751}`, sum: 75100},
+		{name: "hundredths", body: `{"order": "2377225624", "sum": 0.01}`, sum: 1},
+		{name: "number in quotes", body: `{"order": "2377225624", "sum": "10.50"}`, sum: 1050},
+		{name: "trailing zeros", body: `{"order": "2377225624", "sum": 10.500}`, sum: 1050},
+		{name: "beyond hundredths", body: `{"order": "2377225624", "sum": 0.005}`, wantErr: money.ErrTooPrecise},
+		{name: "beyond hundredths in quotes", body: `{"order": "2377225624", "sum": "1.999"}`, wantErr: money.ErrTooPrecise},
+		{name: "missing sum", body: `{"order": "2377225624"}`, wantErr: errNonPositiveSum},
+		{name: "malformed sum", body: `{"order": "2377225624", "sum": "abc"}`, wantDecodeErr: true},
+		{name: "malformed body", body: `{"order": 2377225624`, wantDecodeErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var wr WithdrawRequest
+			err := json.Unmarshal([]byte(tt.body), &wr)
+			if (err != nil) != tt.wantDecodeErr {
+				t.Fatalf("decode error = %v, want error %v", err, tt.wantDecodeErr)
+			}
+			if tt.wantDecodeErr {
+				return
+			}
+
+			if err = wr.Validate(); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Validate() error = %v, want %v", err, tt.wantErr)
+			}
+			if wr.Order != "2377225624" || (tt.wantErr == nil && wr.Sum != tt.sum) {
+				t.Errorf("got order %q, sum %d, want sum %d", wr.Order, wr.Sum, tt.sum)
+			}
+		})
+	}
+}
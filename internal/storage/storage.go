@@ -2,31 +2,134 @@ package storage
 
 import (
 	"context"
+	"errors"
+	"time"
 
+	"lystem/internal/models/adjustment"
 	"lystem/internal/models/balance"
+	"lystem/internal/models/idempotency"
+	"lystem/internal/models/ledger"
+	"lystem/internal/models/lockout"
+	"lystem/internal/models/money"
 	"lystem/internal/models/order"
+	"lystem/internal/models/page"
 	"lystem/internal/models/session"
 	"lystem/internal/models/user"
 	"lystem/internal/models/withdrawal"
 )
 
+var (
+	ErrUserAlreadyExists  = errors.New("логин уже занят")
+	ErrNotEnoughBalance   = errors.New("на балансе недостаточно средств")
+	ErrAlreadyWithdrawn   = errors.New("по этому номеру заказа уже было списание")
+	ErrUserNotFound       = errors.New("пользователь не найден")
+	ErrSessionNotFound    = errors.New("сессия не найдена")
+	ErrResetTokenInvalid  = errors.New("код для сброса пароля недействителен или устарел")
+	ErrAdjustmentNotFound = errors.New("корректировка не найдена")
+	ErrAdjustmentDecided  = errors.New("корректировка уже рассмотрена")
+	ErrOrderNotFound      = errors.New("заказ не найден")
+	ErrOrderAlreadyExists = errors.New("номер заказа уже загружен")
+	ErrBalanceNotFound    = errors.New("баланс пользователя не найден")
+)
+
 type Storage interface {
 	CreateUser(ctx context.Context, u *user.User) (*user.User, error)
+	// FindUserByLogin returns ErrUserNotFound for unknown login
 	FindUserByLogin(ctx context.Context, login string) (*user.User, error)
-	FindUserByToken(ctx context.Context, token string) (*user.User, error)
-	CreateSession(ctx context.Context, u *user.User) (*session.Session, error)
-	DeleteSession(ctx context.Context, u *user.User) error
+	// ReserveLoginAttempt counts the attempt by policy as failed against the login and the address in advance,
+	// under lock of the counters, so parallel attempts can not pass the check before their failures are counted.
+	// If the login or the address is locked, nothing is counted and the time attempts are allowed again is returned.
+	ReserveLoginAttempt(ctx context.Context, a *lockout.Attempt, policy lockout.Policy) (time.Time, error)
+	// RecordLoginAttempt stores the attempt for audit. Success forgets failures against the login
+	// and takes back the failure reserved against the address, attempt to blocked account takes back both.
+	RecordLoginAttempt(ctx context.Context, a *lockout.Attempt, policy lockout.Policy) error
+	// UnlockLogin forgets failed attempts against the login and from the addresses it was tried from,
+	// returns false if there were none
+	UnlockLogin(ctx context.Context, login string) (bool, error)
+	// DeleteExpiredLoginAttempts removes audit records made before attemptedBefore and counters
+	// not locked anymore with the last failure before failedBefore, returns their number
+	DeleteExpiredLoginAttempts(ctx context.Context, attemptedBefore, failedBefore time.Time) (int64, error)
+	UpdateUserPassword(ctx context.Context, u *user.User) error
+	// FindUserByID returns ErrUserNotFound for unknown id
+	FindUserByID(ctx context.Context, id int) (*user.User, error)
+	// SetUserRole stores the role of u
+	SetUserRole(ctx context.Context, u *user.User) error
+	// SetUserBlocked blocks or unblocks u, blocking revokes all sessions of the user
+	SetUserBlocked(ctx context.Context, u *user.User, blocked bool) error
+	// ChangeUserPassword stores new password hash and revokes all sessions of the user
+	// except one with public id keepSessionID
+	ChangeUserPassword(ctx context.Context, u *user.User, keepSessionID string) error
+	// CreatePasswordReset stores the reset, unused resets of the user stop working
+	CreatePasswordReset(ctx context.Context, reset *user.PasswordReset) error
+	// ConsumePasswordReset uses the reset once to set the password and revoke all sessions of its user,
+	// returns ErrResetTokenInvalid for unknown, used or expired token
+	ConsumePasswordReset(ctx context.Context, tokenHash string, hashedPassword string) (*user.User, error)
+	// DeleteExpiredPasswordResets removes unused resets expired before expiredBefore and resets used before usedBefore,
+	// returns their number
+	DeleteExpiredPasswordResets(ctx context.Context, expiredBefore, usedBefore time.Time) (int64, error)
+	// FindUserByToken returns the session with its user, expiration is checked by the caller
+	FindUserByToken(ctx context.Context, token string) (*user.User, *session.Session, error)
+	CreateSession(ctx context.Context, u *user.User, client session.Client) (*session.Session, error)
+	FindSessions(ctx context.Context, u *user.User) ([]session.Session, error)
+	// FindSession returns one session of the user by its public id,
+	// returns ErrSessionNotFound if the user has no such session
+	FindSession(ctx context.Context, u *user.User, publicID string) (*session.Session, error)
+	// DeleteSession revokes one session of the user by its public id,
+	// returns ErrSessionNotFound if the user has no such session
+	DeleteSession(ctx context.Context, u *user.User, publicID string) error
+	TouchSession(ctx context.Context, id string) error
+	// RefreshSession issues new id and refresh token for the session created after createdAfter,
+	// returns ErrSessionNotFound if there is no such session
+	RefreshSession(ctx context.Context, refreshToken string, client session.Client, createdAfter time.Time) (*session.Session, error)
+	// DeleteEndedSessions removes sessions created before createdBefore and returns their number
+	DeleteEndedSessions(ctx context.Context, createdBefore time.Time) (int64, error)
 
 	FindBalance(ctx context.Context, u *user.User) (*balance.Balance, error)
-	DeductFromBalance(ctx context.Context, w *withdrawal.Withdrawal, u *user.User) error
 
-	CreateWithdrawal(ctx context.Context, orderNumber string, u *user.User, sum float64) (*withdrawal.Withdrawal, error)
-	FindWithdrawals(ctx context.Context, balance *balance.Balance) ([]withdrawal.Withdrawal, error)
+	CreateWithdrawal(ctx context.Context, orderNumber string, u *user.User, sum money.Money) (*withdrawal.Withdrawal, error)
+	FindWithdrawals(ctx context.Context, balance *balance.Balance, q page.Query) ([]withdrawal.Withdrawal, error)
+	// FindOrderWithdrawals returns withdrawals of the user made against the order number
+	FindOrderWithdrawals(ctx context.Context, orderNumber string, u *user.User) ([]withdrawal.Withdrawal, error)
+
+	// ReserveIdempotencyKey stores record for the key if it is free, expired or abandoned,
+	// otherwise returns the record stored by the first request
+	ReserveIdempotencyKey(ctx context.Context, rec *idempotency.Record, ttl idempotency.TTL) (*idempotency.Record, error)
+	CompleteIdempotencyKey(ctx context.Context, rec *idempotency.Record) error
+	ReleaseIdempotencyKey(ctx context.Context, userID int, key string) error
+	// DeleteExpiredIdempotencyKeys removes keys reserved before createdBefore and returns their number
+	DeleteExpiredIdempotencyKeys(ctx context.Context, createdBefore time.Time) (int64, error)
+
+	FindLedgerEntries(ctx context.Context, u *user.User) ([]ledger.Entry, error)
+	CheckLedger(ctx context.Context) (*ledger.Report, error)
+
+	// CreateAdjustment stores the adjustment, APPLIED one changes the balance in the same transaction
+	// and returns ErrNotEnoughBalance if the debit exceeds it
+	CreateAdjustment(ctx context.Context, a *adjustment.Adjustment) error
+	// FindAdjustment returns ErrAdjustmentNotFound for unknown id
+	FindAdjustment(ctx context.Context, id int) (*adjustment.Adjustment, error)
+	FindAdjustments(ctx context.Context, f adjustment.Filter) ([]adjustment.Adjustment, error)
+	// DecideAdjustment applies or rejects PENDING adjustment on behalf of decidedBy, returns ErrAdjustmentDecided
+	// if it is decided already and ErrNotEnoughBalance if the debit exceeds the balance, then it stays PENDING
+	DecideAdjustment(ctx context.Context, id int, status string, decidedBy int) (*adjustment.Adjustment, error)
 
 	FindOrderByNumber(ctx context.Context, number string) (*order.Order, error)
 	SaveOrder(ctx context.Context, number string, userID int) (*order.Order, error)
+	// SaveOrders uploads valid numbers at once, reporting result for each of them
+	SaveOrders(ctx context.Context, numbers []string, userID int) ([]order.Upload, error)
+	// UpdateOrder applies legal status transition only, returns order.ErrIllegalTransition otherwise.
+	// Accrual is credited to the user balance on transition to PROCESSED.
 	UpdateOrder(ctx context.Context, o *order.Order) error
-	UpdateOrderAndIncreaseBalance(ctx context.Context, o *order.Order) error
-	FindAllUserOrders(ctx context.Context, u *user.User) ([]order.Order, error)
-	SelectUnprocessedOrders(ctx context.Context, limit int) ([]order.Order, error)
+	FindOrderStatusHistory(ctx context.Context, o *order.Order) ([]order.StatusChange, error)
+	FindAllUserOrders(ctx context.Context, u *user.User, filter order.Filter) ([]order.Order, error)
+	// ClaimUnprocessedOrders leases up to limit unprocessed orders to owner,
+	// orders leased by other owners are skipped until their lease expires
+	ClaimUnprocessedOrders(ctx context.Context, owner string, limit int, lease time.Duration) ([]order.Order, error)
+	ReleaseOrderClaims(ctx context.Context, owner string) error
+	// MarkOrderChecked stores the time accrual system answered about the order without changing it
+	MarkOrderChecked(ctx context.Context, number string) error
+	// ScheduleOrderRetry stores failed attempts count, last error and next attempt time, releasing the claim.
+	// It does not touch check time, failed request is not an answer
+	ScheduleOrderRetry(ctx context.Context, o *order.Order) error
+
+	Close()
 }
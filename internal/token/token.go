@@ -0,0 +1,198 @@
+// Package token issues and verifies signed access tokens in JWT format (HS256),
+// so requests can be authorized without looking the session up in the storage.
+package token
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	algorithm = "HS256"
+	// minSecretLen is the HS256 key size recommended by RFC 7518
+	minSecretLen = 32
+)
+
+var (
+	ErrMalformed    = errors.New("malformed access token")
+	ErrUnknownKey   = errors.New("access token signed with unknown key")
+	ErrBadSignature = errors.New("invalid access token signature")
+	ErrExpired      = errors.New("access token expired")
+
+	errNoKeys = errors.New("no access token keys")
+)
+
+// Key is a signing secret named by kid header of the tokens signed with it
+type Key struct {
+	ID     string
+	Secret []byte
+}
+
+// Claims is what access token says about the request.
+// SessionID is the public id of the session, never its token, as the payload is readable by anyone.
+type Claims struct {
+	UserID    int
+	SessionID string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+type header struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ"`
+	KeyID     string `json:"kid"`
+}
+
+type payload struct {
+	Subject   string `json:"sub"`
+	SessionID string `json:"sid"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// Signer signs new tokens with the first key and accepts tokens signed with any of the keys,
+// so a key can be rotated by putting the new one first and removing the old one after TTL passes.
+type Signer struct {
+	keys map[string][]byte
+	// current signs new tokens
+	current Key
+	ttl     time.Duration
+}
+
+// ParseKeys reads comma separated "kid:secret" pairs
+func ParseKeys(value string) ([]Key, error) {
+	var keys []Key
+	seen := make(map[string]struct{})
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		id, secret, ok := strings.Cut(pair, ":")
+		if !ok || id == "" {
+			return nil, fmt.Errorf("access token key %q: expected kid:secret", pair)
+		}
+		if len(secret) < minSecretLen {
+			return nil, fmt.Errorf("access token key %q: secret must be at least %d bytes", id, minSecretLen)
+		}
+		if _, ok = seen[id]; ok {
+			return nil, fmt.Errorf("access token key %q: duplicate kid", id)
+		}
+		seen[id] = struct{}{}
+		keys = append(keys, Key{ID: id, Secret: []byte(secret)})
+	}
+	return keys, nil
+}
+
+func NewSigner(keys []Key, ttl time.Duration) (*Signer, error) {
+	if len(keys) == 0 {
+		return nil, errNoKeys
+	}
+
+	s := &Signer{keys: make(map[string][]byte, len(keys)), current: keys[0], ttl: ttl}
+	for _, k := range keys {
+		s.keys[k.ID] = k.Secret
+	}
+	return s, nil
+}
+
+// Issue signs a token for the session valid for the signer TTL, but not after notAfter if it is set
+func (s *Signer) Issue(userID int, sessionID string, now, notAfter time.Time) (string, Claims, error) {
+	claims := Claims{UserID: userID, SessionID: sessionID, IssuedAt: now, ExpiresAt: now.Add(s.ttl)}
+	if !notAfter.IsZero() && notAfter.Before(claims.ExpiresAt) {
+		claims.ExpiresAt = notAfter
+	}
+
+	h, err := json.Marshal(header{Algorithm: algorithm, Type: "JWT", KeyID: s.current.ID})
+	if err != nil {
+		return "", Claims{}, err
+	}
+	p, err := json.Marshal(payload{
+		Subject:   strconv.Itoa(userID),
+		SessionID: sessionID,
+		IssuedAt:  claims.IssuedAt.Unix(),
+		ExpiresAt: claims.ExpiresAt.Unix(),
+	})
+	if err != nil {
+		return "", Claims{}, err
+	}
+
+	signed := encode(h) + "." + encode(p)
+	return signed + "." + encode(sign(s.current.Secret, signed)), claims, nil
+}
+
+// Verify checks signature and expiration of the token
+func (s *Signer) Verify(token string, now time.Time) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformed
+	}
+
+	var h header
+	if err := decodeJSON(parts[0], &h); err != nil {
+		return nil, ErrMalformed
+	}
+	// alg is checked explicitly, otherwise "none" or another algorithm could be forced by the client
+	if h.Algorithm != algorithm {
+		return nil, ErrMalformed
+	}
+	secret, ok := s.keys[h.KeyID]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(signature, sign(secret, parts[0]+"."+parts[1])) {
+		return nil, ErrBadSignature
+	}
+
+	var p payload
+	if err = decodeJSON(parts[1], &p); err != nil {
+		return nil, ErrMalformed
+	}
+	userID, err := strconv.Atoi(p.Subject)
+	if err != nil || p.SessionID == "" {
+		return nil, ErrMalformed
+	}
+
+	claims := Claims{
+		UserID:    userID,
+		SessionID: p.SessionID,
+		IssuedAt:  time.Unix(p.IssuedAt, 0),
+		ExpiresAt: time.Unix(p.ExpiresAt, 0),
+	}
+	if !now.Before(claims.ExpiresAt) {
+		return nil, ErrExpired
+	}
+	return &claims, nil
+}
+
+// LooksSigned tells signed tokens from opaque session ids without verifying them
+func LooksSigned(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+func sign(secret []byte, signed string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signed))
+	return mac.Sum(nil)
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeJSON(part string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
@@ -0,0 +1,202 @@
+package token
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	oldKey = Key{ID: "2024-01", Secret: []byte(strings.Repeat("o", minSecretLen))}
+	newKey = Key{ID: "2024-06", Secret: []byte(strings.Repeat("n", minSecretLen))}
+)
+
+func newTestSigner(t *testing.T, keys ...Key) *Signer {
+	t.Helper()
+
+	s, err := NewSigner(keys, 15*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// forge builds a token with arbitrary header and payload signed by key
+func forge(t *testing.T, h header, p payload, key Key) string {
+	t.Helper()
+
+	hb, err := json.Marshal(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pb, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := encode(hb) + "." + encode(pb)
+	return signed + "." + encode(sign(key.Secret, signed))
+}
+
+// unsigned drops the signature, keeping the trailing dot as "alg": "none" tokens do
+func unsigned(token string) string {
+	return token[:strings.LastIndex(token, ".")+1]
+}
+
+func TestIssueVerify(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	s := newTestSigner(t, newKey)
+
+	token, issued, err := s.Issue(42, "c0ffee00-0000-0000-0000-000000000000", now, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !LooksSigned(token) {
+		t.Fatalf("token %q does not look signed", token)
+	}
+
+	claims, err := s.Verify(token, now.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.UserID != 42 || claims.SessionID != issued.SessionID || !claims.ExpiresAt.Equal(now.Add(15*time.Minute)) {
+		t.Fatalf("got claims %+v, issued %+v", *claims, issued)
+	}
+}
+
+func TestIssueNotAfter(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	s := newTestSigner(t, newKey)
+
+	_, claims, err := s.Issue(1, "sid", now, now.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !claims.ExpiresAt.Equal(now.Add(time.Minute)) {
+		t.Fatalf("token outlives its session: expires at %v", claims.ExpiresAt)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	valid := payload{Subject: "1", SessionID: "sid", IssuedAt: now.Unix(), ExpiresAt: now.Add(time.Minute).Unix()}
+	hs256 := header{Algorithm: algorithm, Type: "JWT", KeyID: newKey.ID}
+	s := newTestSigner(t, newKey, oldKey)
+
+	tests := []struct {
+		name  string
+		token string
+		at    time.Time
+		want  error
+	}{
+		{name: "valid", token: forge(t, hs256, valid, newKey), at: now},
+		{name: "signed with previous key", token: forge(t, header{Algorithm: algorithm, KeyID: oldKey.ID}, valid, oldKey), at: now},
+		{name: "expired", token: forge(t, hs256, valid, newKey), at: now.Add(time.Minute), want: ErrExpired},
+		{name: "alg none", token: forge(t, header{Algorithm: "none", KeyID: newKey.ID}, valid, newKey), at: now, want: ErrMalformed},
+		{name: "alg none unsigned", token: unsigned(forge(t, header{Algorithm: "none", KeyID: newKey.ID}, valid, newKey)), at: now, want: ErrMalformed},
+		{name: "alg RS256", token: forge(t, header{Algorithm: "RS256", KeyID: newKey.ID}, valid, newKey), at: now, want: ErrMalformed},
+		{name: "alg HS512", token: forge(t, header{Algorithm: "HS512", KeyID: newKey.ID}, valid, newKey), at: now, want: ErrMalformed},
+		{name: "unknown kid", token: forge(t, header{Algorithm: algorithm, KeyID: "2023-01"}, valid, newKey), at: now, want: ErrUnknownKey},
+		{name: "kid of another key", token: forge(t, hs256, valid, oldKey), at: now, want: ErrBadSignature},
+		{name: "no session", token: forge(t, hs256, payload{Subject: "1", ExpiresAt: valid.ExpiresAt}, newKey), at: now, want: ErrMalformed},
+		{name: "subject not a user id", token: forge(t, hs256, payload{Subject: "admin", SessionID: "sid", ExpiresAt: valid.ExpiresAt}, newKey), at: now, want: ErrMalformed},
+		{name: "two parts", token: "eyJhbGciOiJIUzI1NiJ9.eyJzdWIiOiIxIn0", at: now, want: ErrMalformed},
+		{name: "header not base64", token: "!!!.e30.e30", at: now, want: ErrMalformed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.Verify(tt.token, tt.at)
+			if tt.want == nil && err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyTampered(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	s := newTestSigner(t, newKey)
+	token, _, err := s.Issue(1, "sid", now, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parts := strings.Split(token, ".")
+	p, err := json.Marshal(payload{Subject: "2", SessionID: "sid", IssuedAt: now.Unix(), ExpiresAt: now.Add(time.Hour).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.Verify(parts[0]+"."+encode(p)+"."+parts[2], now); !errors.Is(err, ErrBadSignature) {
+		t.Fatalf("tampered payload: got %v, want ErrBadSignature", err)
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	before := newTestSigner(t, oldKey)
+	during := newTestSigner(t, newKey, oldKey)
+	after := newTestSigner(t, newKey)
+
+	oldToken, _, err := before.Issue(1, "sid", now, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	newToken, _, err := during.Issue(1, "sid", now, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = during.Verify(oldToken, now); err != nil {
+		t.Errorf("token of the previous key is rejected during rotation: %v", err)
+	}
+	if _, err = before.Verify(newToken, now); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("new key is not the signing one: got %v, want ErrUnknownKey", err)
+	}
+	if _, err = after.Verify(oldToken, now); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("removed key still verifies: got %v, want ErrUnknownKey", err)
+	}
+	if _, err = after.Verify(newToken, now); err != nil {
+		t.Errorf("token of the new key is rejected after rotation: %v", err)
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	secret := strings.Repeat("s", minSecretLen)
+	tests := []struct {
+		value   string
+		wantIDs []string
+		wantErr bool
+	}{
+		{value: "a:" + secret, wantIDs: []string{"a"}},
+		{value: " b:" + secret + " , a:" + secret + ",", wantIDs: []string{"b", "a"}},
+		{value: "", wantIDs: nil},
+		{value: "a:short", wantErr: true},
+		{value: secret, wantErr: true},
+		{value: ":" + secret, wantErr: true},
+		{value: "a:" + secret + ",a:" + secret, wantErr: true},
+	}
+	for _, tt := range tests {
+		keys, err := ParseKeys(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseKeys(%q) error = %v, want error %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		var ids []string
+		for _, k := range keys {
+			ids = append(ids, k.ID)
+		}
+		if strings.Join(ids, ",") != strings.Join(tt.wantIDs, ",") {
+			t.Errorf("ParseKeys(%q) = %v, want %v", tt.value, ids, tt.wantIDs)
+		}
+	}
+}
+
+func TestNewSignerWithoutKeys(t *testing.T) {
+	if _, err := NewSigner(nil, time.Minute); !errors.Is(err, errNoKeys) {
+		t.Fatalf("got %v, want errNoKeys", err)
+	}
+}
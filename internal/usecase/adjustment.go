@@ -0,0 +1,90 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+
+	"lystem/internal/models/adjustment"
+	"lystem/internal/models/user"
+	"lystem/internal/request"
+	"lystem/internal/storage"
+)
+
+var (
+	ErrSelfApproval        = errors.New("нельзя подтвердить собственную корректировку")
+	ErrSelfAdjustment      = errors.New("нельзя корректировать собственный баланс")
+	ErrAdjustmentOverdraft = errors.New("сумма списания больше текущего баланса")
+	ErrUnknownStatus       = errors.New("неизвестный статус корректировки")
+)
+
+// AdjustmentUsecase changes balances by hand. Adjustments above policy threshold wait for approval
+// of another operator, so nobody can move large amounts alone.
+type AdjustmentUsecase struct {
+	db     storage.Storage
+	policy adjustment.Policy
+}
+
+func NewAdjustmentUsecase(db storage.Storage, policy adjustment.Policy) *AdjustmentUsecase {
+	return &AdjustmentUsecase{db, policy}
+}
+
+// Create applies the adjustment right away or leaves it PENDING if it needs approval.
+// Operators can not adjust own balance, whatever the amount.
+func (uc *AdjustmentUsecase) Create(ctx context.Context, operator *user.User, target *user.User, req request.CreateAdjustment) (*adjustment.Adjustment, error) {
+	if operator.ID == target.ID {
+		return nil, ErrSelfAdjustment
+	}
+
+	a := &adjustment.Adjustment{
+		UserID:      target.ID,
+		Amount:      req.SignedAmount(),
+		Reason:      req.Reason,
+		Comment:     req.Comment,
+		OrderNumber: req.Order,
+		Status:      adjustment.StatusApplied,
+		CreatedBy:   operator.ID,
+	}
+	if uc.policy.NeedsApproval(a.Amount) {
+		a.Status = adjustment.StatusPending
+	}
+
+	if err := uc.db.CreateAdjustment(ctx, a); err != nil {
+		if errors.Is(err, storage.ErrNotEnoughBalance) {
+			return nil, ErrAdjustmentOverdraft
+		}
+		return nil, err
+	}
+	return a, nil
+}
+
+// Approve applies PENDING adjustment made by another operator to balance of somebody else
+func (uc *AdjustmentUsecase) Approve(ctx context.Context, operator *user.User, id int) (*adjustment.Adjustment, error) {
+	a, err := uc.db.FindAdjustment(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if a.CreatedBy == operator.ID {
+		return nil, ErrSelfApproval
+	}
+	if a.UserID == operator.ID {
+		return nil, ErrSelfAdjustment
+	}
+
+	a, err = uc.db.DecideAdjustment(ctx, id, adjustment.StatusApplied, operator.ID)
+	if err != nil && errors.Is(err, storage.ErrNotEnoughBalance) {
+		return nil, ErrAdjustmentOverdraft
+	}
+	return a, err
+}
+
+// Reject closes PENDING adjustment without changing the balance, the author may reject own adjustment
+func (uc *AdjustmentUsecase) Reject(ctx context.Context, operator *user.User, id int) (*adjustment.Adjustment, error) {
+	return uc.db.DecideAdjustment(ctx, id, adjustment.StatusRejected, operator.ID)
+}
+
+func (uc *AdjustmentUsecase) FindAll(ctx context.Context, f adjustment.Filter) ([]adjustment.Adjustment, error) {
+	if f.Status != "" && f.Status != adjustment.StatusPending && f.Status != adjustment.StatusApplied && f.Status != adjustment.StatusRejected {
+		return nil, ErrUnknownStatus
+	}
+	return uc.db.FindAdjustments(ctx, f)
+}
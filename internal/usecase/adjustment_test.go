@@ -0,0 +1,64 @@
+package usecase_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"lystem/internal/models/adjustment"
+	"lystem/internal/models/money"
+	"lystem/internal/request"
+	"lystem/internal/usecase"
+)
+
+func TestOperatorCanNotAdjustOwnBalance(t *testing.T) {
+	for name, db := range storages(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			operator := userWithBalance(t, ctx, db, 0)
+			credit := request.CreateAdjustment{Type: request.AdjustmentCredit, Amount: 50_00, Reason: adjustment.ReasonGoodwill}
+
+			for _, threshold := range []money.Money{0, 100_00} {
+				adjustments := usecase.NewAdjustmentUsecase(db, adjustment.Policy{ApprovalThreshold: threshold})
+				if _, err := adjustments.Create(ctx, operator, operator, credit); !errors.Is(err, usecase.ErrSelfAdjustment) {
+					t.Errorf("threshold %s: Create() error = %v, want %v", threshold, err, usecase.ErrSelfAdjustment)
+				}
+			}
+		})
+	}
+}
+
+func TestTargetCanNotApproveOwnAdjustment(t *testing.T) {
+	for name, db := range storages(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			author := userWithBalance(t, ctx, db, 0)
+			target := userWithBalance(t, ctx, db, 0)
+			approver := userWithBalance(t, ctx, db, 0)
+			adjustments := usecase.NewAdjustmentUsecase(db, adjustment.Policy{ApprovalThreshold: 10_00})
+
+			credit := request.CreateAdjustment{Type: request.AdjustmentCredit, Amount: 50_00, Reason: adjustment.ReasonGoodwill}
+			pending, err := adjustments.Create(ctx, author, target, credit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if pending.Status != adjustment.StatusPending {
+				t.Fatalf("status = %s, want %s", pending.Status, adjustment.StatusPending)
+			}
+
+			if _, err = adjustments.Approve(ctx, author, pending.ID); !errors.Is(err, usecase.ErrSelfApproval) {
+				t.Errorf("Approve() by author error = %v, want %v", err, usecase.ErrSelfApproval)
+			}
+			if _, err = adjustments.Approve(ctx, target, pending.ID); !errors.Is(err, usecase.ErrSelfAdjustment) {
+				t.Errorf("Approve() by target error = %v, want %v", err, usecase.ErrSelfAdjustment)
+			}
+			approved, err := adjustments.Approve(ctx, approver, pending.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if approved.Status != adjustment.StatusApplied {
+				t.Errorf("status = %s, want %s", approved.Status, adjustment.StatusApplied)
+			}
+		})
+	}
+}
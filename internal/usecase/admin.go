@@ -0,0 +1,61 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+
+	"lystem/internal/models/user"
+	"lystem/internal/storage"
+)
+
+var (
+	ErrSelfBlock   = errors.New("нельзя заблокировать самого себя")
+	ErrUnknownRole = errors.New("неизвестная роль")
+)
+
+// AdminUsecase manages other users' accounts
+type AdminUsecase struct {
+	db storage.Storage
+}
+
+func NewAdminUsecase(db storage.Storage) *AdminUsecase {
+	return &AdminUsecase{db}
+}
+
+// FindUser returns storage.ErrUserNotFound for unknown login
+func (uc *AdminUsecase) FindUser(ctx context.Context, login string) (*user.User, error) {
+	return uc.db.FindUserByLogin(ctx, login)
+}
+
+// SetBlocked blocks or unblocks the account by login. Blocked user is logged out everywhere and can not log in.
+func (uc *AdminUsecase) SetBlocked(ctx context.Context, actor *user.User, login string, blocked bool) (*user.User, error) {
+	foundUser, err := uc.db.FindUserByLogin(ctx, login)
+	if err != nil {
+		return nil, err
+	}
+	// the last admin could lock everyone out of admin API
+	if blocked && foundUser.ID == actor.ID {
+		return nil, ErrSelfBlock
+	}
+
+	if err = uc.db.SetUserBlocked(ctx, foundUser, blocked); err != nil {
+		return nil, err
+	}
+	return foundUser, nil
+}
+
+func (uc *AdminUsecase) SetRole(ctx context.Context, login string, role string) (*user.User, error) {
+	if !user.ValidRole(role) {
+		return nil, ErrUnknownRole
+	}
+
+	foundUser, err := uc.db.FindUserByLogin(ctx, login)
+	if err != nil {
+		return nil, err
+	}
+	foundUser.Role = role
+	if err = uc.db.SetUserRole(ctx, foundUser); err != nil {
+		return nil, err
+	}
+	return foundUser, nil
+}
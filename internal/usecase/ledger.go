@@ -0,0 +1,26 @@
+package usecase
+
+import (
+	"context"
+
+	"lystem/internal/models/ledger"
+	"lystem/internal/models/user"
+	"lystem/internal/storage"
+)
+
+type LedgerUsecase struct {
+	db storage.Storage
+}
+
+func NewLedgerUsecase(db storage.Storage) *LedgerUsecase {
+	return &LedgerUsecase{db}
+}
+
+func (uc *LedgerUsecase) History(ctx context.Context, currUser *user.User) ([]ledger.Entry, error) {
+	return uc.db.FindLedgerEntries(ctx, currUser)
+}
+
+// Check verifies stored balances against ledger sums and that every ledger transaction is balanced
+func (uc *LedgerUsecase) Check(ctx context.Context) (*ledger.Report, error) {
+	return uc.db.CheckLedger(ctx)
+}
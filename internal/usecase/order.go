@@ -2,8 +2,10 @@ package usecase
 
 import (
 	"context"
+	"time"
 
 	"lystem/internal/models/order"
+	"lystem/internal/models/page"
 	"lystem/internal/models/user"
 	"lystem/internal/request"
 	"lystem/internal/storage"
@@ -25,20 +27,99 @@ func (uc *OrderUsecase) Save(ctx context.Context, req request.SaveOrderRequest,
 	return uc.db.SaveOrder(ctx, req.Number, currUser.ID)
 }
 
+// SaveBatch uploads valid numbers of the batch, invalid ones are reported without touching storage.
+// Results follow the order of numbers in the request.
+func (uc *OrderUsecase) SaveBatch(ctx context.Context, req request.SaveOrdersBatchRequest, currUser *user.User) ([]order.Upload, error) {
+	valid, invalid := req.ValidNumbers()
+
+	results := make(map[string]string, len(req.Numbers))
+	for _, number := range invalid {
+		results[number] = order.UploadInvalidNumber
+	}
+	if len(valid) > 0 {
+		uploads, err := uc.db.SaveOrders(ctx, valid, currUser.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, u := range uploads {
+			results[u.Number] = u.Result
+		}
+	}
+
+	uploads := make([]order.Upload, 0, len(req.Numbers))
+	for _, number := range req.Numbers {
+		uploads = append(uploads, order.Upload{Number: number, Result: results[number]})
+	}
+	return uploads, nil
+}
+
 func (uc *OrderUsecase) Update(ctx context.Context, newOrder *order.Order) error {
-	if newOrder.Status == order.StatusProcessed {
-		err := uc.db.UpdateOrderAndIncreaseBalance(ctx, newOrder)
+	return uc.db.UpdateOrder(ctx, newOrder)
+}
+
+// ApplyAccrual saves accrual system answer about the order, whether it is polled or pushed by callback
+func (uc *OrderUsecase) ApplyAccrual(ctx context.Context, o *order.Order, info *request.GetOrderRequest) error {
+	o.Status = info.Status
+	if info.Accrual > 0 {
+		o.Accrual = info.Accrual
+	}
+	return uc.Update(ctx, o)
+}
+
+// ClaimUnprocessed leases unprocessed orders to the poller instance, so other instances skip them
+func (uc *OrderUsecase) ClaimUnprocessed(ctx context.Context, owner string, limit int, lease time.Duration) ([]order.Order, error) {
+	return uc.db.ClaimUnprocessedOrders(ctx, owner, limit, lease)
+}
+
+// ScheduleRetry postpones next accrual request for the order after failure, or marks it stuck
+func (uc *OrderUsecase) ScheduleRetry(ctx context.Context, o *order.Order, cause error, policy order.RetryPolicy) error {
+	o.ScheduleRetry(cause, policy)
+	return uc.db.ScheduleOrderRetry(ctx, o)
+}
+
+// PostponeCheck schedules next accrual request for the order unknown to accrual system, not later than deadline
+func (uc *OrderUsecase) PostponeCheck(ctx context.Context, o *order.Order, policy order.RetryPolicy, deadline time.Time) error {
+	o.PostponeCheck(policy, deadline)
+	if err := uc.db.ScheduleOrderRetry(ctx, o); err != nil {
 		return err
 	}
+	// accrual system answered it does not know the order
+	return uc.db.MarkOrderChecked(ctx, o.Number)
+}
+
+// Throttle releases the claim on the order till accrual system accepts requests again
+func (uc *OrderUsecase) Throttle(ctx context.Context, o *order.Order, retryAfter time.Duration) error {
+	o.Throttle(retryAfter)
+	return uc.db.ScheduleOrderRetry(ctx, o)
+}
+
+func (uc *OrderUsecase) MarkChecked(ctx context.Context, o *order.Order) error {
+	return uc.db.MarkOrderChecked(ctx, o.Number)
+}
 
-	err := uc.db.UpdateOrder(ctx, newOrder)
-	return err
+func (uc *OrderUsecase) ReleaseClaims(ctx context.Context, owner string) error {
+	return uc.db.ReleaseOrderClaims(ctx, owner)
 }
 
-func (uc *OrderUsecase) SelectUnprocessed(ctx context.Context, limit int) ([]order.Order, error) {
-	return uc.db.SelectUnprocessedOrders(ctx, limit)
+func (uc *OrderUsecase) History(ctx context.Context, o *order.Order) ([]order.StatusChange, error) {
+	return uc.db.FindOrderStatusHistory(ctx, o)
 }
 
-func (uc *OrderUsecase) FindAllUserOrders(ctx context.Context, u *user.User) ([]order.Order, error) {
-	return uc.db.FindAllUserOrders(ctx, u)
+// FindAllUserOrders returns a page of user orders and cursor of the next page, nil if it is the last one
+func (uc *OrderUsecase) FindAllUserOrders(ctx context.Context, u *user.User, filter order.Filter) ([]order.Order, *page.Cursor, error) {
+	limit := filter.Limit
+	if limit == 0 {
+		orders, err := uc.db.FindAllUserOrders(ctx, u, filter)
+		return orders, nil, err
+	}
+	// one extra order tells whether there is the next page
+	filter.Limit = limit + 1
+	orders, err := uc.db.FindAllUserOrders(ctx, u, filter)
+	if err != nil || len(orders) <= limit {
+		return orders, nil, err
+	}
+
+	orders = orders[:limit]
+	last := orders[limit-1]
+	return orders, &page.Cursor{At: last.UploadedAt, ID: last.ID}, nil
 }
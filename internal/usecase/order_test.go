@@ -0,0 +1,179 @@
+package usecase_test
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"lystem/internal/models/order"
+	"lystem/internal/models/page"
+	"lystem/internal/request"
+	"lystem/internal/usecase"
+	"lystem/pkg/memory"
+)
+
+func TestOnlyAnswersSetCheckTime(t *testing.T) {
+	policy := order.RetryPolicy{MaxAttempts: 5, BaseDelay: time.Second, MaxDelay: time.Minute}
+
+	for name, db := range storages(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			orders := usecase.NewOrderUsecase(db)
+			u := userWithBalance(t, ctx, db, 0)
+
+			failed, err := db.SaveOrder(ctx, uuid.NewString(), u.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = orders.ScheduleRetry(ctx, failed, errors.New("timeout"), policy); err != nil {
+				t.Fatal(err)
+			}
+			if found, _ := orders.FindByNumber(ctx, failed.Number); !found.CheckedAt.IsZero() {
+				t.Errorf("failed request set check time %v", found.CheckedAt)
+			}
+
+			unknown, err := db.SaveOrder(ctx, uuid.NewString(), u.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = orders.PostponeCheck(ctx, unknown, policy, time.Time{}); err != nil {
+				t.Fatal(err)
+			}
+			if found, _ := orders.FindByNumber(ctx, unknown.Number); found.CheckedAt.IsZero() {
+				t.Errorf("answer about unknown order did not set check time")
+			}
+		})
+	}
+}
+
+func TestOrdersWithoutLimitAreReturnedWhole(t *testing.T) {
+	for name, db := range storages(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			orders := usecase.NewOrderUsecase(db)
+			// the user has one order already
+			u := userWithBalance(t, ctx, db, 0)
+			for i := 0; i < page.DefaultLimit; i++ {
+				if _, err := db.SaveOrder(ctx, uuid.NewString(), u.ID); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			all, next, err := orders.FindAllUserOrders(ctx, u, order.Filter{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(all) != page.DefaultLimit+1 || next != nil {
+				t.Errorf("got %d orders and cursor %v, want %d orders without cursor", len(all), next, page.DefaultLimit+1)
+			}
+
+			first, next, err := orders.FindAllUserOrders(ctx, u, order.Filter{Query: page.Query{Limit: page.DefaultLimit}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(first) != page.DefaultLimit || next == nil {
+				t.Errorf("got %d orders and cursor %v, want %d orders with cursor", len(first), next, page.DefaultLimit)
+			}
+		})
+	}
+}
+
+// luhnNumber makes unique order number passing Luhn check
+func luhnNumber() string {
+	digits := strconv.FormatInt(1e15+rand.Int63n(9e15), 10)
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		// the check digit goes last, so the rightmost digit of payload is doubled
+		if (len(digits)-1-i)%2 == 0 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return digits + strconv.Itoa((10-sum%10)%10)
+}
+
+func TestSaveBatchResults(t *testing.T) {
+	for name, db := range storages(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			orders := usecase.NewOrderUsecase(db)
+			u := userWithBalance(t, ctx, db, 0)
+			other := userWithBalance(t, ctx, db, 0)
+
+			yours, others, fresh := luhnNumber(), luhnNumber(), luhnNumber()
+			if _, err := db.SaveOrder(ctx, yours, u.ID); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := db.SaveOrder(ctx, others, other.ID); err != nil {
+				t.Fatal(err)
+			}
+
+			var req request.SaveOrdersBatchRequest
+			body := strings.Join([]string{others, "12345", fresh, yours, fresh}, "\n")
+			if err := req.Parse([]byte(body)); err != nil {
+				t.Fatal(err)
+			}
+			uploads, err := orders.SaveBatch(ctx, req, u)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := []order.Upload{
+				{Number: others, Result: order.UploadOwnedByOther},
+				{Number: "12345", Result: order.UploadInvalidNumber},
+				{Number: fresh, Result: order.UploadAccepted},
+				{Number: yours, Result: order.UploadAlreadyYours},
+			}
+			if !slices.Equal(uploads, want) {
+				t.Fatalf("got %v, want %v", uploads, want)
+			}
+
+			saved, err := db.FindOrderByNumber(ctx, fresh)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if saved.UserID != u.ID {
+				t.Errorf("accepted order belongs to %d, want %d", saved.UserID, u.ID)
+			}
+			kept, err := db.FindOrderByNumber(ctx, others)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if kept.UserID != other.ID {
+				t.Errorf("order of other user moved to %d", kept.UserID)
+			}
+		})
+	}
+}
+
+func TestSaveBatchOfInvalidNumbersDoesNotTouchStorage(t *testing.T) {
+	ctx := context.Background()
+	db := memory.NewStorage()
+	u := userWithBalance(t, ctx, db, 0)
+
+	uploads, err := usecase.NewOrderUsecase(db).SaveBatch(ctx, request.SaveOrdersBatchRequest{Numbers: []string{"12345", "abc"}}, u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, upload := range uploads {
+		if upload.Result != order.UploadInvalidNumber {
+			t.Errorf("got %v, want invalid", upload)
+		}
+		saved, err := db.FindOrderByNumber(ctx, upload.Number)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if saved != nil {
+			t.Errorf("invalid number %s is saved", upload.Number)
+		}
+	}
+}
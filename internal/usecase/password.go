@@ -0,0 +1,125 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"lystem/internal/models/lockout"
+	"lystem/internal/models/session"
+	"lystem/internal/models/user"
+	"lystem/internal/notifier"
+	"lystem/internal/request"
+	"lystem/internal/storage"
+)
+
+type PasswordUsecase struct {
+	db       storage.Storage
+	notifier notifier.Notifier
+	// legacySalt verifies SHA-512 password hashes made before argon2id
+	legacySalt string
+}
+
+var ErrWrongPassword = errors.New("неверный текущий пароль")
+
+func NewPasswordUsecase(db storage.Storage, n notifier.Notifier, legacySalt string) *PasswordUsecase {
+	return &PasswordUsecase{db: db, notifier: n, legacySalt: legacySalt}
+}
+
+// Change sets new password if the current one is right and revokes all sessions
+// except one with public id currentSessionID. The current password is checked like on login:
+// wrong one counts by policy against the login and the address, locked ones get *LoginLockedError,
+// so a stolen session can not be used to guess the password.
+func (uc *PasswordUsecase) Change(ctx context.Context, u *user.User, changeRequest request.ChangePassword, currentSessionID string,
+	client session.Client, policy lockout.Policy) error {
+	foundUser, err := uc.db.FindUserByID(ctx, u.ID)
+	if err != nil {
+		return err
+	}
+
+	attempt := lockout.Attempt{Login: foundUser.Login, IP: client.IP}
+	lockedUntil, err := uc.db.ReserveLoginAttempt(ctx, &attempt, policy)
+	if err != nil {
+		return err
+	}
+	if wait := time.Until(lockedUntil); wait > 0 {
+		attempt.Result = lockout.ResultLocked
+		// audit record is best effort, the attempt is rejected anyway
+		_ = uc.db.RecordLoginAttempt(ctx, &attempt, policy)
+		return &LoginLockedError{RetryAfter: wait}
+	}
+
+	if !foundUser.ValidatePassword(changeRequest.CurrentPassword, uc.legacySalt) {
+		attempt.Result = lockout.ResultFailure
+		_ = uc.db.RecordLoginAttempt(ctx, &attempt, policy)
+		return ErrWrongPassword
+	}
+	attempt.Result = lockout.ResultSuccess
+	_ = uc.db.RecordLoginAttempt(ctx, &attempt, policy)
+
+	if err = foundUser.SetPassword(changeRequest.NewPassword); err != nil {
+		return err
+	}
+	return uc.db.ChangeUserPassword(ctx, foundUser, currentSessionID)
+}
+
+// RequestReset sends reset token valid for ttl to the user. Unknown login is not reported,
+// otherwise the endpoint would tell which logins are registered; the endpoint runs it
+// in background through passwordreset.Queue, so the response time does not tell it either.
+func (uc *PasswordUsecase) RequestReset(ctx context.Context, resetRequest request.RequestPasswordReset, ttl time.Duration) error {
+	foundUser, err := uc.db.FindUserByLogin(ctx, resetRequest.Login)
+	if errors.Is(err, storage.ErrUserNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	reset, token, err := user.NewPasswordReset(foundUser.ID, ttl)
+	if err != nil {
+		return err
+	}
+	if err = uc.db.CreatePasswordReset(ctx, reset); err != nil {
+		return err
+	}
+
+	return uc.notifier.Notify(ctx, notifier.Notification{
+		Login:     foundUser.Login,
+		Kind:      notifier.KindPasswordReset,
+		Text:      fmt.Sprintf("Код для сброса пароля действует до %s", reset.ExpiresAt.Format(time.RFC3339)),
+		Data:      map[string]string{"token": token},
+		CreatedAt: time.Now(),
+	})
+}
+
+// ReserveReset counts the reset request by policy against the login and the address, returns false
+// if either of them asked too often. Requests are never taken back, so the policy limits how often
+// resets are requested; its Action keeps the counters apart from login ones.
+func (uc *PasswordUsecase) ReserveReset(ctx context.Context, resetRequest request.RequestPasswordReset, client session.Client,
+	policy lockout.Policy) (bool, error) {
+	attempt := lockout.Attempt{Login: resetRequest.Login, IP: client.IP}
+	lockedUntil, err := uc.db.ReserveLoginAttempt(ctx, &attempt, policy)
+	if err != nil {
+		return false, err
+	}
+	return !lockedUntil.After(time.Now()), nil
+}
+
+// ConfirmReset sets new password by reset token and logs the user out everywhere.
+// Failed login attempts are forgotten, the user has just proved access to the account.
+func (uc *PasswordUsecase) ConfirmReset(ctx context.Context, confirmRequest request.ConfirmPasswordReset) error {
+	var newPassword user.User
+	if err := newPassword.SetPassword(confirmRequest.NewPassword); err != nil {
+		return err
+	}
+
+	resetUser, err := uc.db.ConsumePasswordReset(ctx, user.HashResetToken(confirmRequest.Token), newPassword.HashedPassword)
+	if err != nil {
+		return err
+	}
+
+	// password is already changed, the lock expires by itself if unlock fails
+	_, _ = uc.db.UnlockLogin(ctx, resetUser.Login)
+	return nil
+}
@@ -0,0 +1,152 @@
+package usecase_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"lystem/internal/models/lockout"
+	"lystem/internal/models/session"
+	"lystem/internal/models/user"
+	"lystem/internal/request"
+	"lystem/internal/usecase"
+)
+
+func TestExpiredPasswordResetsArePurged(t *testing.T) {
+	for name, db := range storages(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			passwordUsecase := usecase.NewPasswordUsecase(db, nil, "")
+
+			expired, _, err := user.NewPasswordReset(userWithPassword(t, ctx, db).ID, -time.Minute)
+			if err != nil {
+				t.Fatal(err)
+			}
+			valid, validToken, err := user.NewPasswordReset(userWithPassword(t, ctx, db).ID, time.Hour)
+			if err != nil {
+				t.Fatal(err)
+			}
+			used, usedToken, err := user.NewPasswordReset(userWithPassword(t, ctx, db).ID, time.Hour)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, r := range []*user.PasswordReset{expired, valid, used} {
+				if err = db.CreatePasswordReset(ctx, r); err != nil {
+					t.Fatal(err)
+				}
+			}
+			confirm := request.ConfirmPasswordReset{Token: usedToken, NewPassword: "new-password"}
+			if err = passwordUsecase.ConfirmReset(ctx, confirm); err != nil {
+				t.Fatal(err)
+			}
+
+			// used resets are kept while usedBefore is zero
+			deleted, err := db.DeleteExpiredPasswordResets(ctx, time.Now(), time.Time{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if deleted < 1 {
+				t.Errorf("expired reset was not deleted")
+			}
+			deleted, err = db.DeleteExpiredPasswordResets(ctx, time.Now(), time.Now().Add(time.Minute))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if deleted < 1 {
+				t.Errorf("used reset was not deleted")
+			}
+
+			confirm = request.ConfirmPasswordReset{Token: validToken, NewPassword: "new-password"}
+			if err = passwordUsecase.ConfirmReset(ctx, confirm); err != nil {
+				t.Errorf("valid reset was purged: %v", err)
+			}
+		})
+	}
+}
+
+func TestWrongCurrentPasswordIsLockedOut(t *testing.T) {
+	for name, db := range storages(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			u := userWithPassword(t, ctx, db)
+			passwordUsecase := usecase.NewPasswordUsecase(db, nil, "")
+			client := session.Client{IP: uuid.NewString()}
+			wrong := request.ChangePassword{CurrentPassword: "guess", NewPassword: "new password"}
+
+			// the first typo is free, the second one delays the next attempt by BaseDelay
+			for i := 0; i < 2; i++ {
+				if err := passwordUsecase.Change(ctx, u, wrong, "", client, testLoginPolicy); !errors.Is(err, usecase.ErrWrongPassword) {
+					t.Fatalf("attempt %d: got %v, want %v", i+1, err, usecase.ErrWrongPassword)
+				}
+			}
+
+			right := request.ChangePassword{CurrentPassword: "password", NewPassword: "new password"}
+			err := passwordUsecase.Change(ctx, u, right, "", client, testLoginPolicy)
+			var lockedErr *usecase.LoginLockedError
+			if !errors.As(err, &lockedErr) || lockedErr.RetryAfter <= 0 {
+				t.Fatalf("got %v, want *LoginLockedError", err)
+			}
+
+			// the counter is shared with login
+			_, err = usecase.NewSessionUsecase(db, "").Create(ctx, request.CreateSession{Login: u.Login, Password: "password"}, client, testLoginPolicy)
+			if !errors.As(err, &lockedErr) {
+				t.Errorf("login got %v, want *LoginLockedError", err)
+			}
+			stored, err := db.FindUserByID(ctx, u.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !stored.ValidatePassword("password", "") {
+				t.Error("password is changed while locked")
+			}
+		})
+	}
+}
+
+func TestResetRequestsAreThrottled(t *testing.T) {
+	resetPolicy := testLoginPolicy
+	resetPolicy.Action = lockout.ActionPasswordReset
+
+	for name, db := range storages(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			u := userWithPassword(t, ctx, db)
+			passwordUsecase := usecase.NewPasswordUsecase(db, nil, "")
+			reserve := func(login string, client session.Client) bool {
+				t.Helper()
+				allowed, err := passwordUsecase.ReserveReset(ctx, request.RequestPasswordReset{Login: login}, client, resetPolicy)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return allowed
+			}
+
+			// per login: the second request delays the next one by BaseDelay, whatever the address is
+			for i, want := range []bool{true, true, false} {
+				if got := reserve(u.Login, session.Client{IP: uuid.NewString()}); got != want {
+					t.Errorf("request %d for the login: allowed %t, want %t", i+1, got, want)
+				}
+			}
+
+			// per address: IPMaxFailures requests for any logins lock the address
+			client := session.Client{IP: uuid.NewString()}
+			for i := 0; i < resetPolicy.IPMaxFailures; i++ {
+				if !reserve(uuid.NewString(), client) {
+					t.Fatalf("request %d from the address is throttled", i+1)
+				}
+			}
+			if reserve(uuid.NewString(), client) {
+				t.Error("address is not throttled")
+			}
+
+			// reset requests do not lock login
+			if _, err := usecase.NewSessionUsecase(db, "").Create(ctx, request.CreateSession{Login: u.Login, Password: "password"},
+				session.Client{IP: uuid.NewString()}, testLoginPolicy); err != nil {
+				t.Errorf("login after reset requests: %v", err)
+			}
+		})
+	}
+}
@@ -3,7 +3,9 @@ package usecase
 import (
 	"context"
 	"errors"
+	"time"
 
+	"lystem/internal/models/lockout"
 	"lystem/internal/models/session"
 	"lystem/internal/models/user"
 	"lystem/internal/request"
@@ -11,28 +13,84 @@ import (
 )
 
 type SessionUsecase struct {
-	db   storage.Storage
-	salt string
+	db storage.Storage
+	// legacySalt verifies SHA-512 password hashes made before argon2id
+	legacySalt string
 }
 
-var ErrInvalidCreds = errors.New("неверная пара логин/пароль")
+var (
+	ErrInvalidCreds = errors.New("неверная пара логин/пароль")
+	ErrLoginLocked  = errors.New("слишком много неудачных попыток входа, повторите позже")
+	ErrUserBlocked  = errors.New("учётная запись заблокирована")
+)
+
+// LoginLockedError rejects login attempt without checking the password
+type LoginLockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginLockedError) Error() string {
+	return ErrLoginLocked.Error()
+}
+
+func (e *LoginLockedError) Unwrap() error {
+	return ErrLoginLocked
+}
 
-func NewSessionUsecase(db storage.Storage, salt string) *SessionUsecase {
-	return &SessionUsecase{db, salt}
+func NewSessionUsecase(db storage.Storage, legacySalt string) *SessionUsecase {
+	return &SessionUsecase{db, legacySalt}
 }
 
-func (uc *SessionUsecase) Create(ctx context.Context, sessionRequest request.CreateSession) (*session.Session, error) {
+// Create checks credentials and starts a session. Legacy or outdated password hash
+// is replaced with a fresh one while the plain password is at hand.
+// Every attempt is counted by policy as failed before the password check and taken back on success,
+// so parallel attempts can not slip past the delay. Locked login or address gets *LoginLockedError.
+func (uc *SessionUsecase) Create(ctx context.Context, sessionRequest request.CreateSession, client session.Client, policy lockout.Policy) (*session.Session, error) {
+	attempt := lockout.Attempt{Login: sessionRequest.Login, IP: client.IP}
+
+	lockedUntil, err := uc.db.ReserveLoginAttempt(ctx, &attempt, policy)
+	if err != nil {
+		return nil, err
+	}
+	if wait := time.Until(lockedUntil); wait > 0 {
+		attempt.Result = lockout.ResultLocked
+		// audit record is best effort, the attempt is rejected anyway
+		_ = uc.db.RecordLoginAttempt(ctx, &attempt, policy)
+		return nil, &LoginLockedError{RetryAfter: wait}
+	}
+
 	foundUser, err := uc.db.FindUserByLogin(ctx, sessionRequest.Login)
+	if errors.Is(err, storage.ErrUserNotFound) {
+		user.SpendPasswordCheck(sessionRequest.Password)
+		return nil, uc.fail(ctx, &attempt, policy)
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	valid := foundUser.ValidatePassword(sessionRequest.Password, uc.salt)
+	valid := foundUser.ValidatePassword(sessionRequest.Password, uc.legacySalt)
 	if !valid {
-		return nil, ErrInvalidCreds
+		return nil, uc.fail(ctx, &attempt, policy)
 	}
 
-	newSession, err := uc.db.CreateSession(ctx, foundUser)
+	if foundUser.Blocked() {
+		attempt.Result = lockout.ResultBlocked
+		_ = uc.db.RecordLoginAttempt(ctx, &attempt, policy)
+		return nil, ErrUserBlocked
+	}
+
+	attempt.Result = lockout.ResultSuccess
+	// not forgotten failures only delay the next mistake, they do not block this login
+	_ = uc.db.RecordLoginAttempt(ctx, &attempt, policy)
+
+	if foundUser.NeedsRehash() {
+		if err = foundUser.SetPassword(sessionRequest.Password); err == nil {
+			// failed upgrade does not block login, it is retried on the next one
+			_ = uc.db.UpdateUserPassword(ctx, foundUser)
+		}
+	}
+
+	newSession, err := uc.db.CreateSession(ctx, foundUser, client)
 	if err != nil {
 		return nil, err
 	}
@@ -40,6 +98,29 @@ func (uc *SessionUsecase) Create(ctx context.Context, sessionRequest request.Cre
 	return newSession, nil
 }
 
-func (uc *SessionUsecase) Delete(ctx context.Context, u *user.User) error {
-	return uc.db.DeleteSession(ctx, u)
+// fail records failed attempt for audit, the failure itself was counted when the attempt was reserved
+func (uc *SessionUsecase) fail(ctx context.Context, attempt *lockout.Attempt, policy lockout.Policy) error {
+	attempt.Result = lockout.ResultFailure
+	_ = uc.db.RecordLoginAttempt(ctx, attempt, policy)
+	return ErrInvalidCreds
+}
+
+// Unlock forgets failed attempts against the login and from the addresses it was tried from,
+// returns false if there were none
+func (uc *SessionUsecase) Unlock(ctx context.Context, login string) (bool, error) {
+	return uc.db.UnlockLogin(ctx, login)
+}
+
+// Refresh issues new tokens for the session unless it ended, the old ones stop working
+func (uc *SessionUsecase) Refresh(ctx context.Context, refreshRequest request.RefreshSession, client session.Client, ttl session.TTL) (*session.Session, error) {
+	return uc.db.RefreshSession(ctx, refreshRequest.RefreshToken, client, ttl.CreatedAfter(time.Now()))
+}
+
+// Delete revokes one session of the user by its public id, other devices stay logged in
+func (uc *SessionUsecase) Delete(ctx context.Context, u *user.User, publicID string) error {
+	return uc.db.DeleteSession(ctx, u, publicID)
+}
+
+func (uc *SessionUsecase) FindAll(ctx context.Context, u *user.User) ([]session.Session, error) {
+	return uc.db.FindSessions(ctx, u)
 }
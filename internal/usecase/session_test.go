@@ -0,0 +1,258 @@
+package usecase_test
+
+import (
+	"context"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"lystem/internal/models/lockout"
+	"lystem/internal/models/session"
+	"lystem/internal/models/user"
+	"lystem/internal/request"
+	"lystem/internal/storage"
+	"lystem/internal/usecase"
+)
+
+var testLoginPolicy = lockout.Policy{
+	LoginMaxFailures: 5,
+	IPMaxFailures:    3,
+	Window:           time.Hour,
+	Lockout:          time.Hour,
+	BaseDelay:        time.Minute,
+	MaxDelay:         time.Hour,
+}
+
+// userWithPassword registers a user with password "password"
+func userWithPassword(t *testing.T, ctx context.Context, db storage.Storage) *user.User {
+	t.Helper()
+
+	u := &user.User{Login: "u-" + uuid.NewString()}
+	if err := u.SetPassword("password"); err != nil {
+		t.Fatal(err)
+	}
+	u, err := db.CreateUser(ctx, u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestSessionsAreRevokedByPublicID(t *testing.T) {
+	for name, db := range storages(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			u, err := db.CreateUser(ctx, &user.User{Login: "u-" + uuid.NewString(), HashedPassword: "-"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			first, err := db.CreateSession(ctx, u, session.Client{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			second, err := db.CreateSession(ctx, u, session.Client{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sessionUsecase := usecase.NewSessionUsecase(db, "")
+			sessions, err := sessionUsecase.FindAll(ctx, u)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, s := range sessions {
+				if s.PublicID == s.ID || s.PublicID == uuid.Nil {
+					t.Fatalf("session %v has public id %v", s.ID, s.PublicID)
+				}
+			}
+
+			// the token is a credential, not a name of the session
+			if err = sessionUsecase.Delete(ctx, u, second.ID.String()); !errors.Is(err, storage.ErrSessionNotFound) {
+				t.Fatalf("revoke by token: got %v, want ErrSessionNotFound", err)
+			}
+			if err = sessionUsecase.Delete(ctx, u, second.PublicID.String()); err != nil {
+				t.Fatalf("revoke by public id: %v", err)
+			}
+			if _, _, err = db.FindUserByToken(ctx, second.ID.String()); err == nil {
+				t.Fatal("revoked session still authorizes requests")
+			}
+			if _, _, err = db.FindUserByToken(ctx, first.ID.String()); err != nil {
+				t.Fatalf("other session is revoked too: %v", err)
+			}
+		})
+	}
+}
+
+func TestRefreshKeepsPublicID(t *testing.T) {
+	for name, db := range storages(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			u, err := db.CreateUser(ctx, &user.User{Login: "u-" + uuid.NewString(), HashedPassword: "-"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			created, err := db.CreateSession(ctx, u, session.Client{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			refreshed, err := db.RefreshSession(ctx, created.RefreshToken.String(), session.Client{}, created.CreatedAt.Add(-1))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if refreshed.ID == created.ID || refreshed.PublicID != created.PublicID {
+				t.Fatalf("refresh gave token %v and public id %v, was %v and %v", refreshed.ID, refreshed.PublicID, created.ID, created.PublicID)
+			}
+		})
+	}
+}
+
+func TestParallelLoginAttemptsAreDelayed(t *testing.T) {
+	const attempts = 20
+
+	for name, db := range storages(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			u := userWithPassword(t, ctx, db)
+			sessionUsecase := usecase.NewSessionUsecase(db, "")
+			// address is unique, counters in postgres outlive the test
+			client := session.Client{IP: uuid.NewString()}
+
+			var wg sync.WaitGroup
+			errs := make(chan error, attempts)
+			for range attempts {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, err := sessionUsecase.Create(ctx, request.CreateSession{Login: u.Login, Password: "wrong"}, client, testLoginPolicy)
+					errs <- err
+				}()
+			}
+			wg.Wait()
+			close(errs)
+
+			var checked, locked int
+			for err := range errs {
+				var lockedErr *usecase.LoginLockedError
+				switch {
+				case errors.Is(err, usecase.ErrInvalidCreds):
+					checked++
+				case errors.As(err, &lockedErr):
+					locked++
+				default:
+					t.Fatalf("unexpected error %v", err)
+				}
+			}
+			// the first typo is free, the second failure delays all the rest
+			if checked != 2 || locked != attempts-2 {
+				t.Fatalf("%d passwords checked and %d attempts locked, want 2 and %d", checked, locked, attempts-2)
+			}
+		})
+	}
+}
+
+func TestSuccessfulLoginsDoNotLockAddress(t *testing.T) {
+	for name, db := range storages(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			u := userWithPassword(t, ctx, db)
+			sessionUsecase := usecase.NewSessionUsecase(db, "")
+			client := session.Client{IP: uuid.NewString()}
+
+			for i := range testLoginPolicy.IPMaxFailures + 2 {
+				if _, err := sessionUsecase.Create(ctx, request.CreateSession{Login: u.Login, Password: "password"}, client, testLoginPolicy); err != nil {
+					t.Fatalf("login %d: %v", i+1, err)
+				}
+			}
+		})
+	}
+}
+
+func TestUnlockClearsAddresses(t *testing.T) {
+	for name, db := range storages(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			u := userWithPassword(t, ctx, db)
+			sessionUsecase := usecase.NewSessionUsecase(db, "")
+			client := session.Client{IP: uuid.NewString()}
+			policy := testLoginPolicy
+			policy.BaseDelay = 0
+
+			// the address locks before the login does
+			for range policy.IPMaxFailures {
+				_, _ = sessionUsecase.Create(ctx, request.CreateSession{Login: u.Login, Password: "wrong"}, client, policy)
+			}
+			var lockedErr *usecase.LoginLockedError
+			if _, err := sessionUsecase.Create(ctx, request.CreateSession{Login: u.Login, Password: "password"}, client, policy); !errors.As(err, &lockedErr) {
+				t.Fatalf("address is not locked: %v", err)
+			}
+
+			unlocked, err := sessionUsecase.Unlock(ctx, u.Login)
+			if err != nil || !unlocked {
+				t.Fatalf("unlock: %v, %v", unlocked, err)
+			}
+			if _, err = sessionUsecase.Create(ctx, request.CreateSession{Login: u.Login, Password: "password"}, client, policy); err != nil {
+				t.Fatalf("login after unlock: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoginUpgradesLegacyHash(t *testing.T) {
+	const legacySalt = "pepper"
+	sum := sha512.Sum512([]byte("password" + legacySalt))
+	legacyHash := hex.EncodeToString(sum[:])
+
+	for name, db := range storages(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			u, err := db.CreateUser(ctx, &user.User{Login: "u-" + uuid.NewString(), HashedPassword: legacyHash})
+			if err != nil {
+				t.Fatal(err)
+			}
+			sessionUsecase := usecase.NewSessionUsecase(db, legacySalt)
+			client := session.Client{IP: uuid.NewString()}
+
+			if _, err = sessionUsecase.Create(ctx, request.CreateSession{Login: u.Login, Password: "wrong"}, client, testLoginPolicy); !errors.Is(err, usecase.ErrInvalidCreds) {
+				t.Fatalf("got error %v, want %v", err, usecase.ErrInvalidCreds)
+			}
+			stored, err := db.FindUserByID(ctx, u.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.HashedPassword != legacyHash {
+				t.Fatal("hash is changed by failed login")
+			}
+
+			if _, err = sessionUsecase.Create(ctx, request.CreateSession{Login: u.Login, Password: "password"}, client, testLoginPolicy); err != nil {
+				t.Fatal(err)
+			}
+			if stored, err = db.FindUserByID(ctx, u.ID); err != nil {
+				t.Fatal(err)
+			}
+			if stored.NeedsRehash() {
+				t.Fatalf("hash is not upgraded: %s", stored.HashedPassword)
+			}
+			if !stored.ValidatePassword("password", "") {
+				t.Error("upgraded hash does not match the password")
+			}
+
+			// the next login validates the new hash, legacy salt does not matter anymore
+			if _, err = usecase.NewSessionUsecase(db, "").Create(ctx, request.CreateSession{Login: u.Login, Password: "password"}, client, testLoginPolicy); err != nil {
+				t.Fatal(err)
+			}
+			again, err := db.FindUserByID(ctx, u.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if again.HashedPassword != stored.HashedPassword {
+				t.Error("current hash is rehashed on login")
+			}
+		})
+	}
+}
@@ -7,7 +7,6 @@ import (
 	"lystem/internal/models/balance"
 	"lystem/internal/models/session"
 	"lystem/internal/models/user"
-	"lystem/internal/models/withdrawal"
 	"lystem/internal/request"
 	"lystem/internal/storage"
 )
@@ -17,11 +16,11 @@ type UserUsecase struct {
 	factory *factory.UserFactory
 }
 
-func NewUserUsecase(db storage.Storage, userSalt string) *UserUsecase {
-	return &UserUsecase{db, factory.NewUserFactory(userSalt)}
+func NewUserUsecase(db storage.Storage) *UserUsecase {
+	return &UserUsecase{db, factory.NewUserFactory()}
 }
 
-func (uc *UserUsecase) CreateUserAndSession(ctx context.Context, req request.CreateUser) (*session.Session, error) {
+func (uc *UserUsecase) CreateUserAndSession(ctx context.Context, req request.CreateUser, client session.Client) (*session.Session, error) {
 	newUser, err := uc.factory.Build(req)
 	if err != nil {
 		return nil, err
@@ -32,20 +31,9 @@ func (uc *UserUsecase) CreateUserAndSession(ctx context.Context, req request.Cre
 		return nil, err
 	}
 
-	return uc.db.CreateSession(ctx, savedUser)
+	return uc.db.CreateSession(ctx, savedUser, client)
 }
 
-func (uc *UserUsecase) GetBalanceAndWithdrawals(ctx context.Context, currUser *user.User) (*balance.Balance, []withdrawal.Withdrawal, error) {
-	var withdrawals []withdrawal.Withdrawal
-	userBalance, err := uc.db.FindBalance(ctx, currUser)
-	if err != nil {
-		return nil, withdrawals, err
-	}
-
-	withdrawals, err = uc.db.FindWithdrawals(ctx, userBalance)
-	if err != nil {
-		return userBalance, withdrawals, err
-	}
-
-	return userBalance, withdrawals, nil
+func (uc *UserUsecase) GetBalance(ctx context.Context, currUser *user.User) (*balance.Balance, error) {
+	return uc.db.FindBalance(ctx, currUser)
 }
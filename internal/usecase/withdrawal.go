@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 
+	"lystem/internal/models/page"
 	"lystem/internal/models/user"
 	"lystem/internal/models/withdrawal"
 	"lystem/internal/request"
@@ -15,7 +16,7 @@ type WithdrawalUsecase struct {
 }
 
 var (
-	ErrNotEnoughBalance   = errors.New("not enough balance")
+	ErrNotEnoughBalance   = storage.ErrNotEnoughBalance
 	ErrOrderUserIncorrect = errors.New("order user incorrect")
 )
 
@@ -23,22 +24,35 @@ func NewWithdrawalUsecase(db storage.Storage) *WithdrawalUsecase {
 	return &WithdrawalUsecase{db}
 }
 
+// Create checks balance and deducts the sum atomically, returns ErrNotEnoughBalance if balance is insufficient
 func (uc *WithdrawalUsecase) Create(ctx context.Context, wRequest request.WithdrawRequest, currentUser *user.User) (*withdrawal.Withdrawal, error) {
-	userBalance, err := uc.db.FindBalance(ctx, currentUser)
-	if err != nil {
-		return nil, err
-	}
-	if userBalance.Current < wRequest.Sum {
-		return nil, ErrNotEnoughBalance
-	}
-
 	return uc.db.CreateWithdrawal(ctx, wRequest.Order, currentUser, wRequest.Sum)
 }
 
-func (uc *WithdrawalUsecase) FindAll(ctx context.Context, currUser *user.User) ([]withdrawal.Withdrawal, error) {
+func (uc *WithdrawalUsecase) FindByOrder(ctx context.Context, orderNumber string, currUser *user.User) ([]withdrawal.Withdrawal, error) {
+	return uc.db.FindOrderWithdrawals(ctx, orderNumber, currUser)
+}
+
+// FindAll returns a page of user withdrawals and cursor of the next page, nil if it is the last one
+func (uc *WithdrawalUsecase) FindAll(ctx context.Context, currUser *user.User, q page.Query) ([]withdrawal.Withdrawal, *page.Cursor, error) {
 	userBalance, err := uc.db.FindBalance(ctx, currUser)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	limit := q.Limit
+	if limit == 0 {
+		withdrawals, err := uc.db.FindWithdrawals(ctx, userBalance, q)
+		return withdrawals, nil, err
 	}
-	return uc.db.FindWithdrawals(ctx, userBalance)
+	// one extra withdrawal tells whether there is the next page
+	q.Limit = limit + 1
+	withdrawals, err := uc.db.FindWithdrawals(ctx, userBalance, q)
+	if err != nil || len(withdrawals) <= limit {
+		return withdrawals, nil, err
+	}
+
+	withdrawals = withdrawals[:limit]
+	last := withdrawals[limit-1]
+	return withdrawals, &page.Cursor{At: last.ProcessedAt, ID: last.ID}, nil
 }
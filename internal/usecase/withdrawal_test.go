@@ -0,0 +1,120 @@
+package usecase_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"lystem/internal/models/money"
+	"lystem/internal/models/order"
+	"lystem/internal/models/user"
+	"lystem/internal/request"
+	"lystem/internal/storage"
+	"lystem/internal/usecase"
+	"lystem/pkg/memory"
+	"lystem/pkg/postgres"
+)
+
+// storages returns the in-memory storage and, with TEST_DATABASE_URI set, the postgres one
+func storages(t *testing.T) map[string]storage.Storage {
+	t.Helper()
+
+	dbs := map[string]storage.Storage{"memory": memory.NewStorage()}
+	if uri := os.Getenv("TEST_DATABASE_URI"); uri != "" {
+		db, err := postgres.NewStorage(uri)
+		if err != nil {
+			t.Fatalf("connect to %s: %v", uri, err)
+		}
+		t.Cleanup(db.Close)
+		dbs["postgres"] = db
+	}
+	return dbs
+}
+
+// userWithBalance registers a user and credits accrual of a processed order
+func userWithBalance(t *testing.T, ctx context.Context, db storage.Storage, accrual money.Money) *user.User {
+	t.Helper()
+
+	u, err := db.CreateUser(ctx, &user.User{Login: "u-" + uuid.NewString(), HashedPassword: "-"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	o, err := db.SaveOrder(ctx, uuid.NewString(), u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o.Status = order.StatusProcessed
+	o.Accrual = accrual
+	if err = db.UpdateOrder(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestWithdrawalsDoNotOverdraw(t *testing.T) {
+	const (
+		parallel  = 50
+		sum       = money.Money(1000)
+		succeeded = 10
+	)
+
+	for name, db := range storages(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			u := userWithBalance(t, ctx, db, sum*succeeded)
+			withdrawals := usecase.NewWithdrawalUsecase(db)
+
+			var (
+				wg       sync.WaitGroup
+				mu       sync.Mutex
+				ok, poor int
+			)
+			start := make(chan struct{})
+			for i := range parallel {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					<-start
+					req := request.WithdrawRequest{Order: strconv.Itoa(u.ID) + "-" + strconv.Itoa(i), Sum: sum}
+					_, err := withdrawals.Create(ctx, req, u)
+
+					mu.Lock()
+					defer mu.Unlock()
+					switch {
+					case err == nil:
+						ok++
+					case errors.Is(err, usecase.ErrNotEnoughBalance):
+						poor++
+					default:
+						t.Errorf("withdraw: %v", err)
+					}
+				}()
+			}
+			close(start)
+			wg.Wait()
+
+			if ok != succeeded || poor != parallel-succeeded {
+				t.Errorf("withdrawals succeeded %d, rejected %d, want %d and %d", ok, poor, succeeded, parallel-succeeded)
+			}
+			b, err := db.FindBalance(ctx, u)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if b.Current != 0 {
+				t.Errorf("balance %s after withdrawals, want 0", b.Current)
+			}
+			report, err := db.CheckLedger(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !report.OK() {
+				t.Errorf("ledger is inconsistent: %+v", report)
+			}
+		})
+	}
+}
@@ -0,0 +1,219 @@
+// Package accrualstub is a stand-in for the accrual system. It implements
+// GET /api/orders/{number} with scripted answers, so the orders poller can be
+// exercised without the real service.
+package accrualstub
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+)
+
+// Accrual system order statuses
+const (
+	StatusRegistered = "REGISTERED"
+	StatusInvalid    = "INVALID"
+	StatusProcessing = "PROCESSING"
+	StatusProcessed  = "PROCESSED"
+)
+
+const defaultRetryAfter = 60
+
+// Step is one answer for an order. Every request for the order moves its
+// script to the next step, the last step is repeated forever.
+type Step struct {
+	Status  string  `json:"status"`
+	Accrual float64 `json:"accrual,omitempty"`
+}
+
+// Rules configures stub behaviour.
+//
+//	Orders        - scripted answers per order number, unknown orders get 204
+//	ThrottleEvery - every n-th request is answered with 429 and Retry-After
+//	RetryAfter    - Retry-After header value in seconds
+//	FailEvery     - every n-th request starts a burst of FailBurst 500 answers
+type Rules struct {
+	Orders        map[string][]Step `json:"orders"`
+	ThrottleEvery int               `json:"throttle_every"`
+	RetryAfter    int               `json:"retry_after"`
+	FailEvery     int               `json:"fail_every"`
+	FailBurst     int               `json:"fail_burst"`
+}
+
+type orderResponse struct {
+	Order   string  `json:"order"`
+	Status  string  `json:"status"`
+	Accrual float64 `json:"accrual,omitempty"`
+}
+
+type script struct {
+	steps    []Step
+	pos      int
+	requests int
+}
+
+type Stub struct {
+	mu  sync.Mutex
+	mux *http.ServeMux
+
+	orders        map[string]*script
+	throttleEvery int
+	retryAfter    int
+	failEvery     int
+	failBurst     int
+
+	requests     int
+	throttleLeft int
+	failLeft     int
+}
+
+func New(rules Rules) *Stub {
+	s := &Stub{
+		mux:           http.NewServeMux(),
+		orders:        make(map[string]*script),
+		throttleEvery: rules.ThrottleEvery,
+		retryAfter:    rules.RetryAfter,
+		failEvery:     rules.FailEvery,
+		failBurst:     rules.FailBurst,
+	}
+	if s.retryAfter <= 0 {
+		s.retryAfter = defaultRetryAfter
+	}
+	if s.failBurst <= 0 {
+		s.failBurst = 1
+	}
+	for number, steps := range rules.Orders {
+		s.Script(number, steps...)
+	}
+
+	s.mux.HandleFunc("GET /api/orders/{number}", s.getOrder)
+	s.mux.HandleFunc("PUT /stub/orders/{number}", s.putScript)
+	return s
+}
+
+// NewTestServer starts stub on a local httptest server. Server URL is what
+// gophermart expects in ACCRUAL_SYSTEM_ADDRESS.
+func NewTestServer(rules Rules) (*Stub, *httptest.Server) {
+	s := New(rules)
+	return s, httptest.NewServer(s)
+}
+
+func (s *Stub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Script replaces answers for the order number.
+func (s *Stub) Script(number string, steps ...Step) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(steps) == 0 {
+		delete(s.orders, number)
+		return
+	}
+	s.orders[number] = &script{steps: steps}
+}
+
+// Progress scripts the usual REGISTERED -> PROCESSING -> PROCESSED lifecycle.
+func (s *Stub) Progress(number string, accrual float64) {
+	s.Script(number,
+		Step{Status: StatusRegistered},
+		Step{Status: StatusProcessing},
+		Step{Status: StatusProcessed, Accrual: accrual},
+	)
+}
+
+// Throttle answers the next n requests with 429.
+func (s *Stub) Throttle(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.throttleLeft = n
+}
+
+// Fail answers the next n requests with 500.
+func (s *Stub) Fail(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failLeft = n
+}
+
+// Requests returns how many requests for the scripted order were answered from its script.
+// Requests answered with 429 or 500 before reaching the script are not counted,
+// unknown orders always give 0; total of all requests is not exposed.
+func (s *Stub) Requests(number string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if sc, ok := s.orders[number]; ok {
+		return sc.requests
+	}
+	return 0
+}
+
+func (s *Stub) getOrder(w http.ResponseWriter, r *http.Request) {
+	number := r.PathValue("number")
+
+	s.mu.Lock()
+	code, step := s.next(number)
+	retryAfter := s.retryAfter
+	s.mu.Unlock()
+
+	switch code {
+	case http.StatusOK:
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(orderResponse{Order: number, Status: step.Status, Accrual: step.Accrual})
+	case http.StatusTooManyRequests:
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(code)
+		_, _ = w.Write([]byte("No more than N requests per minute allowed"))
+	default:
+		w.WriteHeader(code)
+	}
+}
+
+// next decides the answer for the request, must be called under s.mu
+func (s *Stub) next(number string) (int, Step) {
+	s.requests++
+
+	if s.throttleLeft > 0 {
+		s.throttleLeft--
+		return http.StatusTooManyRequests, Step{}
+	}
+	if s.throttleEvery > 0 && s.requests%s.throttleEvery == 0 {
+		return http.StatusTooManyRequests, Step{}
+	}
+
+	if s.failEvery > 0 && s.requests%s.failEvery == 0 {
+		s.failLeft += s.failBurst
+	}
+	if s.failLeft > 0 {
+		s.failLeft--
+		return http.StatusInternalServerError, Step{}
+	}
+
+	sc, ok := s.orders[number]
+	if !ok {
+		return http.StatusNoContent, Step{}
+	}
+
+	sc.requests++
+	step := sc.steps[sc.pos]
+	if sc.pos < len(sc.steps)-1 {
+		sc.pos++
+	}
+	return http.StatusOK, step
+}
+
+func (s *Stub) putScript(w http.ResponseWriter, r *http.Request) {
+	var steps []Step
+	if err := json.NewDecoder(r.Body).Decode(&steps); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.Script(r.PathValue("number"), steps...)
+	w.WriteHeader(http.StatusNoContent)
+}
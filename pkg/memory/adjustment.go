@@ -0,0 +1,100 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"lystem/internal/models/adjustment"
+	"lystem/internal/storage"
+)
+
+func (s *MemStorage) CreateAdjustment(_ context.Context, a *adjustment.Adjustment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if a.Status == adjustment.StatusApplied {
+		if err := s.applyAdjustment(a); err != nil {
+			return err
+		}
+		decidedAt := time.Now()
+		a.DecidedAt = &decidedAt
+	}
+
+	s.lastAdjustmentID++
+	a.ID = s.lastAdjustmentID
+	a.CreatedAt = time.Now()
+	s.adjustments = append(s.adjustments, *a)
+	return nil
+}
+
+func (s *MemStorage) FindAdjustment(_ context.Context, id int) (*adjustment.Adjustment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, a := range s.adjustments {
+		if a.ID == id {
+			return &a, nil
+		}
+	}
+	return nil, storage.ErrAdjustmentNotFound
+}
+
+func (s *MemStorage) FindAdjustments(_ context.Context, f adjustment.Filter) ([]adjustment.Adjustment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var adjustments []adjustment.Adjustment
+	for _, a := range s.adjustments {
+		if f.Match(a) {
+			adjustments = append(adjustments, a)
+		}
+	}
+	return adjustments, nil
+}
+
+func (s *MemStorage) DecideAdjustment(_ context.Context, id int, status string, decidedBy int) (*adjustment.Adjustment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.adjustments {
+		if s.adjustments[i].ID != id {
+			continue
+		}
+
+		a := s.adjustments[i]
+		if a.Status != adjustment.StatusPending {
+			return nil, storage.ErrAdjustmentDecided
+		}
+		if status == adjustment.StatusApplied {
+			if err := s.applyAdjustment(&a); err != nil {
+				return nil, err
+			}
+		}
+		decidedAt := time.Now()
+		a.Status = status
+		a.DecidedBy = &decidedBy
+		a.DecidedAt = &decidedAt
+		s.adjustments[i] = a
+		return &a, nil
+	}
+	return nil, storage.ErrAdjustmentNotFound
+}
+
+// applyAdjustment changes the balance and posts ledger transaction, must be called under write lock
+func (s *MemStorage) applyAdjustment(a *adjustment.Adjustment) error {
+	userBalance, ok := s.balances[a.UserID]
+	if !ok {
+		return storage.ErrBalanceNotFound
+	}
+	if userBalance.Current+a.Amount < 0 {
+		return storage.ErrNotEnoughBalance
+	}
+
+	userBalance.Current += a.Amount
+	s.balances[a.UserID] = userBalance
+
+	entries := a.Transfer()
+	s.post(entries)
+	a.TransactionID = &entries[0].TransactionID
+	return nil
+}
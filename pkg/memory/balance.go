@@ -0,0 +1,28 @@
+package memory
+
+import (
+	"context"
+
+	"lystem/internal/models/balance"
+	"lystem/internal/models/ledger"
+	"lystem/internal/models/user"
+	"lystem/internal/storage"
+)
+
+func (s *MemStorage) FindBalance(_ context.Context, currentUser *user.User) (*balance.Balance, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	userBalance, ok := s.balances[currentUser.ID]
+	if !ok {
+		return nil, storage.ErrBalanceNotFound
+	}
+
+	account := ledger.UserAccount(currentUser.ID)
+	for _, e := range s.ledger {
+		if e.Account == account && e.Kind == ledger.KindWithdrawal {
+			userBalance.Withdrawn -= e.Amount
+		}
+	}
+	return &userBalance, nil
+}
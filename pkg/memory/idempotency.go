@@ -0,0 +1,63 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"lystem/internal/models/idempotency"
+)
+
+type idempotencyKey struct {
+	userID int
+	key    string
+}
+
+func (s *MemStorage) ReserveIdempotencyKey(_ context.Context, rec *idempotency.Record, ttl idempotency.TTL) (*idempotency.Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := idempotencyKey{rec.UserID, rec.Key}
+	if existing, ok := s.idempotencyKeys[k]; ok && !ttl.Expired(&existing, time.Now()) {
+		return &existing, nil
+	}
+
+	rec.CreatedAt = time.Now()
+	s.idempotencyKeys[k] = *rec
+	return nil, nil
+}
+
+func (s *MemStorage) CompleteIdempotencyKey(_ context.Context, rec *idempotency.Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := idempotencyKey{rec.UserID, rec.Key}
+	if existing, ok := s.idempotencyKeys[k]; ok {
+		existing.StatusCode = rec.StatusCode
+		existing.ContentType = rec.ContentType
+		existing.Body = append([]byte(nil), rec.Body...)
+		s.idempotencyKeys[k] = existing
+	}
+	return nil
+}
+
+func (s *MemStorage) ReleaseIdempotencyKey(_ context.Context, userID int, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.idempotencyKeys, idempotencyKey{userID, key})
+	return nil
+}
+
+func (s *MemStorage) DeleteExpiredIdempotencyKeys(_ context.Context, createdBefore time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deleted int64
+	for k, rec := range s.idempotencyKeys {
+		if rec.CreatedAt.Before(createdBefore) {
+			delete(s.idempotencyKeys, k)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
@@ -0,0 +1,70 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+
+	"lystem/internal/models/ledger"
+	"lystem/internal/models/money"
+	"lystem/internal/models/user"
+)
+
+func (s *MemStorage) FindLedgerEntries(_ context.Context, currUser *user.User) ([]ledger.Entry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	account := ledger.UserAccount(currUser.ID)
+	var entries []ledger.Entry
+	for _, e := range s.ledger {
+		if e.Account == account {
+			entries = append(entries, e)
+		}
+	}
+	// newest first, as in postgres storage
+	slices.Reverse(entries)
+	return entries, nil
+}
+
+func (s *MemStorage) CheckLedger(_ context.Context) (*ledger.Report, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sums := make(map[string]money.Money)
+	transactions := make(map[uuid.UUID]money.Money)
+	var order []uuid.UUID
+	for _, e := range s.ledger {
+		sums[e.Account] += e.Amount
+		if _, ok := transactions[e.TransactionID]; !ok {
+			order = append(order, e.TransactionID)
+		}
+		transactions[e.TransactionID] += e.Amount
+	}
+
+	var report ledger.Report
+	for _, u := range s.users {
+		b := s.balances[u.ID]
+		if sum := sums[ledger.UserAccount(u.ID)]; sum != b.Current {
+			report.Mismatches = append(report.Mismatches, ledger.Mismatch{UserID: u.ID, Current: b.Current, LedgerSum: sum})
+		}
+	}
+	for _, id := range order {
+		if transactions[id] != 0 {
+			report.UnbalancedTransactions = append(report.UnbalancedTransactions, id)
+		}
+	}
+	return &report, nil
+}
+
+// post appends entries to the ledger, must be called under write lock
+func (s *MemStorage) post(entries []ledger.Entry) {
+	now := time.Now()
+	for _, e := range entries {
+		s.lastEntryID++
+		e.ID = s.lastEntryID
+		e.CreatedAt = now
+		s.ledger = append(s.ledger, e)
+	}
+}
@@ -0,0 +1,113 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"lystem/internal/models/lockout"
+)
+
+type failuresKey struct {
+	scope string
+	key   string
+}
+
+func (s *MemStorage) ReserveLoginAttempt(_ context.Context, a *lockout.Attempt, policy lockout.Policy) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := policy.Keys(a.Login, a.IP)
+	var lockedUntil time.Time
+	for _, key := range keys {
+		if c, ok := s.loginFailures[failuresKey{key.Scope, key.Key}]; ok && c.LockedUntil.After(lockedUntil) {
+			lockedUntil = c.LockedUntil
+		}
+	}
+
+	now := time.Now()
+	if lockedUntil.After(now) {
+		return lockedUntil, nil
+	}
+
+	for _, key := range keys {
+		k := failuresKey{key.Scope, key.Key}
+		counter, ok := s.loginFailures[k]
+		if !ok {
+			counter = key
+		}
+		policy.Fail(&counter, now)
+		s.loginFailures[k] = counter
+	}
+	return time.Time{}, nil
+}
+
+func (s *MemStorage) RecordLoginAttempt(_ context.Context, a *lockout.Attempt, policy lockout.Policy) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastLoginAttemptID++
+	a.ID = s.lastLoginAttemptID
+	a.AttemptedAt = time.Now()
+	s.loginAttempts = append(s.loginAttempts, *a)
+
+	if a.Result != lockout.ResultSuccess && a.Result != lockout.ResultBlocked {
+		return nil
+	}
+	for _, key := range policy.Keys(a.Login, a.IP) {
+		k := failuresKey{key.Scope, key.Key}
+		if key.Scope == policy.Scope(lockout.ScopeLogin) && a.Result == lockout.ResultSuccess {
+			// address counter is kept, otherwise logging into own account would reset it
+			delete(s.loginFailures, k)
+			continue
+		}
+		if counter, ok := s.loginFailures[k]; ok {
+			policy.Forgive(&counter)
+			s.loginFailures[k] = counter
+		}
+	}
+	return nil
+}
+
+func (s *MemStorage) UnlockLogin(_ context.Context, login string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := []failuresKey{{lockout.ScopeLogin, login}}
+	for _, a := range s.loginAttempts {
+		if a.Login == login {
+			keys = append(keys, failuresKey{lockout.ScopeIP, a.IP})
+		}
+	}
+
+	var unlocked bool
+	for _, key := range keys {
+		if _, ok := s.loginFailures[key]; ok {
+			delete(s.loginFailures, key)
+			unlocked = true
+		}
+	}
+	return unlocked, nil
+}
+
+func (s *MemStorage) DeleteExpiredLoginAttempts(_ context.Context, attemptedBefore, failedBefore time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept := s.loginAttempts[:0]
+	for _, a := range s.loginAttempts {
+		if !a.AttemptedAt.Before(attemptedBefore) {
+			kept = append(kept, a)
+		}
+	}
+	deleted := int64(len(s.loginAttempts) - len(kept))
+	s.loginAttempts = kept
+
+	now := time.Now()
+	for key, c := range s.loginFailures {
+		if c.LastFailureAt.Before(failedBefore) && c.LockedUntil.Before(now) {
+			delete(s.loginFailures, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
@@ -0,0 +1,59 @@
+package memory
+
+import (
+	"sync"
+
+	"lystem/internal/models/adjustment"
+	"lystem/internal/models/balance"
+	"lystem/internal/models/idempotency"
+	"lystem/internal/models/ledger"
+	"lystem/internal/models/lockout"
+	"lystem/internal/models/order"
+	"lystem/internal/models/session"
+	"lystem/internal/models/user"
+	"lystem/internal/models/withdrawal"
+)
+
+// MemStorage keeps all the data in process memory. It follows the same rules
+// as the postgres storage - unique logins and order numbers, balance changes
+// applied atomically together with the order or withdrawal they belong to.
+// Useful for local runs and tests without a database.
+type MemStorage struct {
+	mu sync.RWMutex
+
+	users          []user.User
+	passwordResets []user.PasswordReset
+	sessions       map[string]session.Session
+	orders         []order.Order
+	orderHistory   []order.StatusChange
+	balances       map[int]balance.Balance
+	withdrawals    []withdrawal.Withdrawal
+	ledger         []ledger.Entry
+	adjustments    []adjustment.Adjustment
+
+	idempotencyKeys map[idempotencyKey]idempotency.Record
+
+	loginAttempts []lockout.Attempt
+	loginFailures map[failuresKey]lockout.Counter
+
+	lastUserID          int
+	lastOrderID         int
+	lastWithdrawalID    int
+	lastEntryID         int
+	lastStatusChangeID  int
+	lastLoginAttemptID  int64
+	lastPasswordResetID int
+	lastAdjustmentID    int
+}
+
+func NewStorage() *MemStorage {
+	return &MemStorage{
+		sessions: make(map[string]session.Session),
+		balances: make(map[int]balance.Balance),
+
+		idempotencyKeys: make(map[idempotencyKey]idempotency.Record),
+		loginFailures:   make(map[failuresKey]lockout.Counter),
+	}
+}
+
+func (s *MemStorage) Close() {}
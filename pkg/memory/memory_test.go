@@ -0,0 +1,130 @@
+package memory_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+
+	"lystem/internal/models/money"
+	"lystem/internal/models/order"
+	"lystem/internal/models/page"
+	"lystem/internal/models/user"
+	"lystem/internal/storage"
+	"lystem/pkg/memory"
+)
+
+// TestConcurrentWithdrawals is meant for go test -race: withdrawals and reads of the same balance run at once
+func TestConcurrentWithdrawals(t *testing.T) {
+	const (
+		parallel  = 50
+		sum       = money.Money(1000)
+		succeeded = 10
+	)
+
+	ctx := context.Background()
+	db := memory.NewStorage()
+	u, err := db.CreateUser(ctx, &user.User{Login: "user", HashedPassword: "-"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	o, err := db.SaveOrder(ctx, "1", u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o.Status = order.StatusProcessed
+	o.Accrual = sum * succeeded
+	if err = db.UpdateOrder(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		ok, poor int
+	)
+	start := make(chan struct{})
+	for i := range parallel {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			<-start
+			_, err := db.CreateWithdrawal(ctx, "w-"+strconv.Itoa(i), u, sum)
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				ok++
+			case errors.Is(err, storage.ErrNotEnoughBalance):
+				poor++
+			default:
+				t.Errorf("CreateWithdrawal: %v", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			<-start
+			b, err := db.FindBalance(ctx, u)
+			if err != nil {
+				t.Errorf("FindBalance: %v", err)
+				return
+			}
+			if b.Current < 0 {
+				t.Errorf("balance went negative: %s", b.Current)
+			}
+			if _, err = db.FindWithdrawals(ctx, b, page.Query{}); err != nil {
+				t.Errorf("FindWithdrawals: %v", err)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	if ok != succeeded || poor != parallel-succeeded {
+		t.Errorf("withdrawals succeeded %d, rejected %d, want %d and %d", ok, poor, succeeded, parallel-succeeded)
+	}
+	b, err := db.FindBalance(ctx, u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Current != 0 || b.Withdrawn != sum*succeeded {
+		t.Errorf("balance %s, withdrawn %s, want 0 and %s", b.Current, b.Withdrawn, sum*succeeded)
+	}
+}
+
+func TestNotFoundErrors(t *testing.T) {
+	ctx := context.Background()
+	db := memory.NewStorage()
+
+	if _, err := db.FindUserByID(ctx, 42); !errors.Is(err, storage.ErrUserNotFound) {
+		t.Errorf("FindUserByID() error = %v, want %v", err, storage.ErrUserNotFound)
+	}
+	if _, _, err := db.FindUserByToken(ctx, "unknown"); !errors.Is(err, storage.ErrSessionNotFound) {
+		t.Errorf("FindUserByToken() error = %v, want %v", err, storage.ErrSessionNotFound)
+	}
+	if err := db.SetUserRole(ctx, &user.User{ID: 42}); !errors.Is(err, storage.ErrUserNotFound) {
+		t.Errorf("SetUserRole() error = %v, want %v", err, storage.ErrUserNotFound)
+	}
+	if err := db.UpdateOrder(ctx, &order.Order{Number: "unknown", Status: order.StatusProcessing}); !errors.Is(err, storage.ErrOrderNotFound) {
+		t.Errorf("UpdateOrder() error = %v, want %v", err, storage.ErrOrderNotFound)
+	}
+	if _, err := db.FindBalance(ctx, &user.User{ID: 42}); !errors.Is(err, storage.ErrBalanceNotFound) {
+		t.Errorf("FindBalance() error = %v, want %v", err, storage.ErrBalanceNotFound)
+	}
+	if _, err := db.CreateWithdrawal(ctx, "2377225624", &user.User{ID: 42}, 100); !errors.Is(err, storage.ErrBalanceNotFound) {
+		t.Errorf("CreateWithdrawal() error = %v, want %v", err, storage.ErrBalanceNotFound)
+	}
+
+	u, err := db.CreateUser(ctx, &user.User{Login: "user", HashedPassword: "-"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = db.SaveOrder(ctx, "1", u.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = db.SaveOrder(ctx, "1", u.ID); !errors.Is(err, storage.ErrOrderAlreadyExists) {
+		t.Errorf("SaveOrder() of the same number error = %v, want %v", err, storage.ErrOrderAlreadyExists)
+	}
+}
@@ -0,0 +1,257 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"lystem/internal/models/ledger"
+	"lystem/internal/models/order"
+	"lystem/internal/models/user"
+	"lystem/internal/storage"
+)
+
+var unprocessedStatuses = []string{order.StatusNew, order.StatusRegistered, order.StatusProcessing}
+
+func (s *MemStorage) FindOrderByNumber(_ context.Context, number string) (*order.Order, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	i := s.orderIndex(number)
+	if i < 0 {
+		return nil, nil
+	}
+
+	foundOrder := s.orders[i]
+	return &foundOrder, nil
+}
+
+func (s *MemStorage) SaveOrder(_ context.Context, number string, userID int) (*order.Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.orderIndex(number) >= 0 {
+		return nil, storage.ErrOrderAlreadyExists
+	}
+	if _, ok := s.findUserByID(userID); !ok {
+		return nil, storage.ErrUserNotFound
+	}
+
+	s.lastOrderID++
+	newOrder := order.Order{
+		ID:         s.lastOrderID,
+		Number:     number,
+		UserID:     userID,
+		Status:     order.StatusNew,
+		UploadedAt: time.Now(),
+	}
+	s.orders = append(s.orders, newOrder)
+	s.recordStatusChange(order.StatusChange{OrderID: newOrder.ID, To: order.StatusNew})
+
+	return &newOrder, nil
+}
+
+func (s *MemStorage) SaveOrders(_ context.Context, numbers []string, userID int) ([]order.Upload, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.findUserByID(userID); !ok {
+		return nil, storage.ErrUserNotFound
+	}
+
+	uploads := make([]order.Upload, 0, len(numbers))
+	for _, number := range numbers {
+		if i := s.orderIndex(number); i >= 0 {
+			result := order.UploadOwnedByOther
+			if s.orders[i].UserID == userID {
+				result = order.UploadAlreadyYours
+			}
+			uploads = append(uploads, order.Upload{Number: number, Result: result})
+			continue
+		}
+
+		s.lastOrderID++
+		newOrder := order.Order{
+			ID:         s.lastOrderID,
+			Number:     number,
+			UserID:     userID,
+			Status:     order.StatusNew,
+			UploadedAt: time.Now(),
+		}
+		s.orders = append(s.orders, newOrder)
+		s.recordStatusChange(order.StatusChange{OrderID: newOrder.ID, To: order.StatusNew})
+		uploads = append(uploads, order.Upload{Number: number, Result: order.UploadAccepted})
+	}
+	return uploads, nil
+}
+
+func (s *MemStorage) UpdateOrder(_ context.Context, newOrder *order.Order) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.orderIndex(newOrder.Number)
+	if i < 0 {
+		return storage.ErrOrderNotFound
+	}
+	current := s.orders[i]
+	if err := order.CheckTransition(current.Status, newOrder.Status); err != nil {
+		return err
+	}
+	if order.IsFinal(current.Status) {
+		return nil
+	}
+
+	if newOrder.Status == order.StatusProcessed {
+		userBalance, ok := s.balances[current.UserID]
+		if !ok {
+			return storage.ErrBalanceNotFound
+		}
+		userBalance.Current += newOrder.Accrual
+		s.balances[userBalance.UserID] = userBalance
+		if newOrder.Accrual > 0 {
+			s.post(ledger.Transfer(ledger.KindAccrual, ledger.AccountAccruals, ledger.UserAccount(userBalance.UserID), newOrder.Accrual, newOrder.Number, ""))
+		}
+	}
+
+	s.orders[i].Accrual = newOrder.Accrual
+	s.orders[i].Status = newOrder.Status
+	s.orders[i].CheckedAt = time.Now()
+	s.resetRetry(i)
+	if current.Status != newOrder.Status {
+		s.recordStatusChange(order.StatusChange{OrderID: current.ID, From: current.Status, To: newOrder.Status})
+	}
+
+	return nil
+}
+
+func (s *MemStorage) FindOrderStatusHistory(_ context.Context, o *order.Order) ([]order.StatusChange, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var changes []order.StatusChange
+	for _, c := range s.orderHistory {
+		if c.OrderID == o.ID {
+			changes = append(changes, c)
+		}
+	}
+	return changes, nil
+}
+
+func (s *MemStorage) FindAllUserOrders(_ context.Context, u *user.User, filter order.Filter) ([]order.Order, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var orders []order.Order
+	for _, o := range s.orders {
+		if o.UserID != u.ID || !filter.InRange(o.UploadedAt) || !filter.Follows(o.UploadedAt, o.ID) {
+			continue
+		}
+		if len(filter.Statuses) > 0 && !slices.Contains(filter.Statuses, o.Status) {
+			continue
+		}
+		orders = append(orders, o)
+	}
+	slices.SortFunc(orders, func(a, b order.Order) int {
+		return compareKeys(filter.Desc, a.UploadedAt, a.ID, b.UploadedAt, b.ID)
+	})
+	return limit(orders, filter.Limit), nil
+}
+
+func (s *MemStorage) ClaimUnprocessedOrders(_ context.Context, owner string, limit int, lease time.Duration) ([]order.Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	var orders []order.Order
+	for i, o := range s.orders {
+		if len(orders) >= limit {
+			break
+		}
+		if !slices.Contains(unprocessedStatuses, o.Status) {
+			continue
+		}
+		if o.ClaimedBy != "" && o.ClaimedBy != owner && o.LeaseUntil.After(now) {
+			continue
+		}
+		if o.NextAttemptAt.After(now) {
+			continue
+		}
+
+		s.orders[i].ClaimedBy = owner
+		s.orders[i].LeaseUntil = now.Add(lease)
+		orders = append(orders, s.orders[i])
+	}
+	return orders, nil
+}
+
+func (s *MemStorage) ScheduleOrderRetry(_ context.Context, o *order.Order) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.orderIndex(o.Number)
+	if i < 0 || order.IsFinal(s.orders[i].Status) {
+		return nil
+	}
+	current := s.orders[i]
+	// retry changes status only when the order gets stuck, status set by a callback meanwhile is kept
+	if o.Status != order.StatusStuck {
+		o.Status = current.Status
+	}
+	if err := order.CheckTransition(current.Status, o.Status); err != nil {
+		return err
+	}
+
+	s.orders[i].Status = o.Status
+	s.orders[i].Attempts = o.Attempts
+	s.orders[i].LastError = o.LastError
+	s.orders[i].NextAttemptAt = o.NextAttemptAt
+	s.orders[i].ClaimedBy = ""
+	s.orders[i].LeaseUntil = time.Time{}
+	if current.Status != o.Status {
+		s.recordStatusChange(order.StatusChange{OrderID: current.ID, From: current.Status, To: o.Status, Reason: o.LastError})
+	}
+	return nil
+}
+
+func (s *MemStorage) MarkOrderChecked(_ context.Context, number string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if i := s.orderIndex(number); i >= 0 {
+		s.orders[i].CheckedAt = time.Now()
+	}
+	return nil
+}
+
+func (s *MemStorage) ReleaseOrderClaims(_ context.Context, owner string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.orders {
+		if s.orders[i].ClaimedBy == owner {
+			s.orders[i].ClaimedBy = ""
+			s.orders[i].LeaseUntil = time.Time{}
+		}
+	}
+	return nil
+}
+
+// resetRetry clears retry schedule after successful answer from accrual system
+func (s *MemStorage) resetRetry(i int) {
+	s.orders[i].Attempts = 0
+	s.orders[i].LastError = ""
+	s.orders[i].NextAttemptAt = time.Time{}
+}
+
+func (s *MemStorage) recordStatusChange(change order.StatusChange) {
+	s.lastStatusChangeID++
+	change.ID = s.lastStatusChangeID
+	change.ChangedAt = time.Now()
+	s.orderHistory = append(s.orderHistory, change)
+}
+
+func (s *MemStorage) orderIndex(number string) int {
+	return slices.IndexFunc(s.orders, func(o order.Order) bool {
+		return o.Number == number
+	})
+}
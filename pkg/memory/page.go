@@ -0,0 +1,26 @@
+package memory
+
+import (
+	"cmp"
+	"time"
+)
+
+// compareKeys orders list items by time and then by id, the same way postgres page queries do
+func compareKeys(desc bool, aAt time.Time, aID int, bAt time.Time, bID int) int {
+	c := aAt.Compare(bAt)
+	if c == 0 {
+		c = cmp.Compare(aID, bID)
+	}
+	if desc {
+		return -c
+	}
+	return c
+}
+
+// limit cuts the page, non-positive n means no limit
+func limit[T any](items []T, n int) []T {
+	if n > 0 && len(items) > n {
+		return items[:n]
+	}
+	return items
+}
@@ -0,0 +1,106 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"lystem/internal/models/user"
+	"lystem/internal/storage"
+)
+
+func (s *MemStorage) FindUserByID(_ context.Context, id int) (*user.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	foundUser, ok := s.findUserByID(id)
+	if !ok {
+		return nil, storage.ErrUserNotFound
+	}
+	return &foundUser, nil
+}
+
+func (s *MemStorage) ChangeUserPassword(_ context.Context, u *user.User, keepSessionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.setPassword(u.ID, u.HashedPassword) {
+		return storage.ErrUserNotFound
+	}
+	s.deleteOtherSessions(u.ID, keepSessionID)
+	return nil
+}
+
+func (s *MemStorage) CreatePasswordReset(_ context.Context, reset *user.PasswordReset) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept := s.passwordResets[:0]
+	for _, r := range s.passwordResets {
+		if r.UserID != reset.UserID || r.UsedAt != nil {
+			kept = append(kept, r)
+		}
+	}
+	s.passwordResets = kept
+
+	s.lastPasswordResetID++
+	reset.ID = s.lastPasswordResetID
+	reset.CreatedAt = time.Now()
+	s.passwordResets = append(s.passwordResets, *reset)
+	return nil
+}
+
+func (s *MemStorage) ConsumePasswordReset(_ context.Context, tokenHash string, hashedPassword string) (*user.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for i := range s.passwordResets {
+		r := &s.passwordResets[i]
+		if r.TokenHash != tokenHash || r.UsedAt != nil || !now.Before(r.ExpiresAt) {
+			continue
+		}
+		r.UsedAt = &now
+
+		if !s.setPassword(r.UserID, hashedPassword) {
+			return nil, storage.ErrUserNotFound
+		}
+		s.deleteOtherSessions(r.UserID, "")
+		foundUser, _ := s.findUserByID(r.UserID)
+		return &foundUser, nil
+	}
+	return nil, storage.ErrResetTokenInvalid
+}
+
+func (s *MemStorage) DeleteExpiredPasswordResets(_ context.Context, expiredBefore, usedBefore time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept := s.passwordResets[:0]
+	for _, r := range s.passwordResets {
+		if r.UsedAt == nil && !r.ExpiresAt.Before(expiredBefore) || r.UsedAt != nil && !r.UsedAt.Before(usedBefore) {
+			kept = append(kept, r)
+		}
+	}
+	deleted := int64(len(s.passwordResets) - len(kept))
+	s.passwordResets = kept
+	return deleted, nil
+}
+
+func (s *MemStorage) setPassword(userID int, hashedPassword string) bool {
+	for i := range s.users {
+		if s.users[i].ID == userID {
+			s.users[i].HashedPassword = hashedPassword
+			return true
+		}
+	}
+	return false
+}
+
+// deleteOtherSessions revokes sessions of the user except one with public id keepID, all of them if keepID is empty
+func (s *MemStorage) deleteOtherSessions(userID int, keepID string) {
+	for id, sess := range s.sessions {
+		if sess.UserID == userID && sess.PublicID.String() != keepID {
+			delete(s.sessions, id)
+		}
+	}
+}
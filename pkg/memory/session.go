@@ -0,0 +1,123 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+
+	"lystem/internal/models/session"
+	"lystem/internal/models/user"
+	"lystem/internal/storage"
+)
+
+func (s *MemStorage) CreateSession(_ context.Context, currentUser *user.User, client session.Client) (*session.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.findUserByID(currentUser.ID); !ok {
+		return nil, storage.ErrUserNotFound
+	}
+
+	now := time.Now()
+	newSession := session.Session{
+		ID:           uuid.New(),
+		PublicID:     uuid.New(),
+		CreatedAt:    now,
+		LastSeenAt:   now,
+		UserID:       currentUser.ID,
+		RefreshToken: uuid.New(),
+		Client:       client,
+	}
+	s.sessions[newSession.ID.String()] = newSession
+
+	return &newSession, nil
+}
+
+func (s *MemStorage) FindSessions(_ context.Context, u *user.User) ([]session.Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var sessions []session.Session
+	for _, sess := range s.sessions {
+		if sess.UserID == u.ID {
+			sessions = append(sessions, sess)
+		}
+	}
+	slices.SortFunc(sessions, func(a, b session.Session) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	return sessions, nil
+}
+
+func (s *MemStorage) FindSession(_ context.Context, u *user.User, publicID string) (*session.Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, sess := range s.sessions {
+		if sess.UserID == u.ID && sess.PublicID.String() == publicID {
+			return &sess, nil
+		}
+	}
+	return nil, storage.ErrSessionNotFound
+}
+
+func (s *MemStorage) DeleteSession(_ context.Context, u *user.User, publicID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, sess := range s.sessions {
+		if sess.UserID == u.ID && sess.PublicID.String() == publicID {
+			delete(s.sessions, id)
+			return nil
+		}
+	}
+	return storage.ErrSessionNotFound
+}
+
+func (s *MemStorage) TouchSession(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sess, ok := s.sessions[id]
+	if !ok {
+		return storage.ErrSessionNotFound
+	}
+	sess.LastSeenAt = time.Now()
+	s.sessions[id] = sess
+	return nil
+}
+
+func (s *MemStorage) RefreshSession(_ context.Context, refreshToken string, client session.Client, createdAfter time.Time) (*session.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, sess := range s.sessions {
+		if sess.RefreshToken.String() != refreshToken || !sess.CreatedAt.After(createdAfter) {
+			continue
+		}
+		delete(s.sessions, id)
+		sess.ID = uuid.New()
+		sess.RefreshToken = uuid.New()
+		sess.LastSeenAt = time.Now()
+		sess.Client = client
+		s.sessions[sess.ID.String()] = sess
+		return &sess, nil
+	}
+	return nil, storage.ErrSessionNotFound
+}
+
+func (s *MemStorage) DeleteEndedSessions(_ context.Context, createdBefore time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deleted int64
+	for id, sess := range s.sessions {
+		if !sess.CreatedAt.After(createdBefore) {
+			delete(s.sessions, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
@@ -0,0 +1,123 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"lystem/internal/models/balance"
+	"lystem/internal/models/session"
+	"lystem/internal/models/user"
+	"lystem/internal/storage"
+)
+
+func (s *MemStorage) CreateUser(_ context.Context, newUser *user.User) (*user.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.findUserByLogin(newUser.Login); ok {
+		return nil, storage.ErrUserAlreadyExists
+	}
+
+	s.lastUserID++
+	savedUser := user.User{
+		ID:             s.lastUserID,
+		Login:          newUser.Login,
+		HashedPassword: newUser.HashedPassword,
+		Role:           user.RoleUser,
+	}
+	s.users = append(s.users, savedUser)
+	s.balances[savedUser.ID] = balance.Balance{Current: 0, UserID: savedUser.ID}
+
+	return &savedUser, nil
+}
+
+func (s *MemStorage) FindUserByLogin(_ context.Context, login string) (*user.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	foundUser, ok := s.findUserByLogin(login)
+	if !ok {
+		return nil, storage.ErrUserNotFound
+	}
+	return &foundUser, nil
+}
+
+func (s *MemStorage) UpdateUserPassword(_ context.Context, u *user.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.setPassword(u.ID, u.HashedPassword) {
+		return storage.ErrUserNotFound
+	}
+	return nil
+}
+
+func (s *MemStorage) FindUserByToken(_ context.Context, token string) (*user.User, *session.Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	foundSession, ok := s.sessions[token]
+	if !ok {
+		return nil, nil, storage.ErrSessionNotFound
+	}
+
+	foundUser, ok := s.findUserByID(foundSession.UserID)
+	if !ok {
+		return nil, nil, storage.ErrUserNotFound
+	}
+	return &foundUser, &foundSession, nil
+}
+
+func (s *MemStorage) findUserByLogin(login string) (user.User, bool) {
+	for _, u := range s.users {
+		if u.Login == login {
+			return u, true
+		}
+	}
+	return user.User{}, false
+}
+
+func (s *MemStorage) findUserByID(id int) (user.User, bool) {
+	for _, u := range s.users {
+		if u.ID == id {
+			return u, true
+		}
+	}
+	return user.User{}, false
+}
+
+func (s *MemStorage) SetUserRole(_ context.Context, u *user.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.users {
+		if s.users[i].ID == u.ID {
+			s.users[i].Role = u.Role
+			return nil
+		}
+	}
+	return storage.ErrUserNotFound
+}
+
+func (s *MemStorage) SetUserBlocked(_ context.Context, u *user.User, blocked bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.users {
+		if s.users[i].ID != u.ID {
+			continue
+		}
+		if !blocked {
+			s.users[i].BlockedAt = nil
+		} else if s.users[i].BlockedAt == nil {
+			now := time.Now()
+			s.users[i].BlockedAt = &now
+		}
+		u.BlockedAt = s.users[i].BlockedAt
+		if blocked {
+			s.deleteOtherSessions(u.ID, "")
+		}
+		return nil
+	}
+	return storage.ErrUserNotFound
+}
@@ -0,0 +1,79 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"lystem/internal/models/balance"
+	"lystem/internal/models/ledger"
+	"lystem/internal/models/money"
+	"lystem/internal/models/page"
+	"lystem/internal/models/user"
+	"lystem/internal/models/withdrawal"
+	"lystem/internal/storage"
+)
+
+func (s *MemStorage) FindWithdrawals(_ context.Context, userBalance *balance.Balance, q page.Query) ([]withdrawal.Withdrawal, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var withdrawals []withdrawal.Withdrawal
+	for _, w := range s.withdrawals {
+		if w.BalanceID == userBalance.UserID && q.InRange(w.ProcessedAt) && q.Follows(w.ProcessedAt, w.ID) {
+			withdrawals = append(withdrawals, w)
+		}
+	}
+	slices.SortFunc(withdrawals, func(a, b withdrawal.Withdrawal) int {
+		return compareKeys(q.Desc, a.ProcessedAt, a.ID, b.ProcessedAt, b.ID)
+	})
+	return limit(withdrawals, q.Limit), nil
+}
+
+func (s *MemStorage) FindOrderWithdrawals(_ context.Context, orderNumber string, currUser *user.User) ([]withdrawal.Withdrawal, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var withdrawals []withdrawal.Withdrawal
+	for _, w := range s.withdrawals {
+		if w.OrderNumber == orderNumber && w.BalanceID == currUser.ID {
+			withdrawals = append(withdrawals, w)
+		}
+	}
+	return withdrawals, nil
+}
+
+func (s *MemStorage) CreateWithdrawal(_ context.Context, orderNumber string, currUser *user.User, sum money.Money) (*withdrawal.Withdrawal, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	userBalance, ok := s.balances[currUser.ID]
+	if !ok {
+		return nil, storage.ErrBalanceNotFound
+	}
+
+	if userBalance.Current < sum {
+		return nil, storage.ErrNotEnoughBalance
+	}
+	for _, w := range s.withdrawals {
+		if w.OrderNumber == orderNumber {
+			return nil, storage.ErrAlreadyWithdrawn
+		}
+	}
+
+	s.lastWithdrawalID++
+	withdraw := withdrawal.Withdrawal{
+		ID:          s.lastWithdrawalID,
+		Sum:         sum,
+		ProcessedAt: time.Now(),
+		OrderNumber: orderNumber,
+		BalanceID:   userBalance.UserID,
+	}
+	s.withdrawals = append(s.withdrawals, withdraw)
+
+	userBalance.Current -= sum
+	s.balances[currUser.ID] = userBalance
+	s.post(ledger.Transfer(ledger.KindWithdrawal, ledger.UserAccount(currUser.ID), ledger.AccountWithdrawals, sum, orderNumber, ""))
+
+	return &withdraw, nil
+}
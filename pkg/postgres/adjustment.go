@@ -0,0 +1,156 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+
+	"lystem/internal/models/adjustment"
+	"lystem/internal/repository"
+	"lystem/internal/storage"
+)
+
+func (s *DBStorage) CreateAdjustment(ctx context.Context, a *adjustment.Adjustment) error {
+	conn, err := s.instance.Acquire(ctx)
+	if err != nil {
+		return newDBError(err)
+	}
+	defer conn.Release()
+
+	adjustmentsRepo := repository.NewAdjustmentsRepository(conn)
+	balancesRepo := repository.NewBalancesRepository(conn)
+	ledgerRepo := repository.NewLedgerRepository(conn)
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return newDBError(err)
+	}
+
+	if err = adjustmentsRepo.Create(ctx, tx, a); err != nil {
+		return rollbackOnErr(ctx, tx, err)
+	}
+	if a.Status == adjustment.StatusApplied {
+		if err = applyAdjustment(ctx, tx, balancesRepo, ledgerRepo, a); err != nil {
+			if isCheckViolation(err, balancesCurrentCheck) {
+				_ = tx.Rollback(ctx)
+				return storage.ErrNotEnoughBalance
+			}
+			if errors.Is(err, pgx.ErrNoRows) {
+				_ = tx.Rollback(ctx)
+				return storage.ErrBalanceNotFound
+			}
+			return rollbackOnErr(ctx, tx, err)
+		}
+		if err = adjustmentsRepo.Decide(ctx, tx, a); err != nil {
+			return rollbackOnErr(ctx, tx, err)
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return newDBError(err)
+	}
+	return nil
+}
+
+func (s *DBStorage) FindAdjustment(ctx context.Context, id int) (*adjustment.Adjustment, error) {
+	conn, err := s.instance.Acquire(ctx)
+	if err != nil {
+		return nil, newDBError(err)
+	}
+	defer conn.Release()
+
+	adjustmentsRepo := repository.NewAdjustmentsRepository(conn)
+	found, err := adjustmentsRepo.FindAll(ctx, adjustment.Filter{ID: id})
+	if err != nil {
+		return nil, newDBError(err)
+	}
+	if len(found) == 0 {
+		return nil, storage.ErrAdjustmentNotFound
+	}
+	return &found[0], nil
+}
+
+func (s *DBStorage) FindAdjustments(ctx context.Context, f adjustment.Filter) ([]adjustment.Adjustment, error) {
+	conn, err := s.instance.Acquire(ctx)
+	if err != nil {
+		return nil, newDBError(err)
+	}
+	defer conn.Release()
+
+	adjustmentsRepo := repository.NewAdjustmentsRepository(conn)
+	adjustments, err := adjustmentsRepo.FindAll(ctx, f)
+	if err != nil {
+		return nil, newDBError(err)
+	}
+	return adjustments, nil
+}
+
+func (s *DBStorage) DecideAdjustment(ctx context.Context, id int, status string, decidedBy int) (*adjustment.Adjustment, error) {
+	conn, err := s.instance.Acquire(ctx)
+	if err != nil {
+		return nil, newDBError(err)
+	}
+	defer conn.Release()
+
+	adjustmentsRepo := repository.NewAdjustmentsRepository(conn)
+	balancesRepo := repository.NewBalancesRepository(conn)
+	ledgerRepo := repository.NewLedgerRepository(conn)
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return nil, newDBError(err)
+	}
+
+	// the row stays locked till commit, so two operators can not decide it both
+	a, err := adjustmentsRepo.Lock(ctx, tx, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			_ = tx.Rollback(ctx)
+			return nil, storage.ErrAdjustmentNotFound
+		}
+		return nil, rollbackOnErr(ctx, tx, err)
+	}
+	if a.Status != adjustment.StatusPending {
+		_ = tx.Rollback(ctx)
+		return nil, storage.ErrAdjustmentDecided
+	}
+
+	a.Status = status
+	a.DecidedBy = &decidedBy
+	if status == adjustment.StatusApplied {
+		if err = applyAdjustment(ctx, tx, balancesRepo, ledgerRepo, a); err != nil {
+			if isCheckViolation(err, balancesCurrentCheck) {
+				_ = tx.Rollback(ctx)
+				return nil, storage.ErrNotEnoughBalance
+			}
+			if errors.Is(err, pgx.ErrNoRows) {
+				_ = tx.Rollback(ctx)
+				return nil, storage.ErrBalanceNotFound
+			}
+			return nil, rollbackOnErr(ctx, tx, err)
+		}
+	}
+	if err = adjustmentsRepo.Decide(ctx, tx, a); err != nil {
+		return nil, rollbackOnErr(ctx, tx, err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, newDBError(err)
+	}
+	return a, nil
+}
+
+// applyAdjustment changes the balance and posts ledger transaction of the adjustment
+func applyAdjustment(ctx context.Context, tx pgx.Tx, balancesRepo *repository.BalancesRepository, ledgerRepo *repository.LedgerRepository, a *adjustment.Adjustment) error {
+	if err := balancesRepo.Adjust(ctx, tx, a.UserID, a.Amount); err != nil {
+		return err
+	}
+
+	entries := a.Transfer()
+	if err := ledgerRepo.Post(ctx, tx, entries); err != nil {
+		return err
+	}
+	a.TransactionID = &entries[0].TransactionID
+	return nil
+}
@@ -2,13 +2,19 @@ package postgres
 
 import (
 	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
 
 	"lystem/internal/models/balance"
 	"lystem/internal/models/user"
-	"lystem/internal/models/withdrawal"
 	"lystem/internal/repository"
+	"lystem/internal/storage"
 )
 
+// balancesCurrentCheck keeps balance from going below zero, see 0003 migration
+const balancesCurrentCheck = "balances_current_non_negative"
+
 func (s *DBStorage) FindBalance(ctx context.Context, currentUser *user.User) (*balance.Balance, error) {
 	conn, err := s.instance.Acquire(ctx)
 	if err != nil {
@@ -18,6 +24,9 @@ func (s *DBStorage) FindBalance(ctx context.Context, currentUser *user.User) (*b
 
 	balancesRepo := repository.NewBalancesRepository(conn)
 	userBalance, err := balancesRepo.FindByUser(ctx, currentUser)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, storage.ErrBalanceNotFound
+	}
 	if err != nil {
 		return nil, newDBError(err)
 	}
@@ -37,18 +46,3 @@ func (s *DBStorage) FindBalance(ctx context.Context, currentUser *user.User) (*b
 //	}
 //	return nil
 //}
-
-func (s *DBStorage) DeductFromBalance(ctx context.Context, w *withdrawal.Withdrawal, currUser *user.User) error {
-	conn, err := s.instance.Acquire(ctx)
-	if err != nil {
-		return newDBError(err)
-	}
-	defer conn.Release()
-	balancesRepo := repository.NewBalancesRepository(conn)
-
-	err = balancesRepo.Decrease(ctx, w, currUser)
-	if err != nil {
-		return newDBError(err)
-	}
-	return nil
-}
@@ -8,6 +8,8 @@ import (
 	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
+
+	"lystem/internal/storage"
 )
 
 type postgresError struct {
@@ -15,7 +17,7 @@ type postgresError struct {
 	Err  error
 }
 
-var ErrUserAlreadyExists = errors.New("логин уже занят")
+var ErrUserAlreadyExists = storage.ErrUserAlreadyExists
 
 func (dbErr *postgresError) Error() string {
 	return fmt.Sprintf("[%s] %v", dbErr.name, dbErr.Err.Error())
@@ -40,6 +42,18 @@ func newDBError(err error) error {
 	}
 }
 
+// isCheckViolation reports whether err is violation of the named check constraint
+func isCheckViolation(err error, constraint string) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == pgerrcode.CheckViolation && pgErr.ConstraintName == constraint
+}
+
+// isUniqueViolation reports whether err is violation of the named unique constraint or index
+func isUniqueViolation(err error, constraint string) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation && pgErr.ConstraintName == constraint
+}
+
 func rollbackOnErr(ctx context.Context, tx pgx.Tx, err error) error {
 	if rollbackErr := tx.Rollback(ctx); rollbackErr != nil {
 		return newDBError(rollbackErr)
@@ -0,0 +1,86 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"lystem/internal/models/idempotency"
+	"lystem/internal/repository"
+)
+
+func (s *DBStorage) ReserveIdempotencyKey(ctx context.Context, rec *idempotency.Record, ttl idempotency.TTL) (*idempotency.Record, error) {
+	conn, err := s.instance.Acquire(ctx)
+	if err != nil {
+		return nil, newDBError(err)
+	}
+	defer conn.Release()
+
+	idempotencyRepo := repository.NewIdempotencyRepository(conn)
+
+	// second attempt covers the key released between reserve and find
+	for range 2 {
+		reserved, err := idempotencyRepo.Reserve(ctx, rec, ttl, time.Now())
+		if err != nil {
+			return nil, newDBError(err)
+		}
+		if reserved {
+			return nil, nil
+		}
+
+		existing, err := idempotencyRepo.Find(ctx, rec.UserID, rec.Key)
+		if err != nil && errors.Is(err, pgx.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, newDBError(err)
+		}
+		return existing, nil
+	}
+	return nil, newDBError(errors.New("idempotency key is contended"))
+}
+
+func (s *DBStorage) CompleteIdempotencyKey(ctx context.Context, rec *idempotency.Record) error {
+	conn, err := s.instance.Acquire(ctx)
+	if err != nil {
+		return newDBError(err)
+	}
+	defer conn.Release()
+
+	idempotencyRepo := repository.NewIdempotencyRepository(conn)
+	if err = idempotencyRepo.Complete(ctx, rec); err != nil {
+		return newDBError(err)
+	}
+	return nil
+}
+
+func (s *DBStorage) ReleaseIdempotencyKey(ctx context.Context, userID int, key string) error {
+	conn, err := s.instance.Acquire(ctx)
+	if err != nil {
+		return newDBError(err)
+	}
+	defer conn.Release()
+
+	idempotencyRepo := repository.NewIdempotencyRepository(conn)
+	if err = idempotencyRepo.Delete(ctx, userID, key); err != nil {
+		return newDBError(err)
+	}
+	return nil
+}
+
+func (s *DBStorage) DeleteExpiredIdempotencyKeys(ctx context.Context, createdBefore time.Time) (int64, error) {
+	conn, err := s.instance.Acquire(ctx)
+	if err != nil {
+		return 0, newDBError(err)
+	}
+	defer conn.Release()
+
+	idempotencyRepo := repository.NewIdempotencyRepository(conn)
+	deleted, err := idempotencyRepo.DeleteExpired(ctx, createdBefore)
+	if err != nil {
+		return 0, newDBError(err)
+	}
+	return deleted, nil
+}
@@ -0,0 +1,39 @@
+package postgres
+
+import (
+	"context"
+
+	"lystem/internal/models/ledger"
+	"lystem/internal/models/user"
+	"lystem/internal/repository"
+)
+
+func (s *DBStorage) FindLedgerEntries(ctx context.Context, currUser *user.User) ([]ledger.Entry, error) {
+	conn, err := s.instance.Acquire(ctx)
+	if err != nil {
+		return nil, newDBError(err)
+	}
+	defer conn.Release()
+
+	ledgerRepo := repository.NewLedgerRepository(conn)
+	entries, err := ledgerRepo.FindByAccount(ctx, ledger.UserAccount(currUser.ID))
+	if err != nil {
+		return nil, newDBError(err)
+	}
+	return entries, nil
+}
+
+func (s *DBStorage) CheckLedger(ctx context.Context) (*ledger.Report, error) {
+	conn, err := s.instance.Acquire(ctx)
+	if err != nil {
+		return nil, newDBError(err)
+	}
+	defer conn.Release()
+
+	ledgerRepo := repository.NewLedgerRepository(conn)
+	report, err := ledgerRepo.Check(ctx)
+	if err != nil {
+		return nil, newDBError(err)
+	}
+	return report, nil
+}
@@ -0,0 +1,145 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"lystem/internal/models/lockout"
+	"lystem/internal/repository"
+)
+
+func (s *DBStorage) ReserveLoginAttempt(ctx context.Context, a *lockout.Attempt, policy lockout.Policy) (time.Time, error) {
+	conn, err := s.instance.Acquire(ctx)
+	if err != nil {
+		return time.Time{}, newDBError(err)
+	}
+	defer conn.Release()
+
+	attemptsRepo := repository.NewLoginAttemptsRepository(conn)
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return time.Time{}, newDBError(err)
+	}
+
+	// counters are locked in the same order by every attempt, login first
+	var counters []*lockout.Counter
+	var lockedUntil time.Time
+	for _, key := range policy.Keys(a.Login, a.IP) {
+		counter, err := attemptsRepo.LockCounter(ctx, tx, key.Scope, key.Key)
+		if err != nil {
+			return time.Time{}, rollbackOnErr(ctx, tx, err)
+		}
+		if counter.LockedUntil.After(lockedUntil) {
+			lockedUntil = counter.LockedUntil
+		}
+		counters = append(counters, counter)
+	}
+
+	now := time.Now()
+	if lockedUntil.After(now) {
+		if err = tx.Rollback(ctx); err != nil {
+			return time.Time{}, newDBError(err)
+		}
+		return lockedUntil, nil
+	}
+
+	for _, counter := range counters {
+		policy.Fail(counter, now)
+		if err = attemptsRepo.SaveCounter(ctx, tx, counter); err != nil {
+			return time.Time{}, rollbackOnErr(ctx, tx, err)
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return time.Time{}, newDBError(err)
+	}
+	return time.Time{}, nil
+}
+
+func (s *DBStorage) RecordLoginAttempt(ctx context.Context, a *lockout.Attempt, policy lockout.Policy) error {
+	conn, err := s.instance.Acquire(ctx)
+	if err != nil {
+		return newDBError(err)
+	}
+	defer conn.Release()
+
+	attemptsRepo := repository.NewLoginAttemptsRepository(conn)
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return newDBError(err)
+	}
+
+	if err = attemptsRepo.Add(ctx, tx, a); err != nil {
+		return rollbackOnErr(ctx, tx, err)
+	}
+
+	if a.Result == lockout.ResultSuccess || a.Result == lockout.ResultBlocked {
+		for _, key := range policy.Keys(a.Login, a.IP) {
+			if key.Scope == policy.Scope(lockout.ScopeLogin) && a.Result == lockout.ResultSuccess {
+				// address counter is kept, otherwise logging into own account would reset it
+				if _, err = attemptsRepo.DeleteCounter(ctx, tx, key.Scope, key.Key); err != nil {
+					return rollbackOnErr(ctx, tx, err)
+				}
+				continue
+			}
+
+			counter, err := attemptsRepo.LockCounter(ctx, tx, key.Scope, key.Key)
+			if err != nil {
+				return rollbackOnErr(ctx, tx, err)
+			}
+			policy.Forgive(counter)
+			if err = attemptsRepo.SaveCounter(ctx, tx, counter); err != nil {
+				return rollbackOnErr(ctx, tx, err)
+			}
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return newDBError(err)
+	}
+	return nil
+}
+
+func (s *DBStorage) UnlockLogin(ctx context.Context, login string) (bool, error) {
+	conn, err := s.instance.Acquire(ctx)
+	if err != nil {
+		return false, newDBError(err)
+	}
+	defer conn.Release()
+
+	attemptsRepo := repository.NewLoginAttemptsRepository(conn)
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return false, newDBError(err)
+	}
+	deleted, err := attemptsRepo.DeleteCounter(ctx, tx, lockout.ScopeLogin, login)
+	if err != nil {
+		return false, rollbackOnErr(ctx, tx, err)
+	}
+	deletedIPs, err := attemptsRepo.DeleteIPCounters(ctx, tx, login)
+	if err != nil {
+		return false, rollbackOnErr(ctx, tx, err)
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return false, newDBError(err)
+	}
+	return deleted || deletedIPs > 0, nil
+}
+
+func (s *DBStorage) DeleteExpiredLoginAttempts(ctx context.Context, attemptedBefore, failedBefore time.Time) (int64, error) {
+	conn, err := s.instance.Acquire(ctx)
+	if err != nil {
+		return 0, newDBError(err)
+	}
+	defer conn.Release()
+
+	attemptsRepo := repository.NewLoginAttemptsRepository(conn)
+	deleted, err := attemptsRepo.DeleteExpired(ctx, attemptedBefore, failedBefore)
+	if err != nil {
+		return 0, newDBError(err)
+	}
+	return deleted, nil
+}
@@ -0,0 +1,61 @@
+package postgres
+
+import (
+	"context"
+
+	"lystem/internal/migrations"
+)
+
+func (s *DBStorage) MigrateUp(ctx context.Context) ([]migrations.Migration, error) {
+	conn, err := s.instance.Acquire(ctx)
+	if err != nil {
+		return nil, newDBError(err)
+	}
+	defer conn.Release()
+
+	migrator, err := migrations.New(conn)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := migrator.Up(ctx)
+	if err != nil {
+		return applied, newDBError(err)
+	}
+	return applied, nil
+}
+
+func (s *DBStorage) MigrateDown(ctx context.Context) (*migrations.Migration, error) {
+	conn, err := s.instance.Acquire(ctx)
+	if err != nil {
+		return nil, newDBError(err)
+	}
+	defer conn.Release()
+
+	migrator, err := migrations.New(conn)
+	if err != nil {
+		return nil, err
+	}
+	rolledBack, err := migrator.Down(ctx)
+	if err != nil {
+		return nil, newDBError(err)
+	}
+	return rolledBack, nil
+}
+
+func (s *DBStorage) MigrationsStatus(ctx context.Context) ([]migrations.Status, error) {
+	conn, err := s.instance.Acquire(ctx)
+	if err != nil {
+		return nil, newDBError(err)
+	}
+	defer conn.Release()
+
+	migrator, err := migrations.New(conn)
+	if err != nil {
+		return nil, err
+	}
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		return nil, newDBError(err)
+	}
+	return statuses, nil
+}
@@ -3,13 +3,21 @@ package postgres
 import (
 	"context"
 	"errors"
-	"fmt"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 
+	"lystem/internal/models/ledger"
 	"lystem/internal/models/order"
 	"lystem/internal/models/user"
 	"lystem/internal/repository"
+	"lystem/internal/storage"
+)
+
+// orders numbers are unique regardless of the owner, see 0001 migration
+const (
+	ordersNumberKey     = "orders_number_key"
+	ordersNumberUserKey = "orders_number_user_id_key"
 )
 
 func (s *DBStorage) FindOrderByNumber(ctx context.Context, number string) (*order.Order, error) {
@@ -18,14 +26,16 @@ func (s *DBStorage) FindOrderByNumber(ctx context.Context, number string) (*orde
 		return nil, newDBError(err)
 	}
 	defer conn.Release()
-	fmt.Println("1")
 
 	ordersRepo := repository.NewOrdersRepository(conn)
 
 	foundOrder, err := ordersRepo.FindByNumber(ctx, number)
-	if err != nil && errors.Is(err, pgx.ErrNoRows) {
+	if errors.Is(err, pgx.ErrNoRows) {
 		return nil, nil
 	}
+	if err != nil {
+		return nil, newDBError(err)
+	}
 
 	return foundOrder, nil
 }
@@ -37,30 +47,93 @@ func (s *DBStorage) SaveOrder(ctx context.Context, number string, userID int) (*
 	}
 	defer conn.Release()
 	ordersRepo := repository.NewOrdersRepository(conn)
+	historyRepo := repository.NewOrderHistoryRepository(conn)
 
-	savedOrder, err := ordersRepo.Save(ctx, number, userID)
+	tx, err := conn.Begin(ctx)
 	if err != nil {
 		return nil, newDBError(err)
 	}
+
+	savedOrder, err := ordersRepo.Save(ctx, tx, number, userID)
+	if err != nil {
+		if isUniqueViolation(err, ordersNumberKey) || isUniqueViolation(err, ordersNumberUserKey) {
+			_ = tx.Rollback(ctx)
+			return nil, storage.ErrOrderAlreadyExists
+		}
+		return nil, rollbackOnErr(ctx, tx, err)
+	}
+	if err = historyRepo.Add(ctx, tx, &order.StatusChange{OrderID: savedOrder.ID, To: order.StatusNew}); err != nil {
+		return nil, rollbackOnErr(ctx, tx, err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, newDBError(err)
+	}
 	return savedOrder, nil
 }
 
-func (s *DBStorage) UpdateOrder(ctx context.Context, newOrder *order.Order) error {
+// SaveOrders uploads numbers in one transaction, numbers uploaded before keep their owner
+func (s *DBStorage) SaveOrders(ctx context.Context, numbers []string, userID int) ([]order.Upload, error) {
 	conn, err := s.instance.Acquire(ctx)
 	if err != nil {
-		return newDBError(err)
+		return nil, newDBError(err)
 	}
 	defer conn.Release()
-
 	ordersRepo := repository.NewOrdersRepository(conn)
-	err = ordersRepo.Update(ctx, newOrder)
+	historyRepo := repository.NewOrderHistoryRepository(conn)
+
+	tx, err := conn.Begin(ctx)
 	if err != nil {
-		return newDBError(err)
+		return nil, newDBError(err)
 	}
-	return nil
+
+	savedOrders, err := ordersRepo.SaveBatch(ctx, tx, numbers, userID)
+	if err != nil {
+		return nil, rollbackOnErr(ctx, tx, err)
+	}
+	accepted := make(map[string]struct{}, len(savedOrders))
+	for _, o := range savedOrders {
+		accepted[o.Number] = struct{}{}
+		if err = historyRepo.Add(ctx, tx, &order.StatusChange{OrderID: o.ID, To: order.StatusNew}); err != nil {
+			return nil, rollbackOnErr(ctx, tx, err)
+		}
+	}
+
+	var conflicting []string
+	for _, number := range numbers {
+		if _, ok := accepted[number]; !ok {
+			conflicting = append(conflicting, number)
+		}
+	}
+	owners := map[string]int{}
+	if len(conflicting) > 0 {
+		if owners, err = ordersRepo.FindOwners(ctx, tx, conflicting); err != nil {
+			return nil, rollbackOnErr(ctx, tx, err)
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, newDBError(err)
+	}
+
+	uploads := make([]order.Upload, 0, len(numbers))
+	for _, number := range numbers {
+		result := order.UploadAccepted
+		if _, ok := accepted[number]; !ok {
+			result = order.UploadOwnedByOther
+			if owners[number] == userID {
+				result = order.UploadAlreadyYours
+			}
+		}
+		uploads = append(uploads, order.Upload{Number: number, Result: result})
+	}
+	return uploads, nil
 }
 
-func (s *DBStorage) UpdateOrderAndIncreaseBalance(ctx context.Context, newOrder *order.Order) error {
+// UpdateOrder moves the order to the new status if the transition is legal, see order.CheckTransition,
+// and records it in status history. Accrual is credited to the user balance only on transition to PROCESSED,
+// which is final, so it happens exactly once.
+func (s *DBStorage) UpdateOrder(ctx context.Context, newOrder *order.Order) error {
 	conn, err := s.instance.Acquire(ctx)
 	if err != nil {
 		return newDBError(err)
@@ -68,20 +141,60 @@ func (s *DBStorage) UpdateOrderAndIncreaseBalance(ctx context.Context, newOrder
 	defer conn.Release()
 
 	ordersRepo := repository.NewOrdersRepository(conn)
+	historyRepo := repository.NewOrderHistoryRepository(conn)
 	balancesRepo := repository.NewBalancesRepository(conn)
+	ledgerRepo := repository.NewLedgerRepository(conn)
 
 	tx, err := conn.Begin(ctx)
 	if err != nil {
 		return newDBError(err)
 	}
 
-	updatedOrder, err := ordersRepo.UpdateReturning(ctx, tx, newOrder)
+	current, err := ordersRepo.LockByNumber(ctx, tx, newOrder.Number)
+	if errors.Is(err, pgx.ErrNoRows) {
+		_ = tx.Rollback(ctx)
+		return storage.ErrOrderNotFound
+	}
 	if err != nil {
 		return rollbackOnErr(ctx, tx, err)
 	}
-	if err = balancesRepo.Accrual(ctx, tx, updatedOrder); err != nil {
+	if err = order.CheckTransition(current.Status, newOrder.Status); err != nil {
+		_ = tx.Rollback(ctx)
+		return err
+	}
+	if order.IsFinal(current.Status) {
+		// repeated final answer, accrual was applied before
+		if err = tx.Rollback(ctx); err != nil {
+			return newDBError(err)
+		}
+		return nil
+	}
+
+	updatedOrder, err := ordersRepo.UpdateReturning(ctx, tx, newOrder)
+	if err != nil {
 		return rollbackOnErr(ctx, tx, err)
 	}
+	if current.Status != updatedOrder.Status {
+		change := &order.StatusChange{OrderID: current.ID, From: current.Status, To: updatedOrder.Status}
+		if err = historyRepo.Add(ctx, tx, change); err != nil {
+			return rollbackOnErr(ctx, tx, err)
+		}
+	}
+	if updatedOrder.Status == order.StatusProcessed {
+		if err = balancesRepo.Accrual(ctx, tx, updatedOrder); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				_ = tx.Rollback(ctx)
+				return storage.ErrBalanceNotFound
+			}
+			return rollbackOnErr(ctx, tx, err)
+		}
+		if updatedOrder.Accrual > 0 {
+			entries := ledger.Transfer(ledger.KindAccrual, ledger.AccountAccruals, ledger.UserAccount(updatedOrder.UserID), updatedOrder.Accrual, updatedOrder.Number, "")
+			if err = ledgerRepo.Post(ctx, tx, entries); err != nil {
+				return rollbackOnErr(ctx, tx, err)
+			}
+		}
+	}
 
 	if err = tx.Commit(ctx); err != nil {
 		return newDBError(err)
@@ -90,7 +203,22 @@ func (s *DBStorage) UpdateOrderAndIncreaseBalance(ctx context.Context, newOrder
 	return nil
 }
 
-func (s *DBStorage) FindAllUserOrders(ctx context.Context, u *user.User) ([]order.Order, error) {
+func (s *DBStorage) FindOrderStatusHistory(ctx context.Context, o *order.Order) ([]order.StatusChange, error) {
+	conn, err := s.instance.Acquire(ctx)
+	if err != nil {
+		return nil, newDBError(err)
+	}
+	defer conn.Release()
+
+	historyRepo := repository.NewOrderHistoryRepository(conn)
+	changes, err := historyRepo.FindByOrder(ctx, o.ID)
+	if err != nil {
+		return nil, newDBError(err)
+	}
+	return changes, nil
+}
+
+func (s *DBStorage) FindAllUserOrders(ctx context.Context, u *user.User, filter order.Filter) ([]order.Order, error) {
 	conn, err := s.instance.Acquire(ctx)
 	if err != nil {
 		return nil, newDBError(err)
@@ -98,7 +226,7 @@ func (s *DBStorage) FindAllUserOrders(ctx context.Context, u *user.User) ([]orde
 	defer conn.Release()
 
 	ordersRepo := repository.NewOrdersRepository(conn)
-	orders, err := ordersRepo.FindAllUserOrders(ctx, u)
+	orders, err := ordersRepo.FindAllUserOrders(ctx, u, filter)
 	if err != nil {
 		return nil, newDBError(err)
 	}
@@ -106,7 +234,7 @@ func (s *DBStorage) FindAllUserOrders(ctx context.Context, u *user.User) ([]orde
 	return orders, nil
 }
 
-func (s *DBStorage) SelectUnprocessedOrders(ctx context.Context, limit int) ([]order.Order, error) {
+func (s *DBStorage) ClaimUnprocessedOrders(ctx context.Context, owner string, limit int, lease time.Duration) ([]order.Order, error) {
 	conn, err := s.instance.Acquire(ctx)
 	if err != nil {
 		return nil, newDBError(err)
@@ -114,9 +242,96 @@ func (s *DBStorage) SelectUnprocessedOrders(ctx context.Context, limit int) ([]o
 	defer conn.Release()
 
 	ordersRepo := repository.NewOrdersRepository(conn)
-	orders, err := ordersRepo.SelectUnprocessed(ctx, limit)
+
+	tx, err := conn.Begin(ctx)
 	if err != nil {
 		return nil, newDBError(err)
 	}
+	orders, err := ordersRepo.ClaimUnprocessed(ctx, tx, owner, limit, lease)
+	if err != nil {
+		return nil, rollbackOnErr(ctx, tx, err)
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return nil, newDBError(err)
+	}
 	return orders, nil
 }
+
+func (s *DBStorage) ScheduleOrderRetry(ctx context.Context, o *order.Order) error {
+	conn, err := s.instance.Acquire(ctx)
+	if err != nil {
+		return newDBError(err)
+	}
+	defer conn.Release()
+
+	ordersRepo := repository.NewOrdersRepository(conn)
+	historyRepo := repository.NewOrderHistoryRepository(conn)
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return newDBError(err)
+	}
+
+	current, err := ordersRepo.LockByNumber(ctx, tx, o.Number)
+	if err != nil {
+		return rollbackOnErr(ctx, tx, err)
+	}
+	if order.IsFinal(current.Status) {
+		// accrual system callback resolved the order meanwhile
+		if err = tx.Rollback(ctx); err != nil {
+			return newDBError(err)
+		}
+		return nil
+	}
+	// retry changes status only when the order gets stuck, status set by a callback meanwhile is kept
+	if o.Status != order.StatusStuck {
+		o.Status = current.Status
+	}
+	if err = order.CheckTransition(current.Status, o.Status); err != nil {
+		_ = tx.Rollback(ctx)
+		return err
+	}
+
+	if err = ordersRepo.ScheduleRetry(ctx, tx, o); err != nil {
+		return rollbackOnErr(ctx, tx, err)
+	}
+	if current.Status != o.Status {
+		change := &order.StatusChange{OrderID: current.ID, From: current.Status, To: o.Status, Reason: o.LastError}
+		if err = historyRepo.Add(ctx, tx, change); err != nil {
+			return rollbackOnErr(ctx, tx, err)
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return newDBError(err)
+	}
+	return nil
+}
+
+func (s *DBStorage) MarkOrderChecked(ctx context.Context, number string) error {
+	conn, err := s.instance.Acquire(ctx)
+	if err != nil {
+		return newDBError(err)
+	}
+	defer conn.Release()
+
+	ordersRepo := repository.NewOrdersRepository(conn)
+	if err = ordersRepo.MarkChecked(ctx, number); err != nil {
+		return newDBError(err)
+	}
+	return nil
+}
+
+func (s *DBStorage) ReleaseOrderClaims(ctx context.Context, owner string) error {
+	conn, err := s.instance.Acquire(ctx)
+	if err != nil {
+		return newDBError(err)
+	}
+	defer conn.Release()
+
+	ordersRepo := repository.NewOrdersRepository(conn)
+	if err = ordersRepo.ReleaseClaims(ctx, owner); err != nil {
+		return newDBError(err)
+	}
+	return nil
+}
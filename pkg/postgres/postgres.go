@@ -7,8 +7,6 @@ import (
 
 	"github.com/cenkalti/backoff/v4"
 	"github.com/jackc/pgx/v5/pgxpool"
-
-	"lystem/internal/repository"
 )
 
 type DBStorage struct {
@@ -16,6 +14,19 @@ type DBStorage struct {
 }
 
 func NewStorage(uri string) (*DBStorage, error) {
+	s, err := Connect(uri)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.init(context.Background()); err != nil {
+		return &DBStorage{}, err
+	}
+
+	return s, nil
+}
+
+// Connect opens connection pool without touching database schema
+func Connect(uri string) (*DBStorage, error) {
 	ctx := context.Background()
 	var s DBStorage
 	tryCount := 0
@@ -48,36 +59,14 @@ func NewStorage(uri string) (*DBStorage, error) {
 	if err := backoff.Retry(createConn, expBackoff); err != nil {
 		return nil, fmt.Errorf("\nfailed to connect to database after retrying %d times: %v", tryCount, err)
 	}
-	if err := s.init(ctx); err != nil {
-		return &DBStorage{}, err
-	}
 
 	return &s, nil
 }
 
 func (s *DBStorage) init(ctx context.Context) error {
-	conn, err := s.instance.Acquire(ctx)
-	if err != nil {
-		return newDBError(err)
+	if _, err := s.MigrateUp(ctx); err != nil {
+		return err
 	}
-	defer conn.Release()
-
-	repo := repository.New(conn)
-
-	tx, err := conn.Begin(ctx)
-	if err != nil {
-		return newDBError(err)
-	}
-
-	err = repo.Init(ctx, tx)
-	if err != nil {
-		return rollbackOnErr(ctx, tx, err)
-	}
-
-	if err = tx.Commit(ctx); err != nil {
-		return newDBError(err)
-	}
-
 	return nil
 }
 
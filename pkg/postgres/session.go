@@ -2,13 +2,19 @@ package postgres
 
 import (
 	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 
 	"lystem/internal/models/session"
 	"lystem/internal/models/user"
 	"lystem/internal/repository"
+	"lystem/internal/storage"
 )
 
-func (s *DBStorage) CreateSession(ctx context.Context, currentUser *user.User) (*session.Session, error) {
+func (s *DBStorage) CreateSession(ctx context.Context, currentUser *user.User, client session.Client) (*session.Session, error) {
 	conn, err := s.instance.Acquire(ctx)
 	if err != nil {
 		return nil, newDBError(err)
@@ -16,25 +22,56 @@ func (s *DBStorage) CreateSession(ctx context.Context, currentUser *user.User) (
 	defer conn.Release()
 
 	sessionsRepo := repository.NewSessionsRepository(conn)
+	newSession, err := sessionsRepo.Create(ctx, currentUser, client)
+	if err != nil {
+		return nil, newDBError(err)
+	}
+	return newSession, nil
+}
 
-	// transaction used for 2 db table changing operations - creating and deleting sessions
-	tx, err := conn.Begin(ctx)
+func (s *DBStorage) FindSessions(ctx context.Context, u *user.User) ([]session.Session, error) {
+	conn, err := s.instance.Acquire(ctx)
 	if err != nil {
 		return nil, newDBError(err)
 	}
+	defer conn.Release()
 
-	newSession, err := sessionsRepo.Create(ctx, tx, currentUser)
+	sessionsRepo := repository.NewSessionsRepository(conn)
+	sessions, err := sessionsRepo.FindByUser(ctx, u)
 	if err != nil {
-		return nil, rollbackOnErr(ctx, tx, err)
+		return nil, newDBError(err)
 	}
+	return sessions, nil
+}
 
-	if err = tx.Commit(ctx); err != nil {
+func (s *DBStorage) FindSession(ctx context.Context, u *user.User, publicID string) (*session.Session, error) {
+	// malformed id can not belong to any session, postgres would reject it as uuid
+	if _, err := uuid.Parse(publicID); err != nil {
+		return nil, storage.ErrSessionNotFound
+	}
+
+	conn, err := s.instance.Acquire(ctx)
+	if err != nil {
 		return nil, newDBError(err)
 	}
-	return newSession, nil
+	defer conn.Release()
+
+	sessionsRepo := repository.NewSessionsRepository(conn)
+	foundSession, err := sessionsRepo.FindByPublicID(ctx, u, publicID)
+	if err != nil && errors.Is(err, pgx.ErrNoRows) {
+		return nil, storage.ErrSessionNotFound
+	} else if err != nil {
+		return nil, newDBError(err)
+	}
+	return foundSession, nil
 }
 
-func (s *DBStorage) DeleteSession(ctx context.Context, u *user.User) error {
+func (s *DBStorage) DeleteSession(ctx context.Context, u *user.User, publicID string) error {
+	// malformed id can not belong to any session, postgres would reject it as uuid
+	if _, err := uuid.Parse(publicID); err != nil {
+		return storage.ErrSessionNotFound
+	}
+
 	conn, err := s.instance.Acquire(ctx)
 	if err != nil {
 		return newDBError(err)
@@ -42,11 +79,62 @@ func (s *DBStorage) DeleteSession(ctx context.Context, u *user.User) error {
 	defer conn.Release()
 
 	sessionsRepo := repository.NewSessionsRepository(conn)
+	deleted, err := sessionsRepo.Delete(ctx, u, publicID)
+	if err != nil {
+		return newDBError(err)
+	}
+	if !deleted {
+		return storage.ErrSessionNotFound
+	}
+	return nil
+}
 
-	err = sessionsRepo.Delete(ctx, u)
+func (s *DBStorage) TouchSession(ctx context.Context, id string) error {
+	conn, err := s.instance.Acquire(ctx)
 	if err != nil {
 		return newDBError(err)
 	}
+	defer conn.Release()
 
+	sessionsRepo := repository.NewSessionsRepository(conn)
+	if err = sessionsRepo.Touch(ctx, id); err != nil {
+		return newDBError(err)
+	}
 	return nil
 }
+
+func (s *DBStorage) RefreshSession(ctx context.Context, refreshToken string, client session.Client, createdAfter time.Time) (*session.Session, error) {
+	if _, err := uuid.Parse(refreshToken); err != nil {
+		return nil, storage.ErrSessionNotFound
+	}
+
+	conn, err := s.instance.Acquire(ctx)
+	if err != nil {
+		return nil, newDBError(err)
+	}
+	defer conn.Release()
+
+	sessionsRepo := repository.NewSessionsRepository(conn)
+	refreshed, err := sessionsRepo.Refresh(ctx, refreshToken, client, createdAfter)
+	if err != nil && errors.Is(err, pgx.ErrNoRows) {
+		return nil, storage.ErrSessionNotFound
+	} else if err != nil {
+		return nil, newDBError(err)
+	}
+	return refreshed, nil
+}
+
+func (s *DBStorage) DeleteEndedSessions(ctx context.Context, createdBefore time.Time) (int64, error) {
+	conn, err := s.instance.Acquire(ctx)
+	if err != nil {
+		return 0, newDBError(err)
+	}
+	defer conn.Release()
+
+	sessionsRepo := repository.NewSessionsRepository(conn)
+	deleted, err := sessionsRepo.DeleteEnded(ctx, createdBefore)
+	if err != nil {
+		return 0, newDBError(err)
+	}
+	return deleted, nil
+}
@@ -3,11 +3,14 @@ package postgres
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 
+	"lystem/internal/models/session"
 	"lystem/internal/models/user"
 	"lystem/internal/repository"
+	"lystem/internal/storage"
 )
 
 func (s *DBStorage) CreateUser(ctx context.Context, newUser *user.User) (*user.User, error) {
@@ -63,45 +66,244 @@ func (s *DBStorage) FindUserByLogin(ctx context.Context, login string) (*user.Us
 	usersRepo := repository.NewUsersRepository(conn)
 	foundUser, err := usersRepo.FindByLogin(ctx, login)
 	if err != nil && errors.Is(err, pgx.ErrNoRows) {
-		return nil, pgx.ErrNoRows
+		return nil, storage.ErrUserNotFound
 	} else if err != nil {
 		return nil, newDBError(err)
 	}
 	return foundUser, nil
 }
 
-func (s *DBStorage) FindUserByToken(ctx context.Context, token string) (*user.User, error) {
+func (s *DBStorage) UpdateUserPassword(ctx context.Context, u *user.User) error {
+	conn, err := s.instance.Acquire(ctx)
+	if err != nil {
+		return newDBError(err)
+	}
+	defer conn.Release()
+
+	usersRepo := repository.NewUsersRepository(conn)
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return newDBError(err)
+	}
+	if err = usersRepo.UpdatePassword(ctx, tx, u); err != nil {
+		return rollbackOnErr(ctx, tx, err)
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return newDBError(err)
+	}
+	return nil
+}
+
+func (s *DBStorage) FindUserByID(ctx context.Context, id int) (*user.User, error) {
 	conn, err := s.instance.Acquire(ctx)
 	if err != nil {
 		return nil, newDBError(err)
 	}
 	defer conn.Release()
 
+	usersRepo := repository.NewUsersRepository(conn)
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return nil, newDBError(err)
+	}
+	foundUser, err := usersRepo.FindByID(ctx, tx, id)
+	if err != nil && errors.Is(err, pgx.ErrNoRows) {
+		_ = tx.Rollback(ctx)
+		return nil, storage.ErrUserNotFound
+	} else if err != nil {
+		return nil, rollbackOnErr(ctx, tx, err)
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return nil, newDBError(err)
+	}
+	return foundUser, nil
+}
+
+func (s *DBStorage) ChangeUserPassword(ctx context.Context, u *user.User, keepSessionID string) error {
+	conn, err := s.instance.Acquire(ctx)
+	if err != nil {
+		return newDBError(err)
+	}
+	defer conn.Release()
+
+	usersRepo := repository.NewUsersRepository(conn)
 	sessionsRepo := repository.NewSessionsRepository(conn)
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return newDBError(err)
+	}
+	if err = usersRepo.UpdatePassword(ctx, tx, u); err != nil {
+		return rollbackOnErr(ctx, tx, err)
+	}
+	if err = sessionsRepo.DeleteOthers(ctx, tx, u.ID, keepSessionID); err != nil {
+		return rollbackOnErr(ctx, tx, err)
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return newDBError(err)
+	}
+	return nil
+}
+
+func (s *DBStorage) CreatePasswordReset(ctx context.Context, reset *user.PasswordReset) error {
+	conn, err := s.instance.Acquire(ctx)
+	if err != nil {
+		return newDBError(err)
+	}
+	defer conn.Release()
+
+	resetsRepo := repository.NewPasswordResetsRepository(conn)
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return newDBError(err)
+	}
+	if err = resetsRepo.Create(ctx, tx, reset); err != nil {
+		return rollbackOnErr(ctx, tx, err)
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return newDBError(err)
+	}
+	return nil
+}
+
+func (s *DBStorage) ConsumePasswordReset(ctx context.Context, tokenHash string, hashedPassword string) (*user.User, error) {
+	conn, err := s.instance.Acquire(ctx)
+	if err != nil {
+		return nil, newDBError(err)
+	}
+	defer conn.Release()
+
+	resetsRepo := repository.NewPasswordResetsRepository(conn)
 	usersRepo := repository.NewUsersRepository(conn)
+	sessionsRepo := repository.NewSessionsRepository(conn)
 
 	tx, err := conn.Begin(ctx)
 	if err != nil {
 		return nil, newDBError(err)
 	}
 
-	foundSession, err := sessionsRepo.FindByID(ctx, tx, token)
+	reset, err := resetsRepo.LockValid(ctx, tx, tokenHash)
+	if err != nil && errors.Is(err, pgx.ErrNoRows) {
+		_ = tx.Rollback(ctx)
+		return nil, storage.ErrResetTokenInvalid
+	} else if err != nil {
+		return nil, rollbackOnErr(ctx, tx, err)
+	}
+	if err = resetsRepo.MarkUsed(ctx, tx, reset.ID); err != nil {
+		return nil, rollbackOnErr(ctx, tx, err)
+	}
+
+	foundUser, err := usersRepo.FindByID(ctx, tx, reset.UserID)
 	if err != nil {
 		return nil, rollbackOnErr(ctx, tx, err)
+	}
+	foundUser.HashedPassword = hashedPassword
+	if err = usersRepo.UpdatePassword(ctx, tx, foundUser); err != nil {
+		return nil, rollbackOnErr(ctx, tx, err)
+	}
+	// whoever knew the old password is logged out everywhere
+	if err = sessionsRepo.DeleteOthers(ctx, tx, foundUser.ID, ""); err != nil {
+		return nil, rollbackOnErr(ctx, tx, err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, newDBError(err)
+	}
+	return foundUser, nil
+}
+
+func (s *DBStorage) FindUserByToken(ctx context.Context, token string) (*user.User, *session.Session, error) {
+	conn, err := s.instance.Acquire(ctx)
+	if err != nil {
+		return nil, nil, newDBError(err)
+	}
+	defer conn.Release()
+
+	sessionsRepo := repository.NewSessionsRepository(conn)
+	usersRepo := repository.NewUsersRepository(conn)
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return nil, nil, newDBError(err)
+	}
+
+	foundSession, err := sessionsRepo.FindByID(ctx, tx, token)
+	if err != nil {
+		return nil, nil, rollbackOnErr(ctx, tx, err)
 	} else if foundSession == nil {
-		return nil, rollbackOnErr(ctx, tx, errors.New("session not found"))
+		return nil, nil, rollbackOnErr(ctx, tx, errors.New("session not found"))
 	}
 
 	foundUser, err := usersRepo.FindByID(ctx, tx, foundSession.UserID)
 	if err != nil {
-		return nil, rollbackOnErr(ctx, tx, err)
+		return nil, nil, rollbackOnErr(ctx, tx, err)
 	} else if foundUser == nil {
-		return nil, rollbackOnErr(ctx, tx, errors.New("user not found"))
+		return nil, nil, rollbackOnErr(ctx, tx, errors.New("user not found"))
 	}
 
 	if err = tx.Commit(ctx); err != nil {
-		return nil, newDBError(err)
+		return nil, nil, newDBError(err)
 	}
 
-	return foundUser, nil
+	return foundUser, foundSession, nil
+}
+
+func (s *DBStorage) SetUserRole(ctx context.Context, u *user.User) error {
+	conn, err := s.instance.Acquire(ctx)
+	if err != nil {
+		return newDBError(err)
+	}
+	defer conn.Release()
+
+	usersRepo := repository.NewUsersRepository(conn)
+	if err = usersRepo.UpdateRole(ctx, u); err != nil {
+		return newDBError(err)
+	}
+	return nil
+}
+
+func (s *DBStorage) SetUserBlocked(ctx context.Context, u *user.User, blocked bool) error {
+	conn, err := s.instance.Acquire(ctx)
+	if err != nil {
+		return newDBError(err)
+	}
+	defer conn.Release()
+
+	usersRepo := repository.NewUsersRepository(conn)
+	sessionsRepo := repository.NewSessionsRepository(conn)
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return newDBError(err)
+	}
+	if err = usersRepo.SetBlocked(ctx, tx, u, blocked); err != nil {
+		return rollbackOnErr(ctx, tx, err)
+	}
+	if blocked {
+		if err = sessionsRepo.DeleteOthers(ctx, tx, u.ID, ""); err != nil {
+			return rollbackOnErr(ctx, tx, err)
+		}
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return newDBError(err)
+	}
+	return nil
+}
+
+func (s *DBStorage) DeleteExpiredPasswordResets(ctx context.Context, expiredBefore, usedBefore time.Time) (int64, error) {
+	conn, err := s.instance.Acquire(ctx)
+	if err != nil {
+		return 0, newDBError(err)
+	}
+	defer conn.Release()
+
+	resetsRepo := repository.NewPasswordResetsRepository(conn)
+	deleted, err := resetsRepo.DeleteExpired(ctx, expiredBefore, usedBefore)
+	if err != nil {
+		return 0, newDBError(err)
+	}
+	return deleted, nil
 }
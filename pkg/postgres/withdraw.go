@@ -2,29 +2,39 @@ package postgres
 
 import (
 	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
 
 	"lystem/internal/models/balance"
+	"lystem/internal/models/ledger"
+	"lystem/internal/models/money"
+	"lystem/internal/models/page"
 	"lystem/internal/models/user"
 	"lystem/internal/models/withdrawal"
 	"lystem/internal/repository"
+	"lystem/internal/storage"
 )
 
-func (s *DBStorage) FindWithdrawals(ctx context.Context, userBalance *balance.Balance) ([]withdrawal.Withdrawal, error) {
+// withdrawalsOrderNumberKey allows one withdrawal per order number, see 0005 migration
+const withdrawalsOrderNumberKey = "withdrawals_order_number_key"
+
+func (s *DBStorage) FindWithdrawals(ctx context.Context, userBalance *balance.Balance, q page.Query) ([]withdrawal.Withdrawal, error) {
 	conn, err := s.instance.Acquire(ctx)
 	if err != nil {
-		return nil, err
+		return nil, newDBError(err)
 	}
 	defer conn.Release()
 
 	withdrawalsRepo := repository.NewWithdrawalsRepository(conn)
-	withdrawals, err := withdrawalsRepo.FindAll(ctx, userBalance)
+	withdrawals, err := withdrawalsRepo.FindAll(ctx, userBalance, q)
 	if err != nil {
 		return nil, newDBError(err)
 	}
 	return withdrawals, nil
 }
 
-func (s *DBStorage) CreateWithdrawal(ctx context.Context, orderNumber string, currUser *user.User, sum float64) (*withdrawal.Withdrawal, error) {
+func (s *DBStorage) FindOrderWithdrawals(ctx context.Context, orderNumber string, currUser *user.User) ([]withdrawal.Withdrawal, error) {
 	conn, err := s.instance.Acquire(ctx)
 	if err != nil {
 		return nil, newDBError(err)
@@ -32,22 +42,62 @@ func (s *DBStorage) CreateWithdrawal(ctx context.Context, orderNumber string, cu
 	defer conn.Release()
 
 	withdrawalsRepo := repository.NewWithdrawalsRepository(conn)
-	balancesRepo := repository.NewBalancesRepository(conn)
+	withdrawals, err := withdrawalsRepo.FindByOrder(ctx, orderNumber, currUser.ID)
+	if err != nil {
+		return nil, newDBError(err)
+	}
+	return withdrawals, nil
+}
 
-	userBalance, err := balancesRepo.FindByUser(ctx, currUser)
+func (s *DBStorage) CreateWithdrawal(ctx context.Context, orderNumber string, currUser *user.User, sum money.Money) (*withdrawal.Withdrawal, error) {
+	conn, err := s.instance.Acquire(ctx)
 	if err != nil {
 		return nil, newDBError(err)
 	}
+	defer conn.Release()
+
+	withdrawalsRepo := repository.NewWithdrawalsRepository(conn)
+	balancesRepo := repository.NewBalancesRepository(conn)
+	ledgerRepo := repository.NewLedgerRepository(conn)
 
 	tx, err := conn.Begin(ctx)
 	if err != nil {
 		return nil, newDBError(err)
 	}
+
+	// balance row stays locked till commit, so concurrent withdrawals are checked one by one
+	userBalance, err := balancesRepo.FindByUserForUpdate(ctx, tx, currUser)
+	if errors.Is(err, pgx.ErrNoRows) {
+		_ = tx.Rollback(ctx)
+		return nil, storage.ErrBalanceNotFound
+	}
+	if err != nil {
+		return nil, rollbackOnErr(ctx, tx, err)
+	}
+	if userBalance.Current < sum {
+		if err = tx.Rollback(ctx); err != nil {
+			return nil, newDBError(err)
+		}
+		return nil, storage.ErrNotEnoughBalance
+	}
+
 	withdraw, err := withdrawalsRepo.Create(ctx, tx, orderNumber, userBalance, sum)
 	if err != nil {
+		if isUniqueViolation(err, withdrawalsOrderNumberKey) {
+			_ = tx.Rollback(ctx)
+			return nil, storage.ErrAlreadyWithdrawn
+		}
+		return nil, rollbackOnErr(ctx, tx, err)
+	}
+	if err = balancesRepo.Decrease(ctx, tx, withdraw, currUser); err != nil {
+		if isCheckViolation(err, balancesCurrentCheck) {
+			_ = tx.Rollback(ctx)
+			return nil, storage.ErrNotEnoughBalance
+		}
 		return nil, rollbackOnErr(ctx, tx, err)
 	}
-	if err = balancesRepo.Decrease(ctx, withdraw, currUser); err != nil {
+	entries := ledger.Transfer(ledger.KindWithdrawal, ledger.UserAccount(currUser.ID), ledger.AccountWithdrawals, sum, orderNumber, "")
+	if err = ledgerRepo.Post(ctx, tx, entries); err != nil {
 		return nil, rollbackOnErr(ctx, tx, err)
 	}
 